@@ -0,0 +1,96 @@
+package feature_toggle_impl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/auth"
+	"github.com/peterrosell/feature-toggle-service/storage"
+	"golang.org/x/net/context"
+)
+
+const DEFAULT_AUDIT_LOG_LIMIT = 100
+
+var entityKinds = map[api.EntityType]string{
+	api.EntityType_FEATURE:       storage.KindFeature,
+	api.EntityType_TOGGLE_RULE:   storage.KindToggleRule,
+	api.EntityType_PROPERTY:      storage.KindProperty,
+	api.EntityType_API_KEY:       storage.KindApiKey,
+	api.EntityType_ROLE_BINDING:  storage.KindRoleBinding,
+	api.EntityType_FEATURE_OWNER: storage.KindFeatureOwner,
+}
+
+// store returns the store to make changes through, which records them in the
+// audit log as made by the caller.
+func (s *FeatureToggleServiceServer) store(ctx context.Context) storage.FeatureToggleStore {
+	return s.fs.WithActor(actorOf(ctx))
+}
+
+// actorOf returns the principal of the caller, anonymous when
+// authentication is disabled.
+func actorOf(ctx context.Context) string {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return storage.ANONYMOUS_ACTOR
+	}
+	return principal.Id()
+}
+
+func (s *FeatureToggleServiceServer) SearchAuditLog(ctx context.Context, req *api.SearchAuditLogRequest) (*api.SearchAuditLogResponse, error) {
+	fmt.Printf("SearchAuditLog: %v\n", req)
+
+	if req.Limit < 0 {
+		return nil, invalidArgument("limit", "limit can't be negative")
+	}
+	search := storage.AuditSearch{Kind:entityKinds[req.EntityType], EntityId:req.EntityId, Actor:req.Actor, Limit:int(req.Limit)}
+	if search.Limit == 0 {
+		search.Limit = DEFAULT_AUDIT_LOG_LIMIT
+	}
+	var err error
+	for _, t := range []struct {
+		field string
+		dst   *time.Time
+		src   *timestamp.Timestamp
+	}{
+		{"start", &search.Start, req.Start},
+		{"end", &search.End, req.End},
+	} {
+		*t.dst, err = fromApiTime(t.src)
+		if err != nil {
+			return nil, invalidArgument(t.field, err.Error())
+		}
+	}
+
+	entries, err := s.fs.SearchAuditLog(search)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	response := &api.SearchAuditLogResponse{Entries:[]*api.AuditEntry{}}
+	for _, entry := range *entries {
+		apiEntry, err := toApiAuditEntry(entry)
+		if err != nil {
+			return nil, statusError(err)
+		}
+		response.Entries = append(response.Entries, apiEntry)
+	}
+	return response, nil
+}
+
+func toApiAuditEntry(entry storage.AuditEntry) (*api.AuditEntry, error) {
+	t, err := toApiTime(entry.Time)
+	if err != nil {
+		return nil, err
+	}
+	var entityType api.EntityType
+	for k, kind := range entityKinds {
+		if kind == entry.Kind {
+			entityType = k
+		}
+	}
+	operation := api.AuditEntry_Operation(api.AuditEntry_Operation_value[strings.ToUpper(entry.Operation)])
+	return &api.AuditEntry{Id:entry.Id, Actor:entry.Actor, Time:t, EntityType:entityType, EntityId:entry.EntityId,
+		Operation:operation, Before:entry.Before, After:entry.After}, nil
+}
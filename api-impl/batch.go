@@ -0,0 +1,105 @@
+package feature_toggle_impl
+
+import (
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/golang/glog"
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BATCH_WORKERS is the number of goroutines evaluating the contexts of a batch.
+var BATCH_WORKERS = runtime.NumCPU()
+
+func (s *FeatureToggleServiceServer) BatchGetFeatures(ctx context.Context, req *api.BatchGetFeaturesRequest) (*api.BatchGetFeaturesResponse, error) {
+	tree := s.tree.get()
+	if tree == nil {
+		return nil, status.Error(codes.Unavailable, "Feature toggle service not initialized.")
+	}
+	glog.V(2).Infof("batch: %d contexts", len(req.Contexts))
+
+	b := newBatchEvaluator(tree)
+	for _, c := range req.Contexts {
+		b.add(c.GetProperties())
+	}
+	return &api.BatchGetFeaturesResponse{Results:b.wait()}, nil
+}
+
+// StreamBatchGetFeatures evaluates the contexts as they are received, against
+// the tree of when the stream started.
+func (s *FeatureToggleServiceServer) StreamBatchGetFeatures(stream api.FeatureToggleService_StreamBatchGetFeaturesServer) error {
+	tree := s.tree.get()
+	if tree == nil {
+		return status.Error(codes.Unavailable, "Feature toggle service not initialized.")
+	}
+
+	b := newBatchEvaluator(tree)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			b.wait()
+			return err
+		}
+		b.add(req.Properties)
+	}
+	results := b.wait()
+	glog.V(2).Infof("stream batch: %d contexts", len(results))
+	return stream.SendAndClose(&api.BatchGetFeaturesResponse{Results:results})
+}
+
+type batchJob struct {
+	index      int
+	properties featuretree.Properties
+}
+
+// batchEvaluator finds the features of the contexts added to it concurrently,
+// all against one tree snapshot.
+type batchEvaluator struct {
+	tree    *featuretree.ToggleRuleTree
+	jobs    chan batchJob
+	workers sync.WaitGroup
+	mutex   sync.Mutex
+	results []*api.GetFeaturesByPropertiesResponse
+}
+
+func newBatchEvaluator(tree *featuretree.ToggleRuleTree) *batchEvaluator {
+	b := &batchEvaluator{tree:tree, jobs:make(chan batchJob, BATCH_WORKERS), results:[]*api.GetFeaturesByPropertiesResponse{}}
+	for i := 0; i < BATCH_WORKERS; i++ {
+		b.workers.Add(1)
+		go b.work()
+	}
+	return b
+}
+
+func (b *batchEvaluator) work() {
+	defer b.workers.Done()
+	for job := range b.jobs {
+		res := &api.GetFeaturesByPropertiesResponse{Features:b.tree.FindFeatures(job.properties)}
+		b.mutex.Lock()
+		b.results[job.index] = res
+		b.mutex.Unlock()
+	}
+}
+
+func (b *batchEvaluator) add(properties map[string]string) {
+	b.mutex.Lock()
+	index := len(b.results)
+	b.results = append(b.results, nil)
+	b.mutex.Unlock()
+	b.jobs <- batchJob{index, properties}
+}
+
+// wait returns the results in the order the contexts were added.
+func (b *batchEvaluator) wait() []*api.GetFeaturesByPropertiesResponse {
+	close(b.jobs)
+	b.workers.Wait()
+	return b.results
+}
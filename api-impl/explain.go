@@ -0,0 +1,42 @@
+package feature_toggle_impl
+
+import (
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *FeatureToggleServiceServer) ExplainFeatures(ctx context.Context, req *api.GetFeaturesByPropertiesRequest) (*api.ExplainFeaturesResponse, error) {
+	tree := s.tree.get()
+	if tree == nil {
+		return nil, status.Error(codes.Unavailable, "Feature toggle service not initialized.")
+	}
+	response := new(api.ExplainFeaturesResponse)
+	for _, e := range tree.Explain(req.Properties) {
+		response.Features = append(response.Features, toApiExplanation(e))
+	}
+	return response, nil
+}
+
+func toApiExplanation(e featuretree.Explanation) *api.FeatureExplanation {
+	explanation := &api.FeatureExplanation{Name:e.Feature, Enabled:e.Enabled, WinningRuleId:e.WinnerId, Variant:e.Variant}
+	for _, m := range e.Matched {
+		explanation.MatchedRules = append(explanation.MatchedRules,
+			&api.RuleMatch{RuleId:m.RuleId, Deny:m.Deny, Priority:int32(m.Priority), Path:toApiPath(m.Path)})
+	}
+	for _, m := range e.NearMisses {
+		explanation.NearMisses = append(explanation.NearMisses,
+			&api.NearMiss{RuleId:m.RuleId, Property:m.Property, Expected:m.Expected, Actual:m.Actual, Path:toApiPath(m.Path)})
+	}
+	return explanation
+}
+
+func toApiPath(path []featuretree.PathStep) []*api.PathStep {
+	res := []*api.PathStep{}
+	for _, step := range path {
+		res = append(res, &api.PathStep{Property:step.Property, Value:step.Value})
+	}
+	return res
+}
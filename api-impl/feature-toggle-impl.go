@@ -2,43 +2,84 @@ package feature_toggle_impl
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	api "github.com/peterrosell/feature-toggle-service/api"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"github.com/peterrosell/feature-toggle-service/storage"
-	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"github.com/peterrosell/feature-toggle-service/featuretree"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 )
 
+const DEFAULT_RELOAD_INTERVAL = 30 * time.Second
+
 type FeatureToggleServiceServer struct {
 	fs storage.FeatureToggleStore
-	tree *featuretree.ToggleRuleTree
+	tree treeHolder
 }
 func (s *FeatureToggleServiceServer) GetFeaturesForProperties(ctx context.Context, req *api.GetFeaturesByPropertiesRequest) (*api.GetFeaturesByPropertiesResponse, error){
-	if s.tree == nil {
-		return nil, errors.New("Feature toggle service not initialized.")
+	tree := s.tree.get()
+	if tree == nil {
+		return nil, status.Error(codes.Unavailable, "Feature toggle service not initialized.")
 	}
 	fmt.Printf("getfeat: %v\n", req)
-	return &api.GetFeaturesByPropertiesResponse{Features:s.tree.FindFeatures(req.Properties)}, nil
+	return &api.GetFeaturesByPropertiesResponse{Features:tree.FindFeatures(req.Properties)}, nil
+}
+
+func (s *FeatureToggleServiceServer) EvaluateFeatures(ctx context.Context, req *api.GetFeaturesByPropertiesRequest) (*api.EvaluateFeaturesResponse, error){
+	tree := s.tree.get()
+	if tree == nil {
+		return nil, status.Error(codes.Unavailable, "Feature toggle service not initialized.")
+	}
+	return &api.EvaluateFeaturesResponse{Features:toApiFeatureValues(tree.Evaluate(req.Properties))}, nil
+}
+
+// reloadTree is called after every write so the change is visible to
+// GetFeaturesForProperties at once.
+func (s *FeatureToggleServiceServer) reloadTree() {
+	err := s.tree.reload(s.fs)
+	if err != nil {
+		fmt.Printf("Failed to reload toggle rule tree, %v\n", err)
+	}
+}
+
+// Close stops the periodic reload of the toggle rule tree.
+func (s *FeatureToggleServiceServer) Close() {
+	s.tree.stopReload()
 }
 
 func (s *FeatureToggleServiceServer) CreateToggleRule(ctx context.Context, req *api.CreateToggleRuleRequest) (*api.CreateToggleRuleResponse, error) {
 	fmt.Printf("CreateToggleRule: %v\n", req.ToggleRule)
 
 	feature, err := s.fs.ReadFeatureByName(req.ToggleRule.Name)
+	if err != nil {
+		return nil, statusError(err)
+	}
 	if feature == nil {
-		return nil, errors.New( "Unknown feature")
+		return nil, notFound(storage.KindFeature, req.ToggleRule.Name)
 	}
 
-	propAsSlice := []string{}
-	for k,v := range req.ToggleRule.Properties {
-		propAsSlice = append(propAsSlice, k, v)
+	toggleRule, err := fromApiToggleRule(req.ToggleRule, feature.Id)
+	if err != nil {
+		return nil, statusError(err)
 	}
-
-	ruleId, err := s.fs.CreateToggleRule(*storage.NewToggleRule((*feature).Id, req.ToggleRule.Enabled, propAsSlice...))
+	ruleId, err := s.store(ctx).CreateToggleRule(*toggleRule)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
+	}
+	if feature.Enabled && req.ToggleRule.Enabled {
+		err = s.tree.addRule(featuretree.ToggleRule{Id:*ruleId, Name:feature.Name, Properties:req.ToggleRule.Properties,
+			RolloutPercentage:toggleRule.RolloutPercentage, RolloutProperty:toggleRule.RolloutProperty,
+			Operators:req.ToggleRule.Operators, Deny:toggleRule.Deny, Priority:toggleRule.Priority,
+			Variants:toggleRule.Variants, Starts:toggleRule.Starts, Expires:toggleRule.Expires}.Within(feature.Starts, feature.Expires))
+		if err != nil {
+			fmt.Printf("%s\n", err.Error())
+		}
 	}
 	response := new(api.CreateToggleRuleResponse)
 	response.Id = *ruleId
@@ -49,56 +90,277 @@ func (s *FeatureToggleServiceServer) CreateToggleRule(ctx context.Context, req *
 func (s *FeatureToggleServiceServer) ReadToggleRule(ctx context.Context, req *api.ReadToggleRuleRequest) (*api.ReadToggleRuleResponse, error) {
 	fmt.Printf("ReadToggleRule: id=%s\n", req.Id)
 
-	toggleRule := new(api.ToggleRule)
-	toggleRule.Id = req.Id
-	toggleRule.Name = "extra speed"
+	rule, err := s.fs.ReadToggleRule(req.Id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if rule == nil {
+		return nil, notFound(storage.KindToggleRule, req.Id)
+	}
+	toggleRules, err := s.toApiToggleRules([]storage.ToggleRule{*rule})
+	if err != nil {
+		return nil, statusError(err)
+	}
 	response := new(api.ReadToggleRuleResponse)
-	response.ToggleRule = toggleRule
+	response.ToggleRule = toggleRules[0]
 
 	return response, nil
 }
 
 func (s *FeatureToggleServiceServer) DeleteToggleRule(ctx context.Context, req *api.DeleteToggleRuleRequest) (*api.DeleteToggleRuleResponse, error) {
 	fmt.Printf("DeleteToggleRule: id=%s\n", req.Id)
+
+	version, err := requireVersion(ctx, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.store(ctx).DeleteToggleRule(req.Id, version)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if !*deleted {
+		return nil, notFound(storage.KindToggleRule, req.Id)
+	}
+	s.tree.removeRule(req.Id)
 	return new(api.DeleteToggleRuleResponse), nil
 }
 
 func (s *FeatureToggleServiceServer) SearchToggleRule(ctx context.Context, req *api.SearchToggleRuleRequest) (*api.SearchToggleRuleResponse, error) {
 	fmt.Printf("SearchToggleRule: %s\n", req)
-	toggleRule := new(api.ToggleRule)
-	toggleRule.Name = "Smörrebröd"
 
-	//_, err := s.fs.SearchToggleRule(req.Name, make(Filter))
-	//if err != nil {
-	//	return nil, errors.New( "failed to search for rules")
-	//}
+	search := storage.ToggleRuleSearch{Properties:storage.Filter(req.Properties)}
+	if req.Name != "" {
+		search.Name = &req.Name
+	}
+	if req.Enabled != nil {
+		search.Enabled = &req.Enabled.Value
+	}
+	var err error
+	for _, t := range []struct {
+		field string
+		dst   *time.Time
+		src   *timestamp.Timestamp
+	}{
+		{"createdStart", &search.CreatedStart, req.CreatedStart},
+		{"createdEnd", &search.CreatedEnd, req.CreatedEnd},
+		{"expiresStart", &search.ExpiresStart, req.ExpiresStart},
+		{"expiresEnd", &search.ExpiresEnd, req.ExpiresEnd},
+	} {
+		*t.dst, err = fromApiTime(t.src)
+		if err != nil {
+			return nil, invalidArgument(t.field, err.Error())
+		}
+	}
 
+	rules, err := s.fs.SearchToggleRule(search)
+	if err != nil {
+		return nil, statusError(err)
+	}
 	response := new(api.SearchToggleRuleResponse)
-	//response.ToggleRules = []*api.ToggleRule{ToApiToggleRules(rules)}
+	response.ToggleRules, err = s.toApiToggleRules(*rules)
+	if err != nil {
+		return nil, statusError(err)
+	}
 
 	return response, nil
 }
-/*
-func ToApiToggleRules(rules *[]storage.ToggleRule) []api.ToggleRule{
-	toggleRules := make([]api.ToggleRule, len(rules))
-	for i := 0; i < len(rules); i++ {
-		toggleRules[i] = ToApiToggleRule( rules[0])
+
+func (s *FeatureToggleServiceServer) UpdateToggleRule(ctx context.Context, req *api.UpdateToggleRuleRequest) (*api.UpdateToggleRuleResponse, error) {
+	fmt.Printf("UpdateToggleRule: %v\n", req)
+
+	if req.ToggleRule == nil {
+		return nil, invalidArgument("toggleRule", "toggle rule is missing")
+	}
+	featureId := ""
+	paths, err := updatePaths(req.UpdateMask.GetPaths(), "id")
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 || containsPath(paths, "name") {
+		feature, err := s.fs.ReadFeatureByName(req.ToggleRule.Name)
+		if err != nil {
+			return nil, statusError(err)
+		}
+		if feature == nil {
+			return nil, notFound(storage.KindFeature, req.ToggleRule.Name)
+		}
+		featureId = feature.Id
+	}
+	// the rule refers to its feature by id in the store
+	storePaths := []string{}
+	for _, path := range paths {
+		if path == "name" {
+			path = "featureId"
+		}
+		storePaths = append(storePaths, path)
+	}
+
+	update, err := fromApiToggleRule(req.ToggleRule, featureId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	update.Id = req.ToggleRule.Id
+	update.Version, err = requireVersion(ctx, req.ToggleRule.Version)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := s.store(ctx).UpdateToggleRule(*update, storePaths)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if rule == nil {
+		return nil, notFound(storage.KindToggleRule, req.ToggleRule.Id)
+	}
+	s.reloadTree()
+
+	toggleRules, err := s.toApiToggleRules([]storage.ToggleRule{*rule})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &api.UpdateToggleRuleResponse{ToggleRule:toggleRules[0]}, nil
+}
+
+// updatePaths turns the mask into the fields the store updates. Over REST the
+// gateway builds the mask from the body with a path per leaf, like
+// properties.username, so each path is cut to its field. The key and the
+// version identify what is updated and are left out.
+func updatePaths(paths []string, key string) ([]string, error) {
+	res := []string{}
+	for _, path := range paths {
+		field := strings.SplitN(path, ".", 2)[0]
+		if field == key || field == "version" || containsPath(res, field) {
+			continue
+		}
+		res = append(res, field)
+	}
+	if len(paths) > 0 && len(res) == 0 {
+		// no paths would update every field
+		return nil, invalidArgument("updateMask", "no field to update")
+	}
+	return res, nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func fromApiToggleRule(rule *api.ToggleRule, featureId string) (*storage.ToggleRule, error) {
+	propAsSlice := []string{}
+	for k,v := range rule.Properties {
+		propAsSlice = append(propAsSlice, k, v)
+	}
+
+	toggleRule := storage.NewToggleRule(featureId, rule.Enabled, propAsSlice...)
+	toggleRule.RolloutPercentage = int(rule.RolloutPercentage)
+	toggleRule.RolloutProperty = rule.RolloutProperty
+	toggleRule.Operators = rule.Operators
+	toggleRule.Deny = rule.Deny
+	toggleRule.Priority = int(rule.Priority)
+	toggleRule.Variants = fromApiWeightedVariants(rule.Variants)
+	toggleRule.Version = rule.Version
+	var err error
+	toggleRule.Starts, err = fromApiTime(rule.Starts)
+	if err != nil {
+		return nil, invalidArgument("starts", err.Error())
+	}
+	toggleRule.Expires, err = fromApiTime(rule.Expires)
+	if err != nil {
+		return nil, invalidArgument("expires", err.Error())
+	}
+	return toggleRule, nil
+}
+
+// toApiToggleRules converts rules from the store, looking up the name of
+// each feature once.
+func (s *FeatureToggleServiceServer) toApiToggleRules(rules []storage.ToggleRule) ([]*api.ToggleRule, error) {
+	featureNames := make(map[string]string)
+	toggleRules := []*api.ToggleRule{}
+	for _, rule := range rules {
+		name, ok := featureNames[rule.FeatureId]
+		if !ok {
+			feature, err := s.fs.ReadFeature(rule.FeatureId)
+			if err != nil {
+				return nil, err
+			}
+			if feature != nil {
+				name = feature.Name
+			}
+			featureNames[rule.FeatureId] = name
+		}
+		toggleRule, err := toApiToggleRule(rule, name)
+		if err != nil {
+			return nil, err
+		}
+		toggleRules = append(toggleRules, toggleRule)
+	}
+	return toggleRules, nil
+}
+
+func toApiToggleRule(rule storage.ToggleRule, featureName string) (*api.ToggleRule, error) {
+	created, err := toApiTime(rule.Created)
+	if err != nil {
+		return nil, err
+	}
+	starts, err := toApiTime(rule.Starts)
+	if err != nil {
+		return nil, err
+	}
+	expires, err := toApiTime(rule.Expires)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ToggleRule{
+		Id:rule.Id,
+		Name:featureName,
+		Enabled:rule.Enabled,
+		Created:created,
+		Starts:starts,
+		Expires:expires,
+		Properties:rule.Properties,
+		RolloutPercentage:int32(rule.RolloutPercentage),
+		RolloutProperty:rule.RolloutProperty,
+		Operators:rule.Operators,
+		Deny:rule.Deny,
+		Priority:int32(rule.Priority),
+		Variants:toApiWeightedVariants(rule.Variants),
+		Version:rule.Version,
+	}, nil
+}
+
+// toApiTime returns nil for the zero time, which the store uses for unset times.
+func toApiTime(t time.Time) (*timestamp.Timestamp, error) {
+	if t.IsZero() {
+		return nil, nil
 	}
+	return ptypes.TimestampProto(t)
 }
 
-func ToApiToggleRule(rule storage.ToggleRule) api.ToggleRule {
-	return api.ToggleRule{Id:rule.Id,Name:rule.FeatureId}
+func fromApiTime(ts *timestamp.Timestamp) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, nil
+	}
+	return ptypes.Timestamp(ts)
 }
-*/
 
 func (s *FeatureToggleServiceServer) CreateFeature(ctx context.Context, req *api.CreateFeatureRequest) (*api.CreateFeatureResponse, error) {
 	fmt.Printf("CreateFeature: %v\n", req.Feature)
 	fmt.Printf("CreateFeature: id=%s\n", req.Feature.Name)
 
-	featureId, err := s.fs.CreateFeature(*storage.NewFeature(req.Feature.Name, req.Feature.Enabled, req.Feature.Description))
+	feature, err := fromApiFeature(req.Feature)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
+	}
+	feature.Id = storage.NewFeature(feature.Name, feature.Enabled, feature.Description).Id
+	featureId, err := s.store(ctx).CreateFeature(*feature)
+	if err != nil {
+		return nil, statusError(err)
 	}
+	s.reloadTree()
 	response := new(api.CreateFeatureResponse)
 	response.Id = *featureId
 
@@ -108,45 +370,126 @@ func (s *FeatureToggleServiceServer) CreateFeature(ctx context.Context, req *api
 func (s *FeatureToggleServiceServer) ReadFeature(ctx context.Context, req *api.ReadFeatureRequest) (*api.ReadFeatureResponse, error) {
 	fmt.Printf("ReadFeature: id=%s\n", req.Id)
 
-	feature := new(api.Feature)
-	feature.Id = req.Id
+	feature, err := s.fs.ReadFeature(req.Id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if feature == nil {
+		return nil, notFound(storage.KindFeature, req.Id)
+	}
 	response := new(api.ReadFeatureResponse)
-	response.Feature = feature
+	response.Feature = toApiFeature(*feature)
 
 	return response, nil
 }
 
 func (s *FeatureToggleServiceServer) DeleteFeature(ctx context.Context, req *api.DeleteFeatureRequest) (*api.DeleteFeatureResponse, error) {
 	fmt.Printf("DeleteFeature: id=%s\n", req.Id)
+
+	version, err := requireVersion(ctx, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.store(ctx).DeleteFeature(req.Id, version)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if !*deleted {
+		return nil, notFound(storage.KindFeature, req.Id)
+	}
+	s.reloadTree()
 	return new(api.DeleteFeatureResponse), nil
 }
 
 func (s *FeatureToggleServiceServer) SearchFeature(ctx context.Context, req *api.SearchFeatureRequest) (*api.SearchFeatureResponse, error) {
-	/*
-	if( req.Filter != nil) {
-		fmt.Printf("SearchFeature: %s\n", req.Filter.Name)
-	} else {
-		fmt.Printf("SearchFeature filter=nil: %v\n", req)
-	}
-	*/
-	fmt.Printf("SearchFeature name=: %v\n", req.Name)
-	feature := new(api.Feature)
-	feature.Name = "Smörrebröd"
+	fmt.Printf("SearchFeature: %v\n", req)
 
+	search := storage.FeatureSearch{Name:req.Name}
+	if req.Enabled != nil {
+		search.Enabled = &req.Enabled.Value
+	}
+	features, err := s.fs.SearchFeature(search)
+	if err != nil {
+		return nil, statusError(err)
+	}
 	response := new(api.SearchFeatureResponse)
-	response.Features = []*api.Feature{feature}
+	response.Features = []*api.Feature{}
+	for _, feature := range *features {
+		response.Features = append(response.Features, toApiFeature(feature))
+	}
 
 	return response, nil
 }
 
+func (s *FeatureToggleServiceServer) UpdateFeature(ctx context.Context, req *api.UpdateFeatureRequest) (*api.UpdateFeatureResponse, error) {
+	fmt.Printf("UpdateFeature: %v\n", req)
+
+	if req.Feature == nil {
+		return nil, invalidArgument("feature", "feature is missing")
+	}
+	update, err := fromApiFeature(req.Feature)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	update.Version, err = requireVersion(ctx, req.Feature.Version)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := updatePaths(req.UpdateMask.GetPaths(), "id")
+	if err != nil {
+		return nil, err
+	}
+	feature, err := s.store(ctx).UpdateFeature(*update, paths)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if feature == nil {
+		return nil, notFound(storage.KindFeature, req.Feature.Id)
+	}
+	s.reloadTree()
+	return &api.UpdateFeatureResponse{Feature:toApiFeature(*feature)}, nil
+}
+
+func fromApiFeature(feature *api.Feature) (*storage.Feature, error) {
+	starts, err := fromApiTime(feature.Starts)
+	if err != nil {
+		return nil, invalidArgument("starts", err.Error())
+	}
+	expires, err := fromApiTime(feature.Expires)
+	if err != nil {
+		return nil, invalidArgument("expires", err.Error())
+	}
+	return &storage.Feature{Id:feature.Id, Name:feature.Name, Enabled:feature.Enabled,
+		Description:feature.Description, Variants:fromApiVariants(feature.Variants),
+		DefaultVariant:feature.DefaultVariant, Starts:starts, Expires:expires, Version:feature.Version}, nil
+}
+
+func toApiFeature(feature storage.Feature) *api.Feature {
+	// valid times, they were read from the store
+	starts, _ := toApiTime(feature.Starts)
+	expires, _ := toApiTime(feature.Expires)
+	return &api.Feature{
+		Id:feature.Id,
+		Name:feature.Name,
+		Enabled:feature.Enabled,
+		Description:feature.Description,
+		Variants:toApiVariants(feature.Variants),
+		DefaultVariant:feature.DefaultVariant,
+		Starts:starts,
+		Expires:expires,
+		Version:feature.Version,
+	}
+}
+
 func (s *FeatureToggleServiceServer) CreateProperty(ctx context.Context, req *api.CreatePropertyRequest) (*api.CreatePropertyResponse, error) {
 	fmt.Printf("CreateProperty: %v\n", req.Property)
 	fmt.Printf("CreateProperty: id=%s\n", req.Property.Name)
 
-	propertyId, err := s.fs.CreateProperty(*storage.NewProperty(req.Property.Name, req.Property.Description))
+	propertyId, err := s.store(ctx).CreateProperty(*storage.NewProperty(req.Property.Name, req.Property.Description))
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
 	}
+	s.reloadTree()
 
 	response := new(api.CreatePropertyResponse)
 	response.Name = *propertyId
@@ -157,61 +500,115 @@ func (s *FeatureToggleServiceServer) CreateProperty(ctx context.Context, req *ap
 func (s *FeatureToggleServiceServer) ReadProperty(ctx context.Context, req *api.ReadPropertyRequest) (*api.ReadPropertyResponse, error) {
 	fmt.Printf("ReadProperty: id=%s\n", req.Name)
 
-	property := new(api.Property)
-	property.Name = req.Name
+	property, err := s.fs.ReadProperty(req.Name)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if property == nil {
+		return nil, notFound(storage.KindProperty, req.Name)
+	}
 	response := new(api.ReadPropertyResponse)
-	response.Property = property
+	response.Property = toApiProperty(*property)
 
 	return response, nil
 }
 
 func (s *FeatureToggleServiceServer) DeleteProperty(ctx context.Context, req *api.DeletePropertyRequest) (*api.DeletePropertyResponse, error) {
 	fmt.Printf("DeleteProperty: id=%s\n", req.Name)
+
+	version, err := requireVersion(ctx, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.store(ctx).DeleteProperty(req.Name, version)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if !*deleted {
+		return nil, notFound(storage.KindProperty, req.Name)
+	}
+	s.reloadTree()
 	return new(api.DeletePropertyResponse), nil
 }
 
 func (s *FeatureToggleServiceServer) SearchProperty(ctx context.Context, req *api.SearchPropertyRequest) (*api.SearchPropertyResponse, error) {
-	fmt.Printf("SearchFeature: %s\n", req.Name)
-	property := new(api.Property)
-	property.Name = "usertype"
+	fmt.Printf("SearchProperty: %s\n", req.Name)
 
+	properties, err := s.fs.SearchProperty(req.Name)
+	if err != nil {
+		return nil, statusError(err)
+	}
 	response := new(api.SearchPropertyResponse)
-	response.Properties = []*api.Property{property}
+	response.Properties = []*api.Property{}
+	for _, property := range *properties {
+		response.Properties = append(response.Properties, toApiProperty(property))
+	}
 
 	return response, nil
 }
 
-func newFeatureToggleServiceServer() *FeatureToggleServiceServer {
-	s := new(FeatureToggleServiceServer)
-	s.fs = storage.NewFeatureToggleStoreImpl()
-	s.fs.Open()
+func (s *FeatureToggleServiceServer) UpdateProperty(ctx context.Context, req *api.UpdatePropertyRequest) (*api.UpdatePropertyResponse, error) {
+	fmt.Printf("UpdateProperty: %v\n", req)
 
-	toggleRules, err := s.fs.GetEnabledToggleRules()
-	if( toggleRules != nil) {
-		propertyNames, err := s.fs.ReadAllPropertyNames();
-		if err != nil {
-			fmt.Printf("Failed to read all properties, %v\n", err)
-			panic(err.Error())
-		}
-		tree := featuretree.NewFeatureTree(*propertyNames)
+	if req.Property == nil {
+		return nil, invalidArgument("property", "property is missing")
+	}
+	update := storage.NewProperty(req.Property.Name, req.Property.Description)
+	var err error
+	update.Version, err = requireVersion(ctx, req.Property.Version)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := updatePaths(req.UpdateMask.GetPaths(), "name")
+	if err != nil {
+		return nil, err
+	}
+	property, err := s.store(ctx).UpdateProperty(*update, paths)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if property == nil {
+		return nil, notFound(storage.KindProperty, req.Property.Name)
+	}
+	return &api.UpdatePropertyResponse{Property:toApiProperty(*property)}, nil
+}
 
-		for _, rule := range *toggleRules {
-			err := tree.AddFeature(rule)
-			if err != nil {
-				fmt.Printf("%s\n", err.Error())
-			} else {
-				fmt.Printf("Added rule: %v\n", rule)
-			}
-		}
-		s.tree = tree
-		fmt.Print(s.tree.String())
-	} else {
+func toApiProperty(property storage.Property) *api.Property {
+	return &api.Property{Name:property.Name, Description:property.Description, Version:property.Version}
+}
+
+func newFeatureToggleServiceServer(fs storage.FeatureToggleStore, reloadInterval time.Duration) *FeatureToggleServiceServer {
+	s := new(FeatureToggleServiceServer)
+	s.fs = fs
+	err := s.fs.Open()
+	if err != nil {
+		fmt.Printf("Failed to open feature toggle store, %v\n", err)
+		panic(err.Error())
+	}
+
+	err = s.tree.reload(s.fs)
+	if err != nil {
 		fmt.Printf("Failed to init feature toggle service, %v\n", err)
 		panic(err.Error())
 	}
+	fmt.Print(s.tree.get().String())
+
+	if reloadInterval > 0 {
+		s.tree.reloadEvery(s.fs, reloadInterval)
+	}
 	return s
 }
 
 func RegisterFeatureToggleService(s *grpc.Server) {
-	api.RegisterFeatureToggleServiceServer(s, newFeatureToggleServiceServer())
+	RegisterFeatureToggleServiceWithStore(s, storage.NewFeatureToggleStoreImpl(storage.DBConfigFromEnv()), DEFAULT_RELOAD_INTERVAL)
+}
+
+// RegisterFeatureToggleServiceWithStore registers the service backed by the
+// given store. The toggle rule tree is rebuilt after every write and every
+// reloadInterval, 0 disables the periodic reload. Close the returned server
+// to stop the periodic reload.
+func RegisterFeatureToggleServiceWithStore(s *grpc.Server, fs storage.FeatureToggleStore, reloadInterval time.Duration) *FeatureToggleServiceServer {
+	server := newFeatureToggleServiceServer(fs, reloadInterval)
+	api.RegisterFeatureToggleServiceServer(s, server)
+	return server
 }
@@ -0,0 +1,674 @@
+package feature_toggle_impl
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/auth"
+	"github.com/peterrosell/feature-toggle-service/storage"
+)
+
+func newTestServer(t *testing.T) *FeatureToggleServiceServer {
+	fs := storage.NewFeatureToggleMemStore()
+	s := newFeatureToggleServiceServer(fs, 0)
+
+	_, err := s.CreateProperty(context.Background(), &api.CreatePropertyRequest{Property: &api.Property{Name: "username"}})
+	require.Nil(t, err, "Should create property, %v", err)
+	_, err = s.CreateFeature(context.Background(), &api.CreateFeatureRequest{Feature: &api.Feature{Name: "feature 1", Enabled: true}})
+	require.Nil(t, err, "Should create feature, %v", err)
+	return s
+}
+
+func TestCreateToggleRule_reloads_tree(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	req := &api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": "adam"}}
+
+	res, err := s.GetFeaturesForProperties(ctx, req)
+	require.Nil(t, err)
+	assert.Empty(t, res.Features, "Should not find any features before the rule is created")
+
+	_, err = s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err, "Should create toggle rule, %v", err)
+
+	res, err = s.GetFeaturesForProperties(ctx, req)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"feature 1"}, res.Features, "Should find the feature right after the rule is created")
+}
+
+func TestReloadEvery_picks_up_store_changes(t *testing.T) {
+	fs := storage.NewFeatureToggleMemStore()
+	fs.CreateProperty(*storage.NewProperty("username", ""))
+	feature := storage.NewFeature("feature 1", true, "")
+	fs.CreateFeature(*feature)
+
+	s := newFeatureToggleServiceServer(fs, 10 * time.Millisecond)
+	defer s.Close()
+
+	// written directly to the store, not through the service
+	fs.CreateToggleRule(*storage.NewToggleRule(feature.Id, true, "username", "adam"))
+
+	found := false
+	for i := 0; i < 100 && !found; i++ {
+		time.Sleep(10 * time.Millisecond)
+		found = len(s.tree.get().FindFeatures(map[string]string{"username": "adam"})) > 0
+	}
+	assert.True(t, found, "Should find the feature after the periodic reload")
+}
+
+func TestEvaluateFeatures(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	_, err := s.CreateFeature(ctx, &api.CreateFeatureRequest{Feature: &api.Feature{Name: "color", Enabled: true,
+		Variants: []*api.Variant{{Name: "red", Type: api.VariantType_STRING, Value: "#f00"},
+			{Name: "config", Type: api.VariantType_JSON, Value: `{"color": "blue"}`}}}})
+	require.Nil(t, err, "Should create feature, %v", err)
+	_, err = s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "color", Enabled: true, Properties: map[string]string{"username": "adam"},
+		Variants: []*api.WeightedVariant{{Variant: "config", Weight: 1}}}})
+	require.Nil(t, err, "Should create toggle rule, %v", err)
+
+	res, err := s.EvaluateFeatures(ctx, &api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": "adam"}})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(res.Features))
+	assert.Equal(t, "config", res.Features[0].Variant)
+	assert.Equal(t, api.VariantType_JSON, res.Features[0].Type)
+	assert.Equal(t, `{"color": "blue"}`, res.Features[0].Value)
+}
+
+func TestExplainFeatures(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	res, err := s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err, "Should create toggle rule, %v", err)
+
+	explained, err := s.ExplainFeatures(ctx, &api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": "bertil"}})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(explained.Features))
+	assert.False(t, explained.Features[0].Enabled)
+	require.Equal(t, 1, len(explained.Features[0].NearMisses))
+	miss := explained.Features[0].NearMisses[0]
+	assert.Equal(t, res.Id, miss.RuleId)
+	assert.Equal(t, "username", miss.Property)
+	assert.Equal(t, "adam", miss.Expected)
+	assert.Equal(t, "bertil", miss.Actual)
+
+	explained, err = s.ExplainFeatures(ctx, &api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": "adam"}})
+	require.Nil(t, err)
+	assert.True(t, explained.Features[0].Enabled)
+	assert.Equal(t, res.Id, explained.Features[0].WinningRuleId)
+	assert.Equal(t, "adam", explained.Features[0].MatchedRules[0].Path[0].Value)
+}
+
+func TestReadDeleteSearchToggleRule(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour).UTC()
+	expiresProto, _ := ptypes.TimestampProto(expires)
+
+	created, err := s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Expires: expiresProto, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err, "Should create toggle rule, %v", err)
+	_, err = s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: false, Properties: map[string]string{"username": "bertil"}}})
+	require.Nil(t, err, "Should create toggle rule, %v", err)
+
+	read, err := s.ReadToggleRule(ctx, &api.ReadToggleRuleRequest{Id: created.Id})
+	require.Nil(t, err)
+	assert.Equal(t, "feature 1", read.ToggleRule.Name)
+	assert.Equal(t, map[string]string{"username": "adam"}, read.ToggleRule.Properties)
+	assert.NotNil(t, read.ToggleRule.Created, "Should have a created time")
+	readExpires, _ := ptypes.Timestamp(read.ToggleRule.Expires)
+	assert.True(t, expires.Equal(readExpires), "Should keep the expiry time")
+
+	searched, err := s.SearchToggleRule(ctx, &api.SearchToggleRuleRequest{Name: "feature 1", Enabled: &wrappers.BoolValue{Value: false}})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(searched.ToggleRules))
+	assert.Equal(t, "bertil", searched.ToggleRules[0].Properties["username"])
+
+	searched, err = s.SearchToggleRule(ctx, &api.SearchToggleRuleRequest{ExpiresStart: ptypes.TimestampNow()})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(searched.ToggleRules), "Only one rule expires")
+	assert.Equal(t, created.Id, searched.ToggleRules[0].Id)
+
+	searched, err = s.SearchToggleRule(ctx, &api.SearchToggleRuleRequest{Properties: map[string]string{"username": "adam"}})
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(searched.ToggleRules))
+
+	_, err = s.DeleteToggleRule(ctx, &api.DeleteToggleRuleRequest{Id: created.Id, Version: read.ToggleRule.Version})
+	require.Nil(t, err)
+	_, err = s.ReadToggleRule(ctx, &api.ReadToggleRuleRequest{Id: created.Id})
+	assert.NotNil(t, err, "Should not find a deleted rule")
+	_, err = s.DeleteToggleRule(ctx, &api.DeleteToggleRuleRequest{Id: created.Id, Version: read.ToggleRule.Version})
+	assert.NotNil(t, err, "Should not delete an unknown rule")
+
+	res, err := s.GetFeaturesForProperties(ctx, &api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": "adam"}})
+	require.Nil(t, err)
+	assert.Empty(t, res.Features, "The deleted rule should be removed from the tree")
+}
+
+func TestReadDeleteSearchFeatureAndProperty(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	created, err := s.CreateFeature(ctx, &api.CreateFeatureRequest{Feature: &api.Feature{Name: "Feature 2", Description: "second"}})
+	require.Nil(t, err, "Should create feature, %v", err)
+
+	read, err := s.ReadFeature(ctx, &api.ReadFeatureRequest{Id: created.Id})
+	require.Nil(t, err)
+	assert.Equal(t, "Feature 2", read.Feature.Name)
+	assert.Equal(t, "second", read.Feature.Description)
+
+	searched, err := s.SearchFeature(ctx, &api.SearchFeatureRequest{Name: "FEATURE"})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(searched.Features))
+	assert.Equal(t, "Feature 2", searched.Features[0].Name, "Should be ordered by name")
+
+	searched, err = s.SearchFeature(ctx, &api.SearchFeatureRequest{Enabled: &wrappers.BoolValue{Value: true}})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(searched.Features))
+	assert.Equal(t, "feature 1", searched.Features[0].Name)
+
+	_, err = s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "Feature 2", Enabled: true, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err, "Should create toggle rule, %v", err)
+	_, err = s.DeleteFeature(ctx, &api.DeleteFeatureRequest{Id: created.Id, Version: read.Feature.Version})
+	assert.NotNil(t, err, "Should not delete a feature with toggle rules")
+	_, err = s.DeleteProperty(ctx, &api.DeletePropertyRequest{Name: "username", Version: 1})
+	assert.NotNil(t, err, "Should not delete a property used by toggle rules")
+
+	property, err := s.ReadProperty(ctx, &api.ReadPropertyRequest{Name: "username"})
+	require.Nil(t, err)
+	assert.Equal(t, "username", property.Property.Name)
+
+	_, err = s.CreateProperty(ctx, &api.CreatePropertyRequest{Property: &api.Property{Name: "country"}})
+	require.Nil(t, err)
+	properties, err := s.SearchProperty(ctx, &api.SearchPropertyRequest{Name: "COUNT"})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(properties.Properties))
+	assert.Equal(t, "country", properties.Properties[0].Name)
+
+	_, err = s.DeleteProperty(ctx, &api.DeletePropertyRequest{Name: "country", Version: properties.Properties[0].Version})
+	require.Nil(t, err)
+	_, err = s.ReadProperty(ctx, &api.ReadPropertyRequest{Name: "country"})
+	assert.NotNil(t, err, "Should not find a deleted property")
+	_, err = s.DeleteProperty(ctx, &api.DeletePropertyRequest{Name: "country", Version: properties.Properties[0].Version})
+	assert.NotNil(t, err, "Should not delete an unknown property")
+}
+
+func TestUpdateFeatureAndToggleRule(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	req := &api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": "bertil"}}
+
+	created, err := s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err, "Should create toggle rule, %v", err)
+
+	updated, err := s.UpdateToggleRule(ctx, &api.UpdateToggleRuleRequest{
+		ToggleRule: &api.ToggleRule{Id: created.Id, Properties: map[string]string{"username": "bertil"}, Version: 1},
+		UpdateMask: &field_mask.FieldMask{Paths: []string{"properties"}}})
+	require.Nil(t, err, "Should update toggle rule, %v", err)
+	assert.Equal(t, created.Id, updated.ToggleRule.Id, "Should keep the id")
+	assert.Equal(t, "feature 1", updated.ToggleRule.Name)
+	assert.True(t, updated.ToggleRule.Enabled)
+
+	res, err := s.GetFeaturesForProperties(ctx, req)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"feature 1"}, res.Features, "Should find the feature with the new properties")
+
+	features, _ := s.SearchFeature(ctx, &api.SearchFeatureRequest{Name: "feature 1"})
+	updatedFeature, err := s.UpdateFeature(ctx, &api.UpdateFeatureRequest{
+		Feature: &api.Feature{Id: features.Features[0].Id, Name: "feature one", Description: "renamed", Version: features.Features[0].Version},
+		UpdateMask: &field_mask.FieldMask{Paths: []string{"name", "description"}}})
+	require.Nil(t, err, "Should update feature, %v", err)
+	assert.Equal(t, "feature one", updatedFeature.Feature.Name)
+	assert.True(t, updatedFeature.Feature.Enabled, "Should not change fields outside the mask")
+
+	res, err = s.GetFeaturesForProperties(ctx, req)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"feature one"}, res.Features, "Should find the renamed feature")
+
+	_, err = s.UpdateFeature(ctx, &api.UpdateFeatureRequest{
+		Feature: &api.Feature{Id: features.Features[0].Id, Version: updatedFeature.Feature.Version}, UpdateMask: &field_mask.FieldMask{Paths: []string{"enabled"}}})
+	require.Nil(t, err)
+	res, err = s.GetFeaturesForProperties(ctx, req)
+	require.Nil(t, err)
+	assert.Empty(t, res.Features, "Should not find a disabled feature")
+
+	property, err := s.UpdateProperty(ctx, &api.UpdatePropertyRequest{Property: &api.Property{Name: "username", Description: "login", Version: 1}})
+	require.Nil(t, err, "Should update property, %v", err)
+	assert.Equal(t, "login", property.Property.Description)
+}
+
+func TestUpdateToggleRule_rest_patch(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	mux := runtime.NewServeMux()
+	require.Nil(t, api.RegisterFeatureToggleServiceHandlerServer(ctx, mux, s))
+
+	created, err := s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err, "Should create toggle rule, %v", err)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("PATCH", "/togglerule/"+created.Id,
+		strings.NewReader(`{"properties":{"username":"bertil"},"version":"1"}`)))
+	require.Equal(t, http.StatusOK, rec.Code, "Should update the properties, %s", rec.Body.String())
+
+	rule, err := s.ReadToggleRule(ctx, &api.ReadToggleRuleRequest{Id: created.Id})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"username": "bertil"}, rule.ToggleRule.Properties)
+	assert.True(t, rule.ToggleRule.Enabled, "Should not change fields outside the body")
+
+	features, _ := s.SearchFeature(ctx, &api.SearchFeatureRequest{Name: "feature 1"})
+	feature := features.Features[0]
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("PATCH", "/feature/"+feature.Id,
+		strings.NewReader(fmt.Sprintf(`{"description":"patched","version":"%d"}`, feature.Version))))
+	require.Equal(t, http.StatusOK, rec.Code, "Should update the description, %s", rec.Body.String())
+
+	features, _ = s.SearchFeature(ctx, &api.SearchFeatureRequest{Name: "feature 1"})
+	assert.Equal(t, "patched", features.Features[0].Description)
+	assert.True(t, features.Features[0].Enabled, "Should not change fields outside the body")
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("PATCH", "/feature/"+feature.Id,
+		strings.NewReader(fmt.Sprintf(`{"version":"%d"}`, features.Features[0].Version))))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "Should not update every field without one in the body")
+}
+
+func TestStatusCodes(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	_, err := s.ReadFeature(ctx, &api.ReadFeatureRequest{Id: "unknown"})
+	st := status.Convert(err)
+	assert.Equal(t, codes.NotFound, st.Code())
+	require.Equal(t, 1, len(st.Details()))
+	resource := st.Details()[0].(*errdetails.ResourceInfo)
+	assert.Equal(t, "feature", resource.ResourceType)
+	assert.Equal(t, "unknown", resource.ResourceName)
+
+	_, err = s.CreateFeature(ctx, &api.CreateFeatureRequest{Feature: &api.Feature{Name: "feature 1"}})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}, RolloutPercentage: 120}})
+	st = status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	require.Equal(t, 1, len(st.Details()))
+	badRequest := st.Details()[0].(*errdetails.BadRequest)
+	assert.Equal(t, "rolloutPercentage", badRequest.FieldViolations[0].Field)
+
+	_, err = s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err)
+	_, err = s.DeleteProperty(ctx, &api.DeletePropertyRequest{Name: "username", Version: 1})
+	st = status.Convert(err)
+	assert.Equal(t, codes.FailedPrecondition, st.Code())
+	precondition := st.Details()[0].(*errdetails.PreconditionFailure)
+	assert.Equal(t, "property/username", precondition.Violations[0].Subject)
+}
+
+func TestVersions(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	features, _ := s.SearchFeature(ctx, &api.SearchFeatureRequest{Name: "feature 1"})
+	feature := features.Features[0]
+	assert.Equal(t, int64(1), feature.Version)
+
+	_, err := s.UpdateFeature(ctx, &api.UpdateFeatureRequest{
+		Feature: &api.Feature{Id: feature.Id}, UpdateMask: &field_mask.FieldMask{Paths: []string{"enabled"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "Should require a version")
+
+	ifMatch := metadata.NewIncomingContext(ctx, metadata.Pairs(IF_MATCH_METADATA, `"1"`))
+	updated, err := s.UpdateFeature(ifMatch, &api.UpdateFeatureRequest{
+		Feature: &api.Feature{Id: feature.Id, Description: "new"}, UpdateMask: &field_mask.FieldMask{Paths: []string{"description"}}})
+	require.Nil(t, err, "Should take the version from If-Match, %v", err)
+	assert.Equal(t, int64(2), updated.Feature.Version)
+
+	_, err = s.DeleteProperty(ifMatch, &api.DeletePropertyRequest{Name: "username", Version: 2})
+	st := status.Convert(err)
+	assert.Equal(t, codes.FailedPrecondition, st.Code(), "Should refuse a stale version")
+	precondition := st.Details()[0].(*errdetails.PreconditionFailure)
+	assert.Equal(t, VERSION_VIOLATION, precondition.Violations[0].Type)
+
+	_, err = s.UpdateFeature(ifMatch, &api.UpdateFeatureRequest{
+		Feature: &api.Feature{Id: feature.Id}, UpdateMask: &field_mask.FieldMask{Paths: []string{"enabled"}}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	invalid := metadata.NewIncomingContext(ctx, metadata.Pairs(IF_MATCH_METADATA, "*"))
+	_, err = s.DeleteFeature(invalid, &api.DeleteFeatureRequest{Id: feature.Id})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestParseETag(t *testing.T) {
+	version, err := ParseETag(ETag(42))
+	require.Nil(t, err)
+	assert.Equal(t, int64(42), version)
+	version, err = ParseETag(`W/"7"`)
+	require.Nil(t, err, "Should accept weak tags")
+	assert.Equal(t, int64(7), version)
+
+	for _, tag := range []string{"7", `"seven"`, `"0"`, `"`} {
+		_, err = ParseETag(tag)
+		assert.NotNil(t, err, "Should refuse %s", tag)
+	}
+}
+
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *api.GetFeaturesByPropertiesResponse
+}
+
+func (w *watchStream) Context() context.Context {
+	return w.ctx
+}
+
+func (w *watchStream) Send(res *api.GetFeaturesByPropertiesResponse) error {
+	w.sent <- res
+	return nil
+}
+
+func TestWatchFeatures(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &watchStream{ctx: ctx, sent: make(chan *api.GetFeaturesByPropertiesResponse, 10)}
+	done := make(chan error)
+	go func() {
+		done <- s.WatchFeatures(&api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": "adam"}}, stream)
+	}()
+	next := func() *api.GetFeaturesByPropertiesResponse {
+		select {
+		case res := <-stream.sent:
+			return res
+		case <-time.After(time.Second):
+			require.Fail(t, "Should get a message")
+			return nil
+		}
+	}
+
+	assert.Empty(t, next().Features, "Should send the initial features")
+
+	_, err := s.CreateProperty(context.Background(), &api.CreatePropertyRequest{Property: &api.Property{Name: "country"}})
+	require.Nil(t, err)
+	created, err := s.CreateToggleRule(context.Background(), &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"feature 1"}, next().Features, "Should only send when the features change")
+
+	_, err = s.DeleteToggleRule(context.Background(), &api.DeleteToggleRuleRequest{Id: created.Id, Version: 1})
+	require.Nil(t, err)
+	assert.Empty(t, next().Features)
+
+	cancel()
+	select {
+	case err = <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "Should stop when the client is gone")
+	}
+}
+
+func TestBatchGetFeatures(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	_, err := s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "user-7"}}})
+	require.Nil(t, err)
+
+	req := &api.BatchGetFeaturesRequest{}
+	for i := 0; i < 100; i++ {
+		req.Contexts = append(req.Contexts, &api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": fmt.Sprintf("user-%d", i)}})
+	}
+	res, err := s.BatchGetFeatures(ctx, req)
+	require.Nil(t, err)
+	require.Equal(t, 100, len(res.Results), "Should get a result per context")
+	for i, r := range res.Results {
+		if i == 7 {
+			assert.Equal(t, []string{"feature 1"}, r.Features, "Should be in the order of the request")
+		} else {
+			assert.Empty(t, r.Features)
+		}
+	}
+}
+
+type batchStream struct {
+	grpc.ServerStream
+	contexts []*api.GetFeaturesByPropertiesRequest
+	res      *api.BatchGetFeaturesResponse
+}
+
+func (b *batchStream) Recv() (*api.GetFeaturesByPropertiesRequest, error) {
+	if len(b.contexts) == 0 {
+		return nil, io.EOF
+	}
+	req := b.contexts[0]
+	b.contexts = b.contexts[1:]
+	return req, nil
+}
+
+func (b *batchStream) SendAndClose(res *api.BatchGetFeaturesResponse) error {
+	b.res = res
+	return nil
+}
+
+func TestStreamBatchGetFeatures(t *testing.T) {
+	s := newTestServer(t)
+	_, err := s.CreateToggleRule(context.Background(), &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err)
+
+	stream := &batchStream{contexts: []*api.GetFeaturesByPropertiesRequest{
+		{Properties: map[string]string{"username": "adam"}},
+		{Properties: map[string]string{"username": "bertil"}},
+		{Properties: map[string]string{"username": "adam"}},
+	}}
+	err = s.StreamBatchGetFeatures(stream)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(stream.res.Results))
+	assert.Equal(t, []string{"feature 1"}, stream.res.Results[0].Features)
+	assert.Empty(t, stream.res.Results[1].Features)
+	assert.Equal(t, []string{"feature 1"}, stream.res.Results[2].Features)
+}
+
+func TestSchedule_activates_rules_on_time(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	ctx := context.Background()
+	req := &api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": "adam"}}
+	now := time.Now()
+	starts, _ := ptypes.TimestampProto(now.Add(100 * time.Millisecond))
+	expires, _ := ptypes.TimestampProto(now.Add(300 * time.Millisecond))
+
+	_, err := s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}, Starts: expires, Expires: starts}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "Should refuse to expire before it starts")
+
+	changed, cancel := s.tree.watch()
+	defer cancel()
+	created, err := s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}, Starts: starts, Expires: expires}})
+	require.Nil(t, err)
+	<-changed
+
+	schedule, err := s.GetSchedule(ctx, &api.GetScheduleRequest{})
+	require.Nil(t, err)
+	require.Len(t, schedule.Changes, 2)
+	first := schedule.Changes[0]
+	assert.Equal(t, starts.Seconds, first.Time.Seconds)
+	assert.Equal(t, starts.Nanos, first.Time.Nanos)
+	assert.Equal(t, created.Id, first.ToggleRuleId)
+	assert.Equal(t, "feature 1", first.Feature)
+	assert.Equal(t, api.ScheduledChange_ACTIVATE, first.Action)
+	assert.Equal(t, api.ScheduledChange_DEACTIVATE, schedule.Changes[1].Action)
+	until, _ := ptypes.TimestampProto(now.Add(200 * time.Millisecond))
+	schedule, err = s.GetSchedule(ctx, &api.GetScheduleRequest{Until: until})
+	require.Nil(t, err)
+	assert.Len(t, schedule.Changes, 1, "Should only get the changes until")
+
+	res, err := s.GetFeaturesForProperties(ctx, req)
+	require.Nil(t, err)
+	assert.Empty(t, res.Features, "Should not be active before it starts")
+
+	waitForChange := func() {
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+			require.Fail(t, "Should swap the tree")
+		}
+	}
+	waitForChange()
+	assert.False(t, time.Now().Before(now.Add(100 * time.Millisecond)), "Should not activate early")
+	res, err = s.GetFeaturesForProperties(ctx, req)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"feature 1"}, res.Features, "Should be active once it starts")
+
+	waitForChange()
+	assert.False(t, time.Now().Before(now.Add(300 * time.Millisecond)), "Should not expire early")
+	res, err = s.GetFeaturesForProperties(ctx, req)
+	require.Nil(t, err)
+	assert.Empty(t, res.Features, "Should not be active once it expires")
+	schedule, err = s.GetSchedule(ctx, &api.GetScheduleRequest{})
+	require.Nil(t, err)
+	assert.Empty(t, schedule.Changes)
+}
+
+func TestRoleBindingsAndFeatureOwners(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	_, err := s.SetRoleBinding(ctx, &api.SetRoleBindingRequest{RoleBinding: &api.RoleBinding{Principal: "jwt:adam", Role: api.Role_EDITOR}})
+	require.Nil(t, err, "Should set role binding, %v", err)
+	_, err = s.SetRoleBinding(ctx, &api.SetRoleBindingRequest{RoleBinding: &api.RoleBinding{Principal: "adam", Role: api.Role_EDITOR}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "Should require the authentication method in the principal")
+
+	bindings, err := s.ListRoleBindings(ctx, &api.ListRoleBindingsRequest{})
+	require.Nil(t, err)
+	require.Len(t, bindings.RoleBindings, 1)
+	assert.Equal(t, "jwt:adam", bindings.RoleBindings[0].Principal)
+	assert.Equal(t, api.Role_EDITOR, bindings.RoleBindings[0].Role)
+
+	_, err = s.DeleteRoleBinding(ctx, &api.DeleteRoleBindingRequest{Principal: "jwt:adam"})
+	require.Nil(t, err)
+	_, err = s.DeleteRoleBinding(ctx, &api.DeleteRoleBindingRequest{Principal: "jwt:adam"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	features, _ := s.SearchFeature(ctx, &api.SearchFeatureRequest{Name: "feature 1"})
+	featureId := features.Features[0].Id
+	_, err = s.AddFeatureOwner(ctx, &api.FeatureOwnerRequest{FeatureId: featureId, Principal: "jwt:adam"})
+	require.Nil(t, err, "Should add feature owner, %v", err)
+	_, err = s.AddFeatureOwner(ctx, &api.FeatureOwnerRequest{FeatureId: "unknown", Principal: "jwt:adam"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	owners, err := s.ListFeatureOwners(ctx, &api.ListFeatureOwnersRequest{FeatureId: featureId})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"jwt:adam"}, owners.Principals)
+
+	_, err = s.RemoveFeatureOwner(ctx, &api.FeatureOwnerRequest{FeatureId: featureId, Principal: "jwt:adam"})
+	require.Nil(t, err)
+	_, err = s.RemoveFeatureOwner(ctx, &api.FeatureOwnerRequest{FeatureId: featureId, Principal: "jwt:adam"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestSearchAuditLog(t *testing.T) {
+	s := newTestServer(t)
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Name: "adam", Method: auth.MethodJWT})
+
+	features, _ := s.SearchFeature(ctx, &api.SearchFeatureRequest{Name: "feature 1"})
+	featureId := features.Features[0].Id
+	_, err := s.UpdateFeature(ctx, &api.UpdateFeatureRequest{
+		Feature: &api.Feature{Id: featureId, Description: "audited", Version: features.Features[0].Version}, UpdateMask: &field_mask.FieldMask{Paths: []string{"description"}}})
+	require.Nil(t, err, "Should update feature, %v", err)
+
+	res, err := s.SearchAuditLog(ctx, &api.SearchAuditLogRequest{EntityType: api.EntityType_FEATURE, EntityId: featureId})
+	require.Nil(t, err, "Should search audit log, %v", err)
+	require.Len(t, res.Entries, 2)
+	assert.Equal(t, "jwt:adam", res.Entries[0].Actor, "Should record the caller")
+	assert.Equal(t, api.AuditEntry_UPDATE, res.Entries[0].Operation)
+	assert.Equal(t, api.EntityType_FEATURE, res.Entries[0].EntityType)
+	assert.Contains(t, res.Entries[0].After, "audited")
+	assert.Equal(t, "anonymous", res.Entries[1].Actor, "Should record changes without a caller as anonymous")
+	assert.Equal(t, api.AuditEntry_CREATE, res.Entries[1].Operation)
+
+	res, err = s.SearchAuditLog(ctx, &api.SearchAuditLogRequest{Actor: "jwt:adam", Limit: 1})
+	require.Nil(t, err)
+	assert.Len(t, res.Entries, 1)
+	res, err = s.SearchAuditLog(ctx, &api.SearchAuditLogRequest{EntityType: api.EntityType_PROPERTY})
+	require.Nil(t, err)
+	require.Len(t, res.Entries, 1)
+	assert.Equal(t, "username", res.Entries[0].EntityId)
+
+	_, err = s.SearchAuditLog(ctx, &api.SearchAuditLogRequest{Limit: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestRevertToRevision_reloads_tree(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	req := &api.GetFeaturesByPropertiesRequest{Properties: map[string]string{"username": "adam"}}
+
+	features, _ := s.SearchFeature(ctx, &api.SearchFeatureRequest{Name: "feature 1"})
+	featureId := features.Features[0].Id
+	_, err := s.CreateToggleRule(ctx, &api.CreateToggleRuleRequest{ToggleRule: &api.ToggleRule{
+		Name: "feature 1", Enabled: true, Properties: map[string]string{"username": "adam"}}})
+	require.Nil(t, err, "Should create toggle rule, %v", err)
+	_, err = s.UpdateFeature(ctx, &api.UpdateFeatureRequest{
+		Feature: &api.Feature{Id: featureId, Version: features.Features[0].Version}, UpdateMask: &field_mask.FieldMask{Paths: []string{"enabled"}}})
+	require.Nil(t, err)
+	res, _ := s.GetFeaturesForProperties(ctx, req)
+	assert.Empty(t, res.Features)
+
+	revisions, err := s.ListRevisions(ctx, &api.ListRevisionsRequest{FeatureId: featureId})
+	require.Nil(t, err, "Should list revisions, %v", err)
+	require.Len(t, revisions.Revisions, 3)
+	assert.False(t, revisions.Revisions[0].Feature.Enabled)
+	require.Len(t, revisions.Revisions[1].ToggleRules, 1)
+	assert.Equal(t, "feature 1", revisions.Revisions[1].ToggleRules[0].Name)
+
+	version := revisions.Revisions[0].Feature.Version
+	_, err = s.RevertToRevision(ctx, &api.RevertToRevisionRequest{FeatureId: featureId, Revision: 2})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "Should require the version")
+	_, err = s.RevertToRevision(ctx, &api.RevertToRevisionRequest{FeatureId: featureId, Revision: 2, Version: version - 1})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "Should refuse a stale version")
+	reverted, err := s.RevertToRevision(ctx, &api.RevertToRevisionRequest{FeatureId: featureId, Revision: 2, Version: version})
+	require.Nil(t, err, "Should revert, %v", err)
+	assert.Equal(t, version + 1, reverted.Revision.Feature.Version)
+	assert.Equal(t, int32(4), reverted.Revision.Revision)
+	assert.True(t, reverted.Revision.Feature.Enabled)
+
+	res, _ = s.GetFeaturesForProperties(ctx, req)
+	assert.Equal(t, []string{"feature 1"}, res.Features, "Should reload the tree")
+
+	_, err = s.RevertToRevision(ctx, &api.RevertToRevisionRequest{FeatureId: featureId, Revision: 9, Version: version + 1})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.RevertToRevision(ctx, &api.RevertToRevisionRequest{FeatureId: featureId})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.ListRevisions(ctx, &api.ListRevisionsRequest{FeatureId: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,101 @@
+package feature_toggle_impl
+
+import (
+	"fmt"
+	"strings"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/auth"
+	"github.com/peterrosell/feature-toggle-service/storage"
+	"golang.org/x/net/context"
+)
+
+func (s *FeatureToggleServiceServer) SetRoleBinding(ctx context.Context, req *api.SetRoleBindingRequest) (*api.SetRoleBindingResponse, error) {
+	fmt.Printf("SetRoleBinding: %v\n", req)
+
+	if req.RoleBinding == nil {
+		return nil, invalidArgument("roleBinding", "role binding is missing")
+	}
+	err := auth.ValidatePrincipal(req.RoleBinding.Principal)
+	if err != nil {
+		return nil, invalidArgument("roleBinding.principal", err.Error())
+	}
+	binding := storage.RoleBinding{Principal:req.RoleBinding.Principal, Role:strings.ToLower(req.RoleBinding.Role.String())}
+	err = s.store(ctx).SetRoleBinding(binding)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return new(api.SetRoleBindingResponse), nil
+}
+
+func (s *FeatureToggleServiceServer) DeleteRoleBinding(ctx context.Context, req *api.DeleteRoleBindingRequest) (*api.DeleteRoleBindingResponse, error) {
+	fmt.Printf("DeleteRoleBinding: principal=%s\n", req.Principal)
+
+	deleted, err := s.store(ctx).DeleteRoleBinding(req.Principal)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if !*deleted {
+		return nil, notFound(storage.KindRoleBinding, req.Principal)
+	}
+	return new(api.DeleteRoleBindingResponse), nil
+}
+
+func (s *FeatureToggleServiceServer) ListRoleBindings(ctx context.Context, req *api.ListRoleBindingsRequest) (*api.ListRoleBindingsResponse, error) {
+	fmt.Printf("ListRoleBindings\n")
+
+	bindings, err := s.fs.ListRoleBindings()
+	if err != nil {
+		return nil, statusError(err)
+	}
+	response := &api.ListRoleBindingsResponse{RoleBindings:[]*api.RoleBinding{}}
+	for _, binding := range *bindings {
+		role := api.Role(api.Role_value[strings.ToUpper(binding.Role)])
+		response.RoleBindings = append(response.RoleBindings, &api.RoleBinding{Principal:binding.Principal, Role:role})
+	}
+	return response, nil
+}
+
+func (s *FeatureToggleServiceServer) AddFeatureOwner(ctx context.Context, req *api.FeatureOwnerRequest) (*api.FeatureOwnerResponse, error) {
+	fmt.Printf("AddFeatureOwner: %v\n", req)
+
+	err := auth.ValidatePrincipal(req.Principal)
+	if err != nil {
+		return nil, invalidArgument("principal", err.Error())
+	}
+	err = s.store(ctx).AddFeatureOwner(storage.FeatureOwner{FeatureId:req.FeatureId, Principal:req.Principal})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return new(api.FeatureOwnerResponse), nil
+}
+
+func (s *FeatureToggleServiceServer) RemoveFeatureOwner(ctx context.Context, req *api.FeatureOwnerRequest) (*api.FeatureOwnerResponse, error) {
+	fmt.Printf("RemoveFeatureOwner: %v\n", req)
+
+	removed, err := s.store(ctx).RemoveFeatureOwner(storage.FeatureOwner{FeatureId:req.FeatureId, Principal:req.Principal})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if !*removed {
+		return nil, notFound(storage.KindFeatureOwner, req.Principal)
+	}
+	return new(api.FeatureOwnerResponse), nil
+}
+
+func (s *FeatureToggleServiceServer) ListFeatureOwners(ctx context.Context, req *api.ListFeatureOwnersRequest) (*api.ListFeatureOwnersResponse, error) {
+	fmt.Printf("ListFeatureOwners: featureId=%s\n", req.FeatureId)
+
+	feature, err := s.fs.ReadFeature(req.FeatureId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if feature == nil {
+		return nil, notFound(storage.KindFeature, req.FeatureId)
+	}
+	owners, err := s.fs.ReadFeatureOwners(req.FeatureId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &api.ListFeatureOwnersResponse{Principals:*owners}, nil
+}
@@ -0,0 +1,75 @@
+package feature_toggle_impl
+
+import (
+	"fmt"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/storage"
+	"golang.org/x/net/context"
+)
+
+func (s *FeatureToggleServiceServer) ListRevisions(ctx context.Context, req *api.ListRevisionsRequest) (*api.ListRevisionsResponse, error) {
+	fmt.Printf("ListRevisions: featureId=%s\n", req.FeatureId)
+
+	feature, err := s.fs.ReadFeature(req.FeatureId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if feature == nil {
+		return nil, notFound(storage.KindFeature, req.FeatureId)
+	}
+	revisions, err := s.fs.ListRevisions(req.FeatureId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	response := &api.ListRevisionsResponse{Revisions:[]*api.Revision{}}
+	for _, revision := range *revisions {
+		apiRevision, err := toApiRevision(revision)
+		if err != nil {
+			return nil, statusError(err)
+		}
+		response.Revisions = append(response.Revisions, apiRevision)
+	}
+	return response, nil
+}
+
+// RevertToRevision rebuilds the toggle rule tree as the rules of the feature
+// may all have changed.
+func (s *FeatureToggleServiceServer) RevertToRevision(ctx context.Context, req *api.RevertToRevisionRequest) (*api.RevertToRevisionResponse, error) {
+	fmt.Printf("RevertToRevision: %v\n", req)
+
+	if req.Revision < 1 {
+		return nil, invalidArgument("revision", "revisions are numbered from 1")
+	}
+	version, err := requireVersion(ctx, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := s.store(ctx).RevertToRevision(req.FeatureId, int(req.Revision), version)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	s.reloadTree()
+	apiRevision, err := toApiRevision(*revision)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &api.RevertToRevisionResponse{Revision:apiRevision}, nil
+}
+
+func toApiRevision(revision storage.Revision) (*api.Revision, error) {
+	created, err := toApiTime(revision.Created)
+	if err != nil {
+		return nil, err
+	}
+	rules := []*api.ToggleRule{}
+	for _, rule := range revision.ToggleRules {
+		apiRule, err := toApiToggleRule(rule, revision.Feature.Name)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, apiRule)
+	}
+	return &api.Revision{Revision:int32(revision.Revision), Created:created, Actor:revision.Actor,
+		Feature:toApiFeature(revision.Feature), ToggleRules:rules}, nil
+}
@@ -0,0 +1,36 @@
+package feature_toggle_impl
+
+import (
+	"fmt"
+	"time"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+	"golang.org/x/net/context"
+)
+
+func (s *FeatureToggleServiceServer) GetSchedule(ctx context.Context, req *api.GetScheduleRequest) (*api.GetScheduleResponse, error) {
+	fmt.Printf("GetSchedule: %v\n", req)
+
+	until, err := fromApiTime(req.Until)
+	if err != nil {
+		return nil, invalidArgument("until", err.Error())
+	}
+	response := &api.GetScheduleResponse{Changes:[]*api.ScheduledChange{}}
+	for _, change := range s.tree.schedule(time.Now()) {
+		if !until.IsZero() && change.Time.After(until) {
+			break
+		}
+		ts, err := toApiTime(change.Time)
+		if err != nil {
+			return nil, statusError(err)
+		}
+		action := api.ScheduledChange_ACTIVATE
+		if change.Action == featuretree.ScheduleDeactivate {
+			action = api.ScheduledChange_DEACTIVATE
+		}
+		response.Changes = append(response.Changes, &api.ScheduledChange{Time:ts, ToggleRuleId:change.RuleId,
+			Feature:change.Feature, Action:action})
+	}
+	return response, nil
+}
@@ -0,0 +1,72 @@
+package feature_toggle_impl
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/peterrosell/feature-toggle-service/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusError turns an error into a gRPC status with details, which the
+// gateway maps to an HTTP status. Typed storage errors become
+//   NotFoundError         NOT_FOUND, 404, with ResourceInfo
+//   AlreadyExistsError    ALREADY_EXISTS, 409, with ResourceInfo
+//   ReferencedError       FAILED_PRECONDITION, 400, with PreconditionFailure
+//   VersionMismatchError  FAILED_PRECONDITION, 412, with PreconditionFailure
+//   InvalidArgumentError  INVALID_ARGUMENT, 400, with BadRequest
+// errors that already are a status are kept and anything else is INTERNAL.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+	switch e := err.(type) {
+	case *storage.NotFoundError:
+		return withDetails(codes.NotFound, e.Error(), &errdetails.ResourceInfo{ResourceType:e.Kind, ResourceName:e.Key, Description:e.Error()})
+	case *storage.AlreadyExistsError:
+		return withDetails(codes.AlreadyExists, e.Error(), &errdetails.ResourceInfo{ResourceType:e.Kind, ResourceName:e.Key, Description:e.Error()})
+	case *storage.ReferencedError:
+		return withDetails(codes.FailedPrecondition, e.Error(), &errdetails.PreconditionFailure{
+			Violations:[]*errdetails.PreconditionFailure_Violation{{
+				Type:"REFERENCED",
+				Subject:fmt.Sprintf("%s/%s", e.Kind, e.Key),
+				Description:e.Error(),
+			}},
+		}, &errdetails.ResourceInfo{ResourceType:e.ByKind, ResourceName:e.ByKey, Description:e.Error()})
+	case *storage.VersionMismatchError:
+		return withDetails(codes.FailedPrecondition, e.Error(), &errdetails.PreconditionFailure{
+			Violations:[]*errdetails.PreconditionFailure_Violation{{
+				Type:VERSION_VIOLATION,
+				Subject:fmt.Sprintf("%s/%s", e.Kind, e.Key),
+				Description:e.Error(),
+			}},
+		})
+	case *storage.InvalidArgumentError:
+		return invalidArgument(e.Field, e.Description)
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func notFound(kind string, key string) error {
+	return statusError(&storage.NotFoundError{Kind:kind, Key:key})
+}
+
+func invalidArgument(field string, description string) error {
+	return withDetails(codes.InvalidArgument, fmt.Sprintf("Invalid %s, %s", field, description), &errdetails.BadRequest{
+		FieldViolations:[]*errdetails.BadRequest_FieldViolation{{Field:field, Description:description}},
+	})
+}
+
+func withDetails(code codes.Code, message string, details ...proto.Message) error {
+	st := status.New(code, message)
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
@@ -0,0 +1,276 @@
+package feature_toggle_impl
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+	"github.com/peterrosell/feature-toggle-service/storage"
+)
+
+// treeHolder keeps the current ToggleRuleTree. A new tree is always built
+// completely before it is swapped in, so readers never see a half-built tree.
+//
+// The holder knows every enabled rule that has not expired, the tree only
+// holds those active now. A timer set to the next start or expiry of a rule
+// swaps in a new tree at that moment.
+type treeHolder struct {
+	tree        featuretree.AtomicTree
+	reloadMutex sync.Mutex
+	builder     *featuretree.TreeBuilder
+	rules       map[string]featuretree.ToggleRule
+	active      map[string]bool
+	timer       *time.Timer
+	stopped     bool
+	stop        chan struct{}
+	stopOnce    sync.Once
+	watchMutex  sync.Mutex
+	watchers    map[chan struct{}]bool
+}
+
+func (h *treeHolder) get() *featuretree.ToggleRuleTree {
+	return h.tree.Load()
+}
+
+// store swaps in the tree and tells the watchers.
+func (h *treeHolder) store(tree *featuretree.ToggleRuleTree) {
+	h.tree.Store(tree)
+
+	h.watchMutex.Lock()
+	defer h.watchMutex.Unlock()
+	for ch := range h.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// the watcher has not seen the previous change yet
+		}
+	}
+}
+
+// watch returns a channel that receives a value after the tree has been
+// swapped, several swaps may be reported once. Call cancel to stop watching.
+func (h *treeHolder) watch() (changed <-chan struct{}, cancel func()) {
+	ch := make(chan struct{}, 1)
+	h.watchMutex.Lock()
+	defer h.watchMutex.Unlock()
+	if h.watchers == nil {
+		h.watchers = make(map[chan struct{}]bool)
+	}
+	h.watchers[ch] = true
+	return ch, func() {
+		h.watchMutex.Lock()
+		defer h.watchMutex.Unlock()
+		delete(h.watchers, ch)
+	}
+}
+
+// reload builds a new tree from the enabled toggle rules in the store and
+// swaps it in. On failure the current tree is kept.
+func (h *treeHolder) reload(fs storage.FeatureToggleStore) error {
+	h.reloadMutex.Lock()
+	defer h.reloadMutex.Unlock()
+
+	builder, rules, err := newTreeBuilder(fs)
+	if err != nil {
+		return err
+	}
+	h.builder = builder
+	h.rules = make(map[string]featuretree.ToggleRule)
+	h.active = make(map[string]bool)
+	now := time.Now()
+	for _, rule := range rules {
+		if rule.ActiveAt(now) {
+			err := builder.AddFeature(rule)
+			if err != nil {
+				fmt.Printf("%s\n", err.Error())
+				continue
+			}
+			h.active[rule.Id] = true
+		}
+		h.rules[rule.Id] = rule
+	}
+	h.store(builder.Build())
+	h.scheduleNext(now)
+	return nil
+}
+
+// addRule adds or replaces a single rule without reading all rules from the store.
+func (h *treeHolder) addRule(rule featuretree.ToggleRule) error {
+	h.reloadMutex.Lock()
+	defer h.reloadMutex.Unlock()
+
+	if h.builder == nil {
+		return nil
+	}
+	now := time.Now()
+	if rule.ExpiredAt(now) {
+		h.removeLocked(rule.Id)
+		return nil
+	}
+	if rule.ActiveAt(now) {
+		err := h.builder.AddFeature(rule)
+		if err != nil {
+			return err
+		}
+		h.active[rule.Id] = true
+	} else if h.active[rule.Id] {
+		h.builder.RemoveRule(rule.Id)
+		delete(h.active, rule.Id)
+	}
+	h.rules[rule.Id] = rule
+	h.store(h.builder.Build())
+	h.scheduleNext(now)
+	return nil
+}
+
+// removeRule removes a single rule without reading all rules from the store.
+func (h *treeHolder) removeRule(id string) {
+	h.reloadMutex.Lock()
+	defer h.reloadMutex.Unlock()
+
+	h.removeLocked(id)
+}
+
+func (h *treeHolder) removeLocked(id string) {
+	if h.builder == nil {
+		return
+	}
+	delete(h.rules, id)
+	delete(h.active, id)
+	if h.builder.RemoveRule(id) {
+		h.store(h.builder.Build())
+	}
+	h.scheduleNext(time.Now())
+}
+
+// activate adds the rules that have started to the tree and removes those
+// that have expired.
+func (h *treeHolder) activate() {
+	h.reloadMutex.Lock()
+	defer h.reloadMutex.Unlock()
+
+	if h.stopped {
+		return
+	}
+	now := time.Now()
+	changed := false
+	for id, rule := range h.rules {
+		active := rule.ActiveAt(now)
+		switch {
+		case active && !h.active[id]:
+			err := h.builder.AddFeature(rule)
+			if err != nil {
+				fmt.Printf("%s\n", err.Error())
+				break
+			}
+			h.active[id] = true
+			changed = true
+		case !active && h.active[id]:
+			h.builder.RemoveRule(id)
+			delete(h.active, id)
+			changed = true
+		}
+		if rule.ExpiredAt(now) {
+			delete(h.rules, id)
+		}
+	}
+	if changed {
+		h.store(h.builder.Build())
+	}
+	h.scheduleNext(now)
+}
+
+// scheduleNext sets the timer to the next start or expiry of a rule after
+// now, must be called with the reloadMutex held.
+func (h *treeHolder) scheduleNext(now time.Time) {
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	if h.stopped {
+		return
+	}
+	next := featuretree.NextChange(h.ruleList(), now)
+	if next.IsZero() {
+		return
+	}
+	h.timer = time.AfterFunc(next.Sub(now), h.activate)
+}
+
+// schedule returns the rules starting or expiring after now.
+func (h *treeHolder) schedule(now time.Time) []featuretree.ScheduledChange {
+	h.reloadMutex.Lock()
+	defer h.reloadMutex.Unlock()
+
+	return featuretree.Schedule(h.ruleList(), now)
+}
+
+func (h *treeHolder) ruleList() []featuretree.ToggleRule {
+	rules := make([]featuretree.ToggleRule, 0, len(h.rules))
+	for _, rule := range h.rules {
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// reloadEvery reloads the tree on a timer to pick up changes made directly in
+// the database, until stopReload is called.
+func (h *treeHolder) reloadEvery(fs storage.FeatureToggleStore, interval time.Duration) {
+	h.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := h.reload(fs)
+				if err != nil {
+					fmt.Printf("Failed to reload toggle rule tree, %v\n", err)
+				}
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopReload stops the periodic reload and the scheduled activations.
+func (h *treeHolder) stopReload() {
+	h.stopOnce.Do(func() {
+		if h.stop != nil {
+			close(h.stop)
+		}
+	})
+	h.reloadMutex.Lock()
+	defer h.reloadMutex.Unlock()
+	h.stopped = true
+	h.scheduleNext(time.Now())
+}
+
+// newTreeBuilder returns a builder with the properties and feature variants
+// of the store and the enabled toggle rules, which are left for the caller to
+// add when they are active.
+func newTreeBuilder(fs storage.FeatureToggleStore) (*featuretree.TreeBuilder, []featuretree.ToggleRule, error) {
+	toggleRules, err := fs.GetEnabledToggleRules()
+	if err != nil {
+		return nil, nil, err
+	}
+	propertyNames, err := fs.ReadAllPropertyNames()
+	if err != nil {
+		return nil, nil, err
+	}
+	builder := featuretree.NewTreeBuilder(*propertyNames)
+
+	variants, err := fs.GetFeatureVariants()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, fv := range *variants {
+		err := builder.SetVariants(fv)
+		if err != nil {
+			fmt.Printf("%s\n", err.Error())
+		}
+	}
+	return builder, *toggleRules, nil
+}
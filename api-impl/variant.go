@@ -0,0 +1,56 @@
+package feature_toggle_impl
+
+import (
+	"strings"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+)
+
+func toVariantType(variantType string) api.VariantType {
+	return api.VariantType(api.VariantType_value[strings.ToUpper(variantType)])
+}
+
+func fromVariantType(variantType api.VariantType) string {
+	return strings.ToLower(variantType.String())
+}
+
+func fromApiVariants(variants []*api.Variant) []featuretree.Variant {
+	res := []featuretree.Variant{}
+	for _, v := range variants {
+		res = append(res, featuretree.Variant{Name:v.Name, Type:fromVariantType(v.Type), Value:v.Value})
+	}
+	return res
+}
+
+func toApiVariants(variants []featuretree.Variant) []*api.Variant {
+	res := []*api.Variant{}
+	for _, v := range variants {
+		res = append(res, &api.Variant{Name:v.Name, Type:toVariantType(v.Type), Value:v.Value})
+	}
+	return res
+}
+
+func fromApiWeightedVariants(variants []*api.WeightedVariant) []featuretree.WeightedVariant {
+	res := []featuretree.WeightedVariant{}
+	for _, v := range variants {
+		res = append(res, featuretree.WeightedVariant{Variant:v.Variant, Weight:int(v.Weight)})
+	}
+	return res
+}
+
+func toApiWeightedVariants(variants []featuretree.WeightedVariant) []*api.WeightedVariant {
+	res := []*api.WeightedVariant{}
+	for _, v := range variants {
+		res = append(res, &api.WeightedVariant{Variant:v.Variant, Weight:int32(v.Weight)})
+	}
+	return res
+}
+
+func toApiFeatureValues(evaluations []featuretree.Evaluation) []*api.FeatureValue {
+	res := []*api.FeatureValue{}
+	for _, e := range evaluations {
+		res = append(res, &api.FeatureValue{Name:e.Feature, Variant:e.Variant, Type:toVariantType(e.Type), Value:e.Value})
+	}
+	return res
+}
@@ -0,0 +1,56 @@
+package feature_toggle_impl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// IF_MATCH_METADATA is the If-Match header of a request as the gateway
+// forwards it.
+const IF_MATCH_METADATA = runtime.MetadataPrefix + "if-match"
+
+// VERSION_VIOLATION is the type of the PreconditionFailure violation of a
+// stale version, the gateway answers it with 412 Precondition Failed.
+const VERSION_VIOLATION = "VERSION"
+
+// requireVersion returns the version an update or delete is based on, taken
+// from the If-Match header if the request doesn't give it.
+func requireVersion(ctx context.Context, version int64) (int64, error) {
+	if version != 0 {
+		return version, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md[IF_MATCH_METADATA]
+	if len(values) == 0 {
+		return 0, invalidArgument("version", "version is missing, give it in the request or as If-Match")
+	}
+	version, err := ParseETag(values[0])
+	if err != nil {
+		return 0, invalidArgument("If-Match", err.Error())
+	}
+	return version, nil
+}
+
+// ETag returns the entity tag of a version.
+func ETag(version int64) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// ParseETag returns the version of an entity tag, weak tags are accepted.
+func ParseETag(tag string) (int64, error) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag) - 1] != '"' {
+		return 0, errors.New(fmt.Sprintf("'%s' is not an entity tag", tag))
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag) - 1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, errors.New(fmt.Sprintf("%s is not a version", tag))
+	}
+	return version, nil
+}
@@ -0,0 +1,52 @@
+package feature_toggle_impl
+
+import (
+	"sort"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchFeatures sends the features enabled for the properties and then the
+// new features every time the tree is swapped and the result changes. The
+// tree is watched before it is read so no change is missed.
+func (s *FeatureToggleServiceServer) WatchFeatures(req *api.GetFeaturesByPropertiesRequest, stream api.FeatureToggleService_WatchFeaturesServer) error {
+	changed, cancel := s.tree.watch()
+	defer cancel()
+
+	var last []string
+	for {
+		tree := s.tree.get()
+		if tree == nil {
+			return status.Error(codes.Unavailable, "Feature toggle service not initialized.")
+		}
+		features := tree.FindFeatures(req.Properties)
+		sort.Strings(features)
+		if last == nil || !equalFeatures(features, last) {
+			err := stream.Send(&api.GetFeaturesByPropertiesResponse{Features:features})
+			if err != nil {
+				return err
+			}
+			last = features
+		}
+
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func equalFeatures(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/golang/glog"
+	"github.com/peterrosell/feature-toggle-service/auth"
+	"github.com/peterrosell/feature-toggle-service/storage"
+)
+
+var (
+	dbConfig = storage.DBConfigFromEnv()
+)
+
+func init() {
+	dbConfig.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] create <name> <evaluate|admin>|list|delete <id>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+// actor names the changes made by the command in the audit log after the
+// user running it.
+func actor() string {
+	u, err := user.Current()
+	if err != nil {
+		return "cli:" + storage.ANONYMOUS_ACTOR
+	}
+	return "cli:" + u.Username
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("missing command")
+	}
+	fs := storage.NewFeatureToggleStoreImpl(dbConfig)
+	err := fs.Open()
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+	store := fs.WithActor(actor())
+
+	switch args[0] {
+	case "create":
+		if len(args) < 3 {
+			return errors.New("create requires a name and a scope")
+		}
+		err = auth.ValidateScope(args[2])
+		if err != nil {
+			return err
+		}
+		key, err := auth.NewApiKey()
+		if err != nil {
+			return err
+		}
+		id, err := store.CreateApiKey(storage.ApiKey{Name:args[1], Hash:auth.HashApiKey(key), Scope:args[2]})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created api key %s, it is only shown once:\n%s\n", *id, key)
+	case "list":
+		keys, err := fs.ListApiKeys()
+		if err != nil {
+			return err
+		}
+		for _, key := range *keys {
+			fmt.Printf("%s\t%s\t%s\t%s\n", key.Id, key.Name, key.Scope, key.Created.Format("2006-01-02 15:04:05"))
+		}
+	case "delete":
+		if len(args) < 2 {
+			return errors.New("delete requires an id")
+		}
+		deleted, err := store.DeleteApiKey(args[1])
+		if err != nil {
+			return err
+		}
+		if !*deleted {
+			return errors.New(fmt.Sprintf("unknown api key '%s'", args[1]))
+		}
+		fmt.Printf("deleted api key %s\n", args[1])
+	default:
+		flag.Usage()
+		return errors.New(fmt.Sprintf("unknown command '%s'", args[0]))
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if err := run(flag.Args()); err != nil {
+		glog.Fatal(err)
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peterrosell/feature-toggle-service/storage"
+	"golang.org/x/net/context"
+)
+
+const (
+	// API_KEY_METADATA is the metadata key, and HTTP header, an API key can
+	// be sent in. It can also be sent as "authorization: ApiKey <key>".
+	API_KEY_METADATA = "x-api-key"
+	DEFAULT_API_KEY_CACHE_TTL = 30 * time.Second
+)
+
+// ApiKeyAuthenticator authenticates requests by the API keys of the store.
+// Keys found are cached for CacheTTL, so a deleted key works for at most that
+// long.
+type ApiKeyAuthenticator struct {
+	store    storage.FeatureToggleStore
+	CacheTTL time.Duration
+
+	mutex sync.Mutex
+	cache map[string]cachedApiKey
+}
+
+type cachedApiKey struct {
+	key     storage.ApiKey
+	fetched time.Time
+}
+
+func NewApiKeyAuthenticator(store storage.FeatureToggleStore) *ApiKeyAuthenticator {
+	return &ApiKeyAuthenticator{store:store, CacheTTL:DEFAULT_API_KEY_CACHE_TTL, cache:make(map[string]cachedApiKey)}
+}
+
+func (a *ApiKeyAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	key, ok := apiKeyFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	apiKey, err := a.lookup(HashApiKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, errors.New("Invalid API key")
+	}
+	return &Principal{Name:apiKey.Name, Method:MethodApiKey, Scopes:scopes([]string{apiKey.Scope})}, nil
+}
+
+func (a *ApiKeyAuthenticator) lookup(hash string) (*storage.ApiKey, error) {
+	a.mutex.Lock()
+	entry, ok := a.cache[hash]
+	a.mutex.Unlock()
+	if ok && time.Since(entry.fetched) < a.CacheTTL {
+		return &entry.key, nil
+	}
+
+	apiKey, err := a.store.ReadApiKeyByHash(hash)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to read API key, %v", err))
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if apiKey == nil {
+		delete(a.cache, hash)
+		return nil, nil
+	}
+	a.cache[hash] = cachedApiKey{key:*apiKey, fetched:time.Now()}
+	return apiKey, nil
+}
+
+func apiKeyFromContext(ctx context.Context) (string, bool) {
+	if key, ok := metadataValue(ctx, API_KEY_METADATA); ok {
+		return key, true
+	}
+	authorization, ok := metadataValue(ctx, "authorization")
+	if ok && strings.HasPrefix(strings.ToLower(authorization), "apikey ") {
+		return strings.TrimSpace(authorization[len("apikey "):]), true
+	}
+	return "", false
+}
+
+// HashApiKey returns the hash an API key is stored by, the hex encoded
+// sha256 of the key.
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewApiKey returns a random key, 32 bytes base64 encoded.
+func NewApiKey() (string, error) {
+	return randomToken()
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/peterrosell/feature-toggle-service/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func withMetadata(kv ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+}
+
+func TestApiKeyAuthenticator(t *testing.T) {
+	fs := storage.NewFeatureToggleMemStore()
+	key, err := NewApiKey()
+	require.Nil(t, err)
+	_, err = fs.CreateApiKey(storage.ApiKey{Name:"checkout", Hash:HashApiKey(key), Scope:ScopeEvaluate})
+	require.Nil(t, err)
+	a := NewApiKeyAuthenticator(fs)
+
+	principal, err := a.Authenticate(withMetadata(API_KEY_METADATA, key))
+	require.NotNil(t, principal, "Should authenticate the key, %v", err)
+	assert.Equal(t, "checkout", principal.Name)
+	assert.Equal(t, MethodApiKey, principal.Method)
+	assert.Equal(t, []string{ScopeEvaluate}, principal.Scopes)
+
+	principal, err = a.Authenticate(withMetadata("authorization", "ApiKey " + key))
+	require.NotNil(t, principal, "Should read the key from the authorization header, %v", err)
+
+	principal, err = a.Authenticate(withMetadata(API_KEY_METADATA, "wrong"))
+	assert.Nil(t, principal)
+	assert.NotNil(t, err, "Should refuse an unknown key")
+
+	principal, err = a.Authenticate(withMetadata("authorization", "Bearer token"))
+	assert.Nil(t, principal)
+	assert.Nil(t, err, "Should leave other credentials to other authenticators")
+}
+
+func TestApiKeyAuthenticator__deleted_key(t *testing.T) {
+	fs := storage.NewFeatureToggleMemStore()
+	id, _ := fs.CreateApiKey(storage.ApiKey{Name:"checkout", Hash:HashApiKey("secret"), Scope:ScopeAdmin})
+	a := NewApiKeyAuthenticator(fs)
+	a.CacheTTL = 0
+
+	principal, _ := a.Authenticate(withMetadata(API_KEY_METADATA, "secret"))
+	require.NotNil(t, principal)
+	fs.DeleteApiKey(*id)
+	principal, err := a.Authenticate(withMetadata(API_KEY_METADATA, "secret"))
+	assert.Nil(t, principal)
+	assert.NotNil(t, err, "Should refuse a deleted key once the cache expires")
+}
+
+func TestHashApiKey(t *testing.T) {
+	assert.Equal(t, "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b", HashApiKey("secret"))
+}
@@ -0,0 +1,199 @@
+// Package auth authenticates the callers of the gRPC service. Each request
+// must carry an API key, a JWT bearer token or a client certificate, which
+// one is up to the configured authenticators, and the principal it
+// authenticates as must have the scope the method requires.
+//
+// Keys, tokens and certificates have an evaluate or an admin scope.
+// Evaluate gives access to the methods that evaluate features and read the
+// toggle rules, which is what the Go client needs for local evaluation.
+// Admin gives access to everything.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	ScopeEvaluate = "evaluate"
+	ScopeAdmin    = "admin"
+)
+
+// Authentication methods, as named in Config.Methods.
+const (
+	MethodApiKey = "api_key"
+	MethodJWT    = "jwt"
+	MethodMTLS   = "mtls"
+)
+
+const servicePrefix = "/feature_toggle_api.FeatureToggleService/"
+
+// evaluateMethods are the methods the evaluate scope gives access to, all
+// other methods require the admin scope.
+var evaluateMethods = map[string]bool{
+	"GetFeaturesForProperties": true,
+	"EvaluateFeatures":         true,
+	"ExplainFeatures":          true,
+	"WatchFeatures":            true,
+	"BatchGetFeatures":         true,
+	"StreamBatchGetFeatures":   true,
+	"GetSchedule":              true,
+	"ReadToggleRule":           true,
+	"SearchToggleRule":         true,
+	"ReadFeature":              true,
+	"SearchFeature":            true,
+	"ReadProperty":             true,
+	"SearchProperty":           true,
+}
+
+// Principal is who a request is made by.
+type Principal struct {
+	Name string
+	// Method is how the principal was authenticated, one of Method*.
+	Method string
+	Scopes []string
+}
+
+// Id is the principal as named in role bindings, "<method>:<name>".
+func (p *Principal) Id() string {
+	return p.Method + ":" + p.Name
+}
+
+// HasScope tells if the principal has the scope, admin implies evaluate.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator finds the principal of a request from its metadata or peer.
+// It returns nil and no error if the request carries none of the
+// credentials it checks, and an error if they are invalid.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (*Principal, error)
+}
+
+// Chain tries each authenticator in order, the first one that finds
+// credentials decides.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context) (*Principal, error) {
+	for _, a := range c {
+		principal, err := a.Authenticate(ctx)
+		if err != nil || principal != nil {
+			return principal, err
+		}
+	}
+	return nil, nil
+}
+
+type principalKey struct{}
+
+// NewContext returns a context carrying the principal.
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal the request was authenticated as.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// RequiredScope returns the scope needed to call the full gRPC method name.
+func RequiredScope(fullMethod string) string {
+	if strings.HasPrefix(fullMethod, servicePrefix) && evaluateMethods[strings.TrimPrefix(fullMethod, servicePrefix)] {
+		return ScopeEvaluate
+	}
+	return ScopeAdmin
+}
+
+// UnaryServerInterceptor rejects unary calls that aren't authenticated with
+// the required scope, the principal is in the context of the handler.
+func UnaryServerInterceptor(a Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authorize(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls.
+func StreamServerInterceptor(a Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(stream.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream:stream, ctx:ctx})
+	}
+}
+
+func authorize(ctx context.Context, a Authenticator, fullMethod string) (context.Context, error) {
+	principal, err := a.Authenticate(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if principal == nil {
+		return nil, status.Error(codes.Unauthenticated, "Missing credentials")
+	}
+	scope := RequiredScope(fullMethod)
+	if !principal.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("'%s' requires the %s scope", principal.Name, scope))
+	}
+	return NewContext(ctx, principal), nil
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// scopes keeps the known scopes of values.
+func scopes(values []string) []string {
+	res := []string{}
+	for _, v := range values {
+		if v == ScopeEvaluate || v == ScopeAdmin {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// ValidateScope returns an error unless scope is ScopeEvaluate or ScopeAdmin.
+func ValidateScope(scope string) error {
+	if len(scopes([]string{scope})) == 0 {
+		return errors.New(fmt.Sprintf("Unknown scope '%s', must be '%s' or '%s'", scope, ScopeEvaluate, ScopeAdmin))
+	}
+	return nil
+}
+
+// metadataValue returns the first value of the metadata key of the incoming
+// request.
+func metadataValue(ctx context.Context, key string) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	values := md[key]
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fixedAuthenticator struct {
+	principal *Principal
+	err       error
+}
+
+func (a fixedAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	return a.principal, a.err
+}
+
+func callUnary(a Authenticator, method string) (*Principal, error) {
+	var principal *Principal
+	_, err := UnaryServerInterceptor(a)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod:servicePrefix + method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			principal, _ = FromContext(ctx)
+			return nil, nil
+		})
+	return principal, err
+}
+
+func TestRequiredScope(t *testing.T) {
+	assert.Equal(t, ScopeEvaluate, RequiredScope(servicePrefix + "GetFeaturesForProperties"))
+	assert.Equal(t, ScopeEvaluate, RequiredScope(servicePrefix + "SearchToggleRule"), "Local evaluation should only need evaluate")
+	assert.Equal(t, ScopeAdmin, RequiredScope(servicePrefix + "CreateFeature"))
+	assert.Equal(t, ScopeAdmin, RequiredScope("/other.Service/GetFeaturesForProperties"))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	evaluator := &Principal{Name:"service", Scopes:[]string{ScopeEvaluate}}
+	admin := &Principal{Name:"ops", Scopes:[]string{ScopeAdmin}}
+
+	principal, err := callUnary(fixedAuthenticator{principal:evaluator}, "EvaluateFeatures")
+	require.Nil(t, err)
+	assert.Equal(t, evaluator, principal, "Should pass the principal to the handler")
+
+	_, err = callUnary(fixedAuthenticator{principal:evaluator}, "DeleteFeature")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "Evaluate keys should not administrate")
+
+	_, err = callUnary(fixedAuthenticator{principal:admin}, "EvaluateFeatures")
+	assert.Nil(t, err, "Admin should imply evaluate")
+	_, err = callUnary(fixedAuthenticator{principal:admin}, "DeleteFeature")
+	assert.Nil(t, err)
+
+	_, err = callUnary(fixedAuthenticator{}, "EvaluateFeatures")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "Should require credentials")
+
+	_, err = callUnary(fixedAuthenticator{err:errors.New("Invalid API key")}, "EvaluateFeatures")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestChain(t *testing.T) {
+	first := &Principal{Name:"first"}
+	chain := Chain{fixedAuthenticator{}, fixedAuthenticator{principal:first}, fixedAuthenticator{err:errors.New("fail")}}
+	principal, err := chain.Authenticate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, first, principal, "The first authenticator finding credentials should decide")
+
+	chain = Chain{fixedAuthenticator{err:errors.New("fail")}, fixedAuthenticator{principal:first}}
+	_, err = chain.Authenticate(context.Background())
+	assert.NotNil(t, err, "Invalid credentials should not fall through")
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	a, err := NewAuthenticator(Config{Methods:"api_key, mtls"}, nil)
+	require.Nil(t, err)
+	assert.Len(t, a, 2)
+
+	_, err = NewAuthenticator(Config{Methods:"jwt"}, nil)
+	assert.NotNil(t, err, "jwt should require a JWKS file")
+
+	_, err = NewAuthenticator(Config{Methods:"password"}, nil)
+	assert.NotNil(t, err, "Should refuse unknown methods")
+
+	assert.False(t, Config{Methods:" , "}.Enabled())
+}
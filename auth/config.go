@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/peterrosell/feature-toggle-service/storage"
+	"google.golang.org/grpc"
+)
+
+// Config selects the authentication methods, none disables authentication.
+type Config struct {
+	// Methods is a comma separated list of api_key, jwt and mtls, tried in
+	// the order listed.
+	Methods  string
+	JWKSFile string
+	Issuer   string
+	Audience string
+	// GatewaySecret is shared with the gateway, see MTLSAuthenticator.
+	GatewaySecret string
+	// RBAC authorizes calls by role, see RBAC. DefaultRole is the role of
+	// principals without a binding and Admins a comma separated list of
+	// principals that are always admins.
+	RBAC        bool
+	DefaultRole string
+	Admins      string
+}
+
+// ConfigFromEnv returns the configuration from the AUTH_METHODS,
+// AUTH_JWKS_FILE, AUTH_JWT_ISSUER, AUTH_JWT_AUDIENCE, AUTH_GATEWAY_SECRET,
+// AUTH_RBAC, AUTH_RBAC_DEFAULT_ROLE and AUTH_RBAC_ADMINS environment
+// variables.
+func ConfigFromEnv() Config {
+	return Config{
+		Methods: os.Getenv("AUTH_METHODS"),
+		JWKSFile: os.Getenv("AUTH_JWKS_FILE"),
+		Issuer: os.Getenv("AUTH_JWT_ISSUER"),
+		Audience: os.Getenv("AUTH_JWT_AUDIENCE"),
+		GatewaySecret: os.Getenv("AUTH_GATEWAY_SECRET"),
+		RBAC: os.Getenv("AUTH_RBAC") == "true",
+		DefaultRole: envOr("AUTH_RBAC_DEFAULT_ROLE", RoleViewer),
+		Admins: os.Getenv("AUTH_RBAC_ADMINS"),
+	}
+}
+
+func envOr(name string, value string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return value
+}
+
+// RegisterFlags binds the configuration to command line flags, the current
+// values are used as flag defaults.
+func (config *Config) RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&config.Methods, "auth", config.Methods, "comma separated authentication methods, api_key, jwt and mtls, empty disables authentication")
+	flags.StringVar(&config.JWKSFile, "auth_jwks_file", config.JWKSFile, "JWKS file with the keys JWT bearer tokens are verified with")
+	flags.StringVar(&config.Issuer, "auth_jwt_issuer", config.Issuer, "required iss of JWT bearer tokens, empty accepts any")
+	flags.StringVar(&config.Audience, "auth_jwt_audience", config.Audience, "required aud of JWT bearer tokens, empty accepts any")
+	flags.StringVar(&config.GatewaySecret, "auth_gateway_secret", config.GatewaySecret, "secret the gateway forwards client certificates with")
+	flags.BoolVar(&config.RBAC, "rbac", config.RBAC, "authorize calls by the role bindings of the store, requires -auth")
+	flags.StringVar(&config.DefaultRole, "rbac_default_role", config.DefaultRole, "role of principals without a role binding, viewer, editor, admin or empty for none")
+	flags.StringVar(&config.Admins, "rbac_admins", config.Admins, "comma separated principals, e.g. jwt:adam, that are always admins")
+}
+
+func (config Config) Enabled() bool {
+	return len(config.methods()) > 0
+}
+
+func (config Config) methods() []string {
+	return splitList(config.Methods)
+}
+
+func splitList(list string) []string {
+	res := []string{}
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			res = append(res, value)
+		}
+	}
+	return res
+}
+
+// NewAuthenticator returns a chain of the configured authenticators, API
+// keys are looked up in the store.
+func NewAuthenticator(config Config, store storage.FeatureToggleStore) (Authenticator, error) {
+	chain := Chain{}
+	for _, method := range config.methods() {
+		switch method {
+		case MethodApiKey:
+			chain = append(chain, NewApiKeyAuthenticator(store))
+		case MethodJWT:
+			if config.JWKSFile == "" {
+				return nil, errors.New("The jwt authentication method requires a JWKS file")
+			}
+			a, err := NewJWTAuthenticator(config.JWKSFile, config.Issuer, config.Audience)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, a)
+		case MethodMTLS:
+			chain = append(chain, NewMTLSAuthenticator(config.GatewaySecret))
+		default:
+			return nil, errors.New(fmt.Sprintf("Unknown authentication method '%s', must be '%s', '%s' or '%s'", method, MethodApiKey, MethodJWT, MethodMTLS))
+		}
+	}
+	return chain, nil
+}
+
+// ServerOptions returns the interceptors authenticating, and with RBAC
+// authorizing, the calls of a server. Authentication must be enabled.
+func ServerOptions(config Config, store storage.FeatureToggleStore) ([]grpc.ServerOption, error) {
+	authenticator, err := NewAuthenticator(config, store)
+	if err != nil {
+		return nil, err
+	}
+	unary := UnaryServerInterceptor(authenticator)
+	if config.RBAC {
+		if config.DefaultRole != "" {
+			err = ValidateRole(config.DefaultRole)
+			if err != nil {
+				return nil, err
+			}
+		}
+		admins := splitList(config.Admins)
+		for _, admin := range admins {
+			err = ValidatePrincipal(admin)
+			if err != nil {
+				return nil, err
+			}
+		}
+		unary = ChainUnaryServer(unary, NewRBAC(store, config.DefaultRole, admins).UnaryServerInterceptor())
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(unary),
+		grpc.StreamInterceptor(StreamServerInterceptor(authenticator)),
+	}, nil
+}
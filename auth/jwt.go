@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	jose "gopkg.in/go-jose/go-jose.v2"
+	"gopkg.in/go-jose/go-jose.v2/jwt"
+)
+
+const (
+	// JWT_CLOCK_SKEW is how much exp and nbf may be off.
+	JWT_CLOCK_SKEW = 30 * time.Second
+	// JWKS_RELOAD_INTERVAL limits how often the JWKS file is read again when
+	// a token is signed by an unknown key.
+	JWKS_RELOAD_INTERVAL = time.Minute
+)
+
+var jwtAlgorithms = map[string]bool{
+	string(jose.RS256): true, string(jose.RS384): true, string(jose.RS512): true,
+	string(jose.ES256): true, string(jose.ES384): true, string(jose.ES512): true,
+}
+
+// JWTAuthenticator authenticates requests by bearer tokens signed by one of
+// the keys of a JWKS file, RS256, RS384, RS512, ES256, ES384 and ES512 are
+// supported. The sub claim is the name of the principal and the space
+// separated scope claim its scopes. Tokens must have an exp claim, Issuer and
+// Audience are checked unless empty. The file is read again when a token is
+// signed by an unknown key, so keys can be rotated without a restart.
+type JWTAuthenticator struct {
+	file     string
+	Issuer   string
+	Audience string
+
+	mutex  sync.Mutex
+	keys   map[string]jose.JSONWebKey
+	loaded time.Time
+}
+
+type jwtClaims struct {
+	jwt.Claims
+	Scope string
+}
+
+func NewJWTAuthenticator(jwksFile string, issuer string, audience string) (*JWTAuthenticator, error) {
+	a := &JWTAuthenticator{file:jwksFile, Issuer:issuer, Audience:audience}
+	err := a.load()
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	authorization, ok := metadataValue(ctx, "authorization")
+	if !ok || !strings.HasPrefix(strings.ToLower(authorization), "bearer ") {
+		return nil, nil
+	}
+	claims, err := a.verify(strings.TrimSpace(authorization[len("bearer "):]), time.Now())
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid token, %v", err))
+	}
+	return &Principal{Name:claims.Subject, Method:MethodJWT, Scopes:scopes(strings.Fields(claims.Scope))}, nil
+}
+
+func (a *JWTAuthenticator) verify(token string, now time.Time) (*jwtClaims, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil || len(parsed.Headers) != 1 {
+		return nil, errors.New("malformed token")
+	}
+	header := parsed.Headers[0]
+	if !jwtAlgorithms[header.Algorithm] {
+		return nil, errors.New(fmt.Sprintf("unsupported alg '%s'", header.Algorithm))
+	}
+	key, err := a.key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+		return nil, errors.New(fmt.Sprintf("key '%s' is not for %s", key.KeyID, header.Algorithm))
+	}
+	var claims jwtClaims
+	var scope struct {
+		Scope string `json:"scope"`
+	}
+	err = parsed.Claims(key.Key, &claims.Claims, &scope)
+	if err != nil {
+		return nil, errors.New("invalid signature")
+	}
+
+	if claims.Expiry == nil {
+		return nil, errors.New("missing exp")
+	}
+	expected := jwt.Expected{Issuer:a.Issuer, Time:now}
+	if a.Audience != "" {
+		expected.Audience = jwt.Audience{a.Audience}
+	}
+	err = claims.ValidateWithLeeway(expected, JWT_CLOCK_SKEW)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("missing sub")
+	}
+	claims.Scope = scope.Scope
+	return &claims, nil
+}
+
+// key returns the key with the id, the only key if the id is empty.
+func (a *JWTAuthenticator) key(kid string) (*jose.JSONWebKey, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	key, ok := a.findKey(kid)
+	if !ok && time.Since(a.loaded) >= JWKS_RELOAD_INTERVAL {
+		err := a.loadLocked()
+		if err != nil {
+			return nil, err
+		}
+		key, ok = a.findKey(kid)
+	}
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown key '%s'", kid))
+	}
+	return &key, nil
+}
+
+func (a *JWTAuthenticator) findKey(kid string) (jose.JSONWebKey, bool) {
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, true
+		}
+	}
+	key, ok := a.keys[kid]
+	return key, ok
+}
+
+func (a *JWTAuthenticator) load() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.loadLocked()
+}
+
+func (a *JWTAuthenticator) loadLocked() error {
+	data, err := ioutil.ReadFile(a.file)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Failed to read JWKS file, %v", err))
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Failed to parse JWKS file '%s', %v", a.file, err))
+	}
+	a.keys = keys
+	a.loaded = time.Now()
+	return nil
+}
+
+// parseJWKS returns the RSA and EC signing keys of the set by id, other
+// keys are skipped.
+func parseJWKS(data []byte) (map[string]jose.JSONWebKey, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]jose.JSONWebKey)
+	for _, raw := range set.Keys {
+		var kind struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+		}
+		err = json.Unmarshal(raw, &kind)
+		if err != nil {
+			return nil, err
+		}
+		if (kind.Kty != "RSA" && kind.Kty != "EC") || (kind.Use != "" && kind.Use != "sig") {
+			continue
+		}
+		var key jose.JSONWebKey
+		err = key.UnmarshalJSON(raw)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("key '%s', %v", kind.Kid, err))
+		}
+		switch key.Key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys[key.KeyID] = key
+		default:
+			return nil, errors.New(fmt.Sprintf("key '%s' is not a public key", kind.Kid))
+		}
+	}
+	return keys, nil
+}
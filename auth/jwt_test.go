@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jwtKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newJWTKeys(t *testing.T) jwtKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	return jwtKeys{rsa:rsaKey, ec:ecKey}
+}
+
+func (k jwtKeys) writeJWKS(t *testing.T) string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := map[string][]map[string]string{"keys": {
+		{"kty":"RSA", "kid":"rsa", "use":"sig", "n":b64(k.rsa.N.Bytes()), "e":b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+		{"kty":"EC", "kid":"ec", "crv":"P-256", "x":b64(k.ec.X.Bytes()), "y":b64(k.ec.Y.Bytes())},
+		{"kty":"oct", "kid":"hmac", "k":"c2VjcmV0"},
+	}}
+	data, _ := json.Marshal(jwks)
+	dir, err := ioutil.TempDir("", "jwks")
+	require.Nil(t, err)
+	file := filepath.Join(dir, "jwks.json")
+	require.Nil(t, ioutil.WriteFile(file, data, 0600))
+	return file
+}
+
+func (k jwtKeys) sign(alg string, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg":alg, "kid":kid, "typ":"JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(input))
+	var signature []byte
+	if alg == "ES256" {
+		r, s, _ := ecdsa.Sign(rand.Reader, k.ec, digest.Sum(nil))
+		signature = make([]byte, 64)
+		copy(signature[32 - len(r.Bytes()):32], r.Bytes())
+		copy(signature[64 - len(s.Bytes()):], s.Bytes())
+	} else {
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest.Sum(nil))
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	keys := newJWTKeys(t)
+	file := keys.writeJWKS(t)
+	defer os.RemoveAll(filepath.Dir(file))
+	a, err := NewJWTAuthenticator(file, "https://issuer", "feature-toggle")
+	require.Nil(t, err)
+
+	claims := map[string]interface{}{"sub":"checkout", "iss":"https://issuer", "aud":[]string{"feature-toggle"},
+		"exp":time.Now().Add(time.Hour).Unix(), "scope":"openid evaluate"}
+	for _, token := range []string{keys.sign("RS256", "rsa", claims), keys.sign("ES256", "ec", claims)} {
+		principal, err := a.Authenticate(withMetadata("authorization", "Bearer " + token))
+		require.NotNil(t, principal, "Should authenticate the token, %v", err)
+		assert.Equal(t, "checkout", principal.Name)
+		assert.Equal(t, MethodJWT, principal.Method)
+		assert.Equal(t, []string{ScopeEvaluate}, principal.Scopes)
+	}
+
+	principal, err := a.Authenticate(withMetadata(API_KEY_METADATA, "key"))
+	assert.Nil(t, principal)
+	assert.Nil(t, err, "Should leave other credentials to other authenticators")
+}
+
+func TestJWTAuthenticator__invalid(t *testing.T) {
+	keys := newJWTKeys(t)
+	file := keys.writeJWKS(t)
+	defer os.RemoveAll(filepath.Dir(file))
+	a, err := NewJWTAuthenticator(file, "https://issuer", "feature-toggle")
+	require.Nil(t, err)
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{"sub":"checkout", "iss":"https://issuer", "aud":"feature-toggle",
+			"exp":time.Now().Add(time.Hour).Unix(), "scope":"admin"}
+	}
+	expired := valid()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	notBefore := valid()
+	notBefore["nbf"] = time.Now().Add(time.Hour).Unix()
+	issuer := valid()
+	issuer["iss"] = "https://other"
+	audience := valid()
+	audience["aud"] = "other"
+	noExpiry := valid()
+	delete(noExpiry, "exp")
+	tampered := keys.sign("RS256", "rsa", valid())
+	tampered = tampered[:len(tampered) - 4] + "AAAA"
+
+	tokens := map[string]string{
+		"expired": keys.sign("RS256", "rsa", expired),
+		"not before": keys.sign("RS256", "rsa", notBefore),
+		"issuer": keys.sign("RS256", "rsa", issuer),
+		"audience": keys.sign("RS256", "rsa", audience),
+		"no exp": keys.sign("RS256", "rsa", noExpiry),
+		"unknown key": keys.sign("RS256", "other", valid()),
+		"wrong key type": keys.sign("RS256", "ec", valid()),
+		"hmac": keys.sign("HS256", "hmac", valid()),
+		"signature": tampered,
+		"malformed": "not.a-token",
+	}
+	for name, token := range tokens {
+		principal, err := a.Authenticate(withMetadata("authorization", "Bearer " + token))
+		assert.Nil(t, principal, name)
+		assert.NotNil(t, err, "Should refuse %s", name)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	_, err := parseJWKS([]byte(`{"keys":[{"kty":"EC","kid":"bad","crv":"P-256","x":"AQ","y":"AQ"}]}`))
+	assert.NotNil(t, err, "Should refuse a point not on the curve")
+
+	keys, err := parseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"enc","use":"enc","n":"AQ","e":"AQAB"}]}`))
+	require.Nil(t, err)
+	assert.Len(t, keys, 0, "Should skip encryption keys")
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// FORWARDED_CLIENT_CERT_METADATA carries the client certificate, base64
+	// encoded DER, the gateway verified when the request came in over TLS.
+	FORWARDED_CLIENT_CERT_METADATA = "x-forwarded-client-cert"
+	// GATEWAY_SECRET_METADATA carries the secret the gateway proves it is
+	// the gateway with, a forwarded certificate is only trusted along with it.
+	GATEWAY_SECRET_METADATA = "x-gateway-secret"
+)
+
+// MTLSAuthenticator authenticates requests by the client certificate the
+// TLS connection was verified with, or the one the gateway forwards. The
+// common name is the name of the principal and the organizational units that
+// name a scope its scopes.
+type MTLSAuthenticator struct {
+	gatewaySecret string
+}
+
+// NewMTLSAuthenticator returns an authenticator trusting certificates
+// forwarded along with the gateway secret, none if it is empty.
+func NewMTLSAuthenticator(gatewaySecret string) *MTLSAuthenticator {
+	return &MTLSAuthenticator{gatewaySecret:gatewaySecret}
+}
+
+func (a *MTLSAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if forwarded := md[FORWARDED_CLIENT_CERT_METADATA]; len(forwarded) > 0 {
+		return a.forwarded(forwarded, md[GATEWAY_SECRET_METADATA])
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	return certificatePrincipal(tlsInfo.State.VerifiedChains[0][0]), nil
+}
+
+func (a *MTLSAuthenticator) forwarded(certs []string, secrets []string) (*Principal, error) {
+	if a.gatewaySecret == "" || len(secrets) != 1 || len(certs) != 1 ||
+		subtle.ConstantTimeCompare([]byte(secrets[0]), []byte(a.gatewaySecret)) != 1 {
+		return nil, errors.New("Untrusted forwarded client certificate")
+	}
+	der, err := base64.StdEncoding.DecodeString(certs[0])
+	if err != nil {
+		return nil, errors.New("Malformed forwarded client certificate")
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.New("Malformed forwarded client certificate")
+	}
+	return certificatePrincipal(cert), nil
+}
+
+func certificatePrincipal(cert *x509.Certificate) *Principal {
+	return &Principal{Name:cert.Subject.CommonName, Method:MethodMTLS, Scopes:scopes(cert.Subject.OrganizationalUnit)}
+}
+
+// NewGatewaySecret returns a random secret for a gateway running in the same
+// process as the server.
+func NewGatewaySecret() (string, error) {
+	return randomToken()
+}
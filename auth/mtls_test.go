@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func newCertificate(t *testing.T, cn string, ou ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:big.NewInt(1),
+		Subject:pkix.Name{CommonName:cn, OrganizationalUnit:ou},
+		NotBefore:time.Now().Add(-time.Hour),
+		NotAfter:time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return cert
+}
+
+func TestMTLSAuthenticator(t *testing.T) {
+	cert := newCertificate(t, "checkout", "evaluate", "payments")
+	state := tls.ConnectionState{VerifiedChains:[][]*x509.Certificate{{cert}}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo:credentials.TLSInfo{State:state}})
+
+	principal, err := NewMTLSAuthenticator("").Authenticate(ctx)
+	require.NotNil(t, principal, "Should authenticate a verified certificate, %v", err)
+	assert.Equal(t, "checkout", principal.Name)
+	assert.Equal(t, MethodMTLS, principal.Method)
+	assert.Equal(t, []string{ScopeEvaluate}, principal.Scopes)
+
+	unverified := peer.NewContext(context.Background(), &peer.Peer{AuthInfo:credentials.TLSInfo{}})
+	principal, err = NewMTLSAuthenticator("").Authenticate(unverified)
+	assert.Nil(t, principal)
+	assert.Nil(t, err, "Connections without a verified certificate carry no credentials")
+}
+
+func TestMTLSAuthenticator__forwarded(t *testing.T) {
+	cert := base64.StdEncoding.EncodeToString(newCertificate(t, "ops", "admin").Raw)
+	a := NewMTLSAuthenticator("gateway-secret")
+
+	principal, err := a.Authenticate(withMetadata(FORWARDED_CLIENT_CERT_METADATA, cert, GATEWAY_SECRET_METADATA, "gateway-secret"))
+	require.NotNil(t, principal, "Should trust a certificate forwarded by the gateway, %v", err)
+	assert.Equal(t, "ops", principal.Name)
+	assert.Equal(t, []string{ScopeAdmin}, principal.Scopes)
+
+	principal, err = a.Authenticate(withMetadata(FORWARDED_CLIENT_CERT_METADATA, cert, GATEWAY_SECRET_METADATA, "guess"))
+	assert.Nil(t, principal)
+	assert.NotNil(t, err, "Should refuse a certificate forwarded with the wrong secret")
+
+	principal, err = a.Authenticate(withMetadata(FORWARDED_CLIENT_CERT_METADATA, cert))
+	assert.Nil(t, principal)
+	assert.NotNil(t, err, "Should refuse a certificate forwarded without a secret")
+
+	principal, err = NewMTLSAuthenticator("").Authenticate(withMetadata(FORWARDED_CLIENT_CERT_METADATA, cert, GATEWAY_SECRET_METADATA, ""))
+	assert.Nil(t, principal)
+	assert.NotNil(t, err, "Should not trust forwarded certificates without a gateway secret")
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/storage"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+const (
+	PermissionFeatureRead      = "feature.read"
+	PermissionFeatureCreate    = "feature.create"
+	PermissionFeatureUpdate    = "feature.update"
+	PermissionFeatureDelete    = "feature.delete"
+	PermissionToggleRuleRead   = "toggle_rule.read"
+	PermissionToggleRuleCreate = "toggle_rule.create"
+	PermissionToggleRuleUpdate = "toggle_rule.update"
+	PermissionToggleRuleDelete = "toggle_rule.delete"
+	PermissionPropertyRead     = "property.read"
+	PermissionPropertyCreate   = "property.create"
+	PermissionPropertyUpdate   = "property.update"
+	PermissionPropertyDelete   = "property.delete"
+	PermissionPolicyRead       = "policy.read"
+	PermissionPolicyWrite      = "policy.write"
+	PermissionAuditRead        = "audit.read"
+)
+
+var viewerPermissions = []string{PermissionFeatureRead, PermissionToggleRuleRead, PermissionPropertyRead}
+
+var editorPermissions = append([]string{
+	PermissionFeatureCreate, PermissionFeatureUpdate, PermissionFeatureDelete,
+	PermissionToggleRuleCreate, PermissionToggleRuleUpdate, PermissionToggleRuleDelete,
+	PermissionPropertyCreate, PermissionPropertyUpdate, PermissionPropertyDelete,
+}, viewerPermissions...)
+
+var rolePermissions = map[string][]string{
+	RoleViewer: viewerPermissions,
+	RoleEditor: editorPermissions,
+	RoleAdmin:  append([]string{PermissionPolicyRead, PermissionPolicyWrite, PermissionAuditRead}, editorPermissions...),
+}
+
+// methodPermissions is the permission each method requires, methods that
+// evaluate features require none.
+var methodPermissions = map[string]string{
+	"GetSchedule":        PermissionToggleRuleRead,
+	"ReadToggleRule":     PermissionToggleRuleRead,
+	"SearchToggleRule":   PermissionToggleRuleRead,
+	"CreateToggleRule":   PermissionToggleRuleCreate,
+	"UpdateToggleRule":   PermissionToggleRuleUpdate,
+	"DeleteToggleRule":   PermissionToggleRuleDelete,
+	"ReadFeature":        PermissionFeatureRead,
+	"SearchFeature":      PermissionFeatureRead,
+	"CreateFeature":      PermissionFeatureCreate,
+	"UpdateFeature":      PermissionFeatureUpdate,
+	"DeleteFeature":      PermissionFeatureDelete,
+	"ReadProperty":       PermissionPropertyRead,
+	"SearchProperty":     PermissionPropertyRead,
+	"CreateProperty":     PermissionPropertyCreate,
+	"UpdateProperty":     PermissionPropertyUpdate,
+	"DeleteProperty":     PermissionPropertyDelete,
+	"ListRoleBindings":   PermissionPolicyRead,
+	"ListFeatureOwners":  PermissionPolicyRead,
+	"SetRoleBinding":     PermissionPolicyWrite,
+	"DeleteRoleBinding":  PermissionPolicyWrite,
+	"AddFeatureOwner":    PermissionPolicyWrite,
+	"RemoveFeatureOwner": PermissionPolicyWrite,
+	"ListRevisions":      PermissionFeatureRead,
+	"RevertToRevision":   PermissionFeatureUpdate,
+	"SearchAuditLog":     PermissionAuditRead,
+}
+
+// RBAC authorizes authenticated calls by the role of the principal, bound in
+// the store. Changes of a feature with owners, and of its toggle rules, are
+// limited to the owners and admins.
+type RBAC struct {
+	store       storage.FeatureToggleStore
+	defaultRole string
+	admins      map[string]bool
+}
+
+// NewRBAC returns the access control of the store's role bindings.
+// Principals without a binding have the default role, none if it is empty.
+// The admins are admins whatever their binding, so there is someone to set
+// up the bindings.
+func NewRBAC(store storage.FeatureToggleStore, defaultRole string, admins []string) *RBAC {
+	r := &RBAC{store:store, defaultRole:defaultRole, admins:make(map[string]bool)}
+	for _, admin := range admins {
+		r.admins[admin] = true
+	}
+	return r
+}
+
+// ValidateRole returns an error unless role is one of Role*.
+func ValidateRole(role string) error {
+	if _, ok := rolePermissions[role]; !ok {
+		return errors.New(fmt.Sprintf("Unknown role '%s', must be '%s', '%s' or '%s'", role, RoleViewer, RoleEditor, RoleAdmin))
+	}
+	return nil
+}
+
+// ValidatePrincipal returns an error unless principal is "<method>:<name>".
+func ValidatePrincipal(principal string) error {
+	parts := strings.SplitN(principal, ":", 2)
+	if len(parts) != 2 || parts[1] == "" || (parts[0] != MethodApiKey && parts[0] != MethodJWT && parts[0] != MethodMTLS) {
+		return errors.New(fmt.Sprintf("Invalid principal '%s', must be '<%s|%s|%s>:<name>'", principal, MethodApiKey, MethodJWT, MethodMTLS))
+	}
+	return nil
+}
+
+// UnaryServerInterceptor authorizes unary calls, it must run after the
+// authentication.
+func (r *RBAC) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		err := r.Authorize(ctx, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Authorize returns a PermissionDenied status naming the missing permission
+// if the principal of the context may not make the call.
+func (r *RBAC) Authorize(ctx context.Context, fullMethod string, req interface{}) error {
+	permission := methodPermissions[strings.TrimPrefix(fullMethod, servicePrefix)]
+	if !strings.HasPrefix(fullMethod, servicePrefix) || permission == "" {
+		return nil
+	}
+	principal, ok := FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "Missing credentials")
+	}
+	role, err := r.role(principal.Id())
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if !hasPermission(role, permission) {
+		return status.Error(codes.PermissionDenied, fmt.Sprintf("'%s' lacks permission '%s'", principal.Id(), permission))
+	}
+	if role == RoleAdmin {
+		return nil
+	}
+
+	featureIds, err := r.features(req)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	for _, featureId := range featureIds {
+		owners, err := r.store.ReadFeatureOwners(featureId)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if len(*owners) > 0 && !contains(*owners, principal.Id()) {
+			return status.Error(codes.PermissionDenied, fmt.Sprintf("'%s' lacks permission '%s' on feature '%s', only its owners have it", principal.Id(), permission, featureId))
+		}
+	}
+	return nil
+}
+
+func (r *RBAC) role(principal string) (string, error) {
+	if r.admins[principal] {
+		return RoleAdmin, nil
+	}
+	binding, err := r.store.ReadRoleBinding(principal)
+	if err != nil {
+		return "", err
+	}
+	if binding == nil {
+		return r.defaultRole, nil
+	}
+	return binding.Role, nil
+}
+
+// features returns the ids of the features a call changes. Unknown features
+// and rules are left to the method to report.
+func (r *RBAC) features(req interface{}) ([]string, error) {
+	switch req := req.(type) {
+	case *api.UpdateFeatureRequest:
+		return []string{req.Feature.GetId()}, nil
+	case *api.DeleteFeatureRequest:
+		return []string{req.Id}, nil
+	case *api.CreateToggleRuleRequest:
+		return r.featureByName(req.ToggleRule.GetName())
+	case *api.UpdateToggleRuleRequest:
+		ids, err := r.featureOfRule(req.ToggleRule.GetId())
+		if err != nil {
+			return nil, err
+		}
+		paths := req.UpdateMask.GetPaths()
+		if len(paths) == 0 || contains(paths, "name") {
+			moved, err := r.featureByName(req.ToggleRule.GetName())
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, moved...)
+		}
+		return ids, nil
+	case *api.DeleteToggleRuleRequest:
+		return r.featureOfRule(req.Id)
+	case *api.RevertToRevisionRequest:
+		return []string{req.FeatureId}, nil
+	}
+	return nil, nil
+}
+
+func (r *RBAC) featureByName(name string) ([]string, error) {
+	feature, err := r.store.ReadFeatureByName(name)
+	if err != nil || feature == nil {
+		return nil, err
+	}
+	return []string{feature.Id}, nil
+}
+
+func (r *RBAC) featureOfRule(id string) ([]string, error) {
+	rule, err := r.store.ReadToggleRule(id)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+	return []string{rule.FeatureId}, nil
+}
+
+func hasPermission(role string, permission string) bool {
+	return contains(rolePermissions[role], permission)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ChainUnaryServer runs the interceptors in order, the last one calls the
+// handler.
+func ChainUnaryServer(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, h := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, h)
+			}
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type rbacFixture struct {
+	store   *storage.FeatureToggleMemStore
+	rbac    *RBAC
+	feature string
+	owned   string
+	rule    string
+}
+
+func newRBACFixture(t *testing.T) rbacFixture {
+	fs := storage.NewFeatureToggleMemStore()
+	feature, err := fs.CreateFeature(*storage.NewFeature("feature 1", true, ""))
+	require.Nil(t, err)
+	owned, err := fs.CreateFeature(*storage.NewFeature("owned", true, ""))
+	require.Nil(t, err)
+	require.Nil(t, fs.AddFeatureOwner(storage.FeatureOwner{FeatureId:*owned, Principal:"jwt:owner"}))
+	_, err = fs.CreateProperty(*storage.NewProperty("username", ""))
+	require.Nil(t, err)
+	rule, err := fs.CreateToggleRule(*storage.NewToggleRule(*owned, true, "username", "adam"))
+	require.Nil(t, err)
+
+	fs.SetRoleBinding(storage.RoleBinding{Principal:"jwt:editor", Role:RoleEditor})
+	fs.SetRoleBinding(storage.RoleBinding{Principal:"jwt:owner", Role:RoleEditor})
+	fs.SetRoleBinding(storage.RoleBinding{Principal:"jwt:admin", Role:RoleAdmin})
+	return rbacFixture{store:fs, rbac:NewRBAC(fs, RoleViewer, []string{"api_key:bootstrap"}), feature:*feature, owned:*owned, rule:*rule}
+}
+
+func (f rbacFixture) authorize(principal string, method string, req interface{}) error {
+	parts := strings.SplitN(principal, ":", 2)
+	p := &Principal{Method:parts[0], Name:parts[1]}
+	return f.rbac.Authorize(NewContext(context.Background(), p), servicePrefix + method, req)
+}
+
+func TestRBAC_roles(t *testing.T) {
+	f := newRBACFixture(t)
+
+	assert.Nil(t, f.authorize("jwt:someone", "SearchFeature", &api.SearchFeatureRequest{}), "Should give the default role")
+	err := f.authorize("jwt:someone", "CreateFeature", &api.CreateFeatureRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "'feature.create'", "Should name the missing permission")
+
+	assert.Nil(t, f.authorize("jwt:editor", "CreateFeature", &api.CreateFeatureRequest{}))
+	assert.Nil(t, f.authorize("jwt:editor", "DeleteProperty", &api.DeletePropertyRequest{Name:"username"}))
+	err = f.authorize("jwt:editor", "SetRoleBinding", &api.SetRoleBindingRequest{})
+	assert.Contains(t, status.Convert(err).Message(), "'policy.write'")
+
+	assert.Nil(t, f.authorize("jwt:admin", "SetRoleBinding", &api.SetRoleBindingRequest{}))
+	assert.Nil(t, f.authorize("api_key:bootstrap", "SetRoleBinding", &api.SetRoleBindingRequest{}), "Configured admins should not need a binding")
+	assert.Nil(t, f.authorize("jwt:someone", "EvaluateFeatures", &api.GetFeaturesByPropertiesRequest{}), "Evaluation should not need a permission")
+
+	noDefault := NewRBAC(f.store, "", nil)
+	err = noDefault.Authorize(NewContext(context.Background(), &Principal{Name:"someone", Method:MethodJWT}), servicePrefix + "SearchFeature", &api.SearchFeatureRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "Should deny principals without a role")
+}
+
+func TestRBAC_feature_owners(t *testing.T) {
+	f := newRBACFixture(t)
+
+	assert.Nil(t, f.authorize("jwt:editor", "UpdateFeature", &api.UpdateFeatureRequest{Feature:&api.Feature{Id:f.feature}}), "Features without owners are open to editors")
+
+	err := f.authorize("jwt:editor", "UpdateFeature", &api.UpdateFeatureRequest{Feature:&api.Feature{Id:f.owned}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "'feature.update'")
+	err = f.authorize("jwt:editor", "CreateToggleRule", &api.CreateToggleRuleRequest{ToggleRule:&api.ToggleRule{Name:"owned"}})
+	assert.Contains(t, status.Convert(err).Message(), "'toggle_rule.create'")
+	err = f.authorize("jwt:editor", "DeleteToggleRule", &api.DeleteToggleRuleRequest{Id:f.rule})
+	assert.Contains(t, status.Convert(err).Message(), "'toggle_rule.delete'")
+	err = f.authorize("jwt:editor", "UpdateToggleRule", &api.UpdateToggleRuleRequest{ToggleRule:&api.ToggleRule{Id:f.rule, Enabled:true},
+		UpdateMask:&field_mask.FieldMask{Paths:[]string{"enabled"}}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "Should check the feature of the rule")
+	err = f.authorize("jwt:editor", "RevertToRevision", &api.RevertToRevisionRequest{FeatureId:f.owned, Revision:1})
+	assert.Contains(t, status.Convert(err).Message(), "'feature.update'")
+
+	assert.Nil(t, f.authorize("jwt:owner", "DeleteFeature", &api.DeleteFeatureRequest{Id:f.owned}))
+	assert.Nil(t, f.authorize("jwt:owner", "DeleteToggleRule", &api.DeleteToggleRuleRequest{Id:f.rule}))
+	assert.Nil(t, f.authorize("jwt:admin", "DeleteFeature", &api.DeleteFeatureRequest{Id:f.owned}), "Admins should not need to own features")
+
+	err = f.authorize("jwt:editor", "UpdateToggleRule", &api.UpdateToggleRuleRequest{ToggleRule:&api.ToggleRule{Id:"unknown", Name:"owned"},
+		UpdateMask:&field_mask.FieldMask{Paths:[]string{"name"}}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "Should not move rules to a feature owned by others")
+}
+
+func TestChainUnaryServer(t *testing.T) {
+	calls := []string{}
+	interceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+	chain := ChainUnaryServer(interceptor("first"), interceptor("second"))
+	res, err := chain(context.Background(), "req", &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return req, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "req", res)
+	assert.Equal(t, []string{"first", "second", "handler"}, calls)
+}
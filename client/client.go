@@ -0,0 +1,205 @@
+// Package client is a Go client of the feature toggle service.
+//
+//	c := client.New(api.NewFeatureToggleServiceClient(conn), client.Options{
+//		CacheTTL: time.Minute,
+//		Defaults: map[string]bool{"new-checkout": false},
+//	})
+//	defer c.Close()
+//	if c.IsEnabled(ctx, "new-checkout", map[string]string{"username": "adam"}) {
+//		...
+//	}
+//
+// With LocalEvaluation the client pulls all toggle rules and evaluates them in
+// process, so IsEnabled never makes a network call. In both modes the client
+// keeps using the last known data while the service can't be reached and
+// falls back to the defaults when it has none.
+package client
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+	"golang.org/x/net/context"
+)
+
+const (
+	DEFAULT_REFRESH_INTERVAL  = 30 * time.Second
+	DEFAULT_MAX_CACHE_ENTRIES = 10000
+)
+
+type Options struct {
+	// CacheTTL is how long the features of a context are used before they are
+	// fetched again, 0 fetches them on every call. The last fetched features
+	// are kept regardless and used while the service can't be reached.
+	CacheTTL time.Duration
+	// MaxCacheEntries bounds the number of cached contexts.
+	MaxCacheEntries int
+	// LocalEvaluation pulls all toggle rules every RefreshInterval and
+	// evaluates them in process. Rules start and expire on time in between.
+	LocalEvaluation bool
+	RefreshInterval time.Duration
+	// Defaults is the state of each feature when it can't be evaluated,
+	// features without a default are disabled.
+	Defaults map[string]bool
+	// OnError is called with the errors the client recovers from.
+	OnError func(error)
+}
+
+type Client struct {
+	api     api.FeatureToggleServiceClient
+	options Options
+
+	mutex  sync.RWMutex
+	cache  map[string]cacheEntry
+	tree   *featuretree.ToggleRuleTree
+	rules  *ruleSet
+	timer  *time.Timer
+	closed bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type cacheEntry struct {
+	features map[string]bool
+	fetched  time.Time
+}
+
+// New returns a client of the service. With LocalEvaluation it loads the
+// toggle rules in the background and keeps them up to date until Close is
+// called.
+func New(client api.FeatureToggleServiceClient, options Options) *Client {
+	if options.MaxCacheEntries <= 0 {
+		options.MaxCacheEntries = DEFAULT_MAX_CACHE_ENTRIES
+	}
+	if options.RefreshInterval <= 0 {
+		options.RefreshInterval = DEFAULT_REFRESH_INTERVAL
+	}
+	c := &Client{api:client, options:options, cache:make(map[string]cacheEntry), stop:make(chan struct{})}
+	if options.LocalEvaluation {
+		go c.refreshEvery(options.RefreshInterval)
+	}
+	return c
+}
+
+// Close stops the background refresh of the toggle rules.
+func (c *Client) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// IsEnabled tells if the feature is enabled for the properties. It never
+// fails, when the features of the context are unknown the default of the
+// feature is returned.
+func (c *Client) IsEnabled(ctx context.Context, feature string, properties map[string]string) bool {
+	features, ok := c.features(ctx, properties)
+	if !ok {
+		return c.options.Defaults[feature]
+	}
+	return features[feature]
+}
+
+// Features returns the features enabled for the properties, sorted. The
+// second result is false if they are unknown and the defaults were used.
+func (c *Client) Features(ctx context.Context, properties map[string]string) ([]string, bool) {
+	features, ok := c.features(ctx, properties)
+	if !ok {
+		features = c.options.Defaults
+	}
+	res := []string{}
+	for feature, enabled := range features {
+		if enabled {
+			res = append(res, feature)
+		}
+	}
+	sort.Strings(res)
+	return res, ok
+}
+
+func (c *Client) features(ctx context.Context, properties map[string]string) (map[string]bool, bool) {
+	if c.options.LocalEvaluation {
+		c.mutex.RLock()
+		tree := c.tree
+		c.mutex.RUnlock()
+		if tree == nil {
+			return nil, false
+		}
+		return toSet(tree.FindFeatures(properties)), true
+	}
+
+	key := cacheKey(properties)
+	c.mutex.RLock()
+	entry, cached := c.cache[key]
+	c.mutex.RUnlock()
+	if cached && time.Since(entry.fetched) < c.options.CacheTTL {
+		return entry.features, true
+	}
+
+	res, err := c.api.GetFeaturesForProperties(ctx, &api.GetFeaturesByPropertiesRequest{Properties:properties})
+	if err != nil {
+		c.onError(err)
+		// the last known features, however old
+		return entry.features, cached
+	}
+	// kept even with CacheTTL 0, as the last known features
+	entry = cacheEntry{features:toSet(res.Features), fetched:time.Now()}
+	c.mutex.Lock()
+	if _, ok := c.cache[key]; !ok && len(c.cache) >= c.options.MaxCacheEntries {
+		c.evict()
+	}
+	c.cache[key] = entry
+	c.mutex.Unlock()
+	return entry.features, true
+}
+
+// evict removes the entry fetched first, must be called with the mutex held.
+func (c *Client) evict() {
+	oldestKey := ""
+	var oldest time.Time
+	for key, entry := range c.cache {
+		if oldestKey == "" || entry.fetched.Before(oldest) {
+			oldestKey = key
+			oldest = entry.fetched
+		}
+	}
+	delete(c.cache, oldestKey)
+}
+
+func (c *Client) onError(err error) {
+	if c.options.OnError != nil {
+		c.options.OnError(err)
+	}
+}
+
+// cacheKey is the properties sorted by name.
+func cacheKey(properties map[string]string) string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name + "\x00" + properties[name])
+	}
+	return strings.Join(parts, "\x01")
+}
+
+func toSet(features []string) map[string]bool {
+	set := make(map[string]bool, len(features))
+	for _, f := range features {
+		set[f] = true
+	}
+	return set
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+)
+
+// fakeService answers the calls the client makes, the rest of the
+// interface panics.
+type fakeService struct {
+	api.FeatureToggleServiceClient
+	mutex       sync.Mutex
+	down        bool
+	calls       int
+	evaluations int
+	features    []*api.Feature
+	properties  []*api.Property
+	toggleRules []*api.ToggleRule
+	enabled     map[string][]string
+}
+
+func (f *fakeService) call() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.calls++
+	if f.down {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (f *fakeService) setDown(down bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.down = down
+}
+
+func (f *fakeService) GetFeaturesForProperties(ctx context.Context, in *api.GetFeaturesByPropertiesRequest, opts ...grpc.CallOption) (*api.GetFeaturesByPropertiesResponse, error) {
+	f.mutex.Lock()
+	f.evaluations++
+	f.mutex.Unlock()
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	return &api.GetFeaturesByPropertiesResponse{Features:f.enabled[in.Properties["username"]]}, nil
+}
+
+func (f *fakeService) SearchProperty(ctx context.Context, in *api.SearchPropertyRequest, opts ...grpc.CallOption) (*api.SearchPropertyResponse, error) {
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	return &api.SearchPropertyResponse{Properties:f.properties}, nil
+}
+
+func (f *fakeService) SearchFeature(ctx context.Context, in *api.SearchFeatureRequest, opts ...grpc.CallOption) (*api.SearchFeatureResponse, error) {
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	return &api.SearchFeatureResponse{Features:f.features}, nil
+}
+
+func (f *fakeService) SearchToggleRule(ctx context.Context, in *api.SearchToggleRuleRequest, opts ...grpc.CallOption) (*api.SearchToggleRuleResponse, error) {
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	return &api.SearchToggleRuleResponse{ToggleRules:f.toggleRules}, nil
+}
+
+func TestIsEnabled_cached(t *testing.T) {
+	service := &fakeService{enabled:map[string][]string{"adam": {"feature 1"}}}
+	c := New(service, Options{CacheTTL:time.Hour})
+	defer c.Close()
+	ctx := context.Background()
+
+	assert.True(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"}))
+	assert.False(t, c.IsEnabled(ctx, "feature 2", map[string]string{"username":"adam"}))
+	assert.False(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"bert"}))
+	assert.Equal(t, 2, service.calls)
+}
+
+func TestIsEnabled_ttl_expired(t *testing.T) {
+	service := &fakeService{enabled:map[string][]string{"adam": {"feature 1"}}}
+	c := New(service, Options{CacheTTL:time.Millisecond})
+	defer c.Close()
+	ctx := context.Background()
+
+	c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"})
+	time.Sleep(5 * time.Millisecond)
+	c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"})
+	assert.Equal(t, 2, service.calls)
+}
+
+func TestIsEnabled_unreachable(t *testing.T) {
+	service := &fakeService{enabled:map[string][]string{"adam": {"feature 1"}}}
+	errs := 0
+	c := New(service, Options{
+		CacheTTL:time.Millisecond,
+		Defaults:map[string]bool{"feature 2": true},
+		OnError:func(error) { errs++ },
+	})
+	defer c.Close()
+	ctx := context.Background()
+
+	assert.True(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"}))
+	service.setDown(true)
+	time.Sleep(5 * time.Millisecond)
+
+	// last known features of the context
+	assert.True(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"}))
+	assert.False(t, c.IsEnabled(ctx, "feature 2", map[string]string{"username":"adam"}))
+	// defaults for an unknown context
+	assert.False(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"bert"}))
+	assert.True(t, c.IsEnabled(ctx, "feature 2", map[string]string{"username":"bert"}))
+	features, known := c.Features(ctx, map[string]string{"username":"bert"})
+	assert.False(t, known)
+	assert.Equal(t, []string{"feature 2"}, features)
+	assert.Equal(t, 5, errs)
+}
+
+func TestIsEnabled_unreachable_without_ttl(t *testing.T) {
+	service := &fakeService{enabled:map[string][]string{"adam": {"feature 1"}}}
+	c := New(service, Options{})
+	defer c.Close()
+	ctx := context.Background()
+
+	assert.True(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"}))
+	assert.True(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"}))
+	assert.Equal(t, 2, service.calls, "Should fetch on every call without a ttl")
+
+	service.setDown(true)
+	assert.True(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"}), "Should use the last fetched features")
+}
+
+func TestIsEnabled_max_cache_entries(t *testing.T) {
+	service := &fakeService{}
+	c := New(service, Options{CacheTTL:time.Hour, MaxCacheEntries:2})
+	defer c.Close()
+	ctx := context.Background()
+
+	for _, username := range []string{"adam", "bert", "cecil"} {
+		c.IsEnabled(ctx, "feature 1", map[string]string{"username":username})
+	}
+	assert.Len(t, c.cache, 2)
+	_, cached := c.cache[cacheKey(map[string]string{"username":"adam"})]
+	assert.False(t, cached)
+}
+
+func TestCacheKey(t *testing.T) {
+	assert.Equal(t, cacheKey(map[string]string{"a":"1", "b":"2"}), cacheKey(map[string]string{"b":"2", "a":"1"}))
+	assert.NotEqual(t, cacheKey(map[string]string{"a":"1b"}), cacheKey(map[string]string{"a":"1", "b":""}))
+}
+
+func TestIsEnabled_local_evaluation(t *testing.T) {
+	expired, _ := ptypes.TimestampProto(time.Now().Add(-time.Hour))
+	service := &fakeService{
+		properties:[]*api.Property{{Name:"username"}, {Name:"country"}},
+		features:[]*api.Feature{
+			{Name:"feature 1", Enabled:true},
+			{Name:"feature 2", Enabled:true, Variants:[]*api.Variant{{Name:"blue", Type:api.VariantType_STRING, Value:"blue"}}},
+		},
+		toggleRules:[]*api.ToggleRule{
+			{Id:"1", Name:"feature 1", Enabled:true, Properties:map[string]string{"username":"adam"}},
+			{Id:"2", Name:"feature 2", Enabled:true, Properties:map[string]string{"country":"se"}},
+			{Id:"3", Name:"feature 2", Enabled:true, Properties:map[string]string{"country":"no"}, Expires:expired},
+			// the feature is disabled
+			{Id:"4", Name:"feature 3", Enabled:true, Properties:map[string]string{"username":"adam"}},
+		},
+	}
+	c := New(service, Options{LocalEvaluation:true, RefreshInterval:time.Hour})
+	defer c.Close()
+	ctx := context.Background()
+	require.Nil(t, c.Refresh(ctx))
+
+	assert.True(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam", "country":"dk"}))
+	assert.True(t, c.IsEnabled(ctx, "feature 2", map[string]string{"username":"bert", "country":"se"}))
+	assert.False(t, c.IsEnabled(ctx, "feature 2", map[string]string{"username":"bert", "country":"no"}))
+	assert.False(t, c.IsEnabled(ctx, "feature 3", map[string]string{"username":"adam", "country":"dk"}))
+	assert.Equal(t, 0, service.evaluations)
+
+	// the last tree is kept while the service is down
+	service.setDown(true)
+	assert.NotNil(t, c.Refresh(ctx))
+	assert.True(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam", "country":"dk"}))
+}
+
+func TestIsEnabled_local_evaluation_not_loaded(t *testing.T) {
+	service := &fakeService{down:true}
+	c := New(service, Options{LocalEvaluation:true, Defaults:map[string]bool{"feature 1": true}})
+	defer c.Close()
+
+	assert.True(t, c.IsEnabled(context.Background(), "feature 1", map[string]string{"username":"adam"}))
+	assert.False(t, c.IsEnabled(context.Background(), "feature 2", map[string]string{"username":"adam"}))
+}
+
+func TestIsEnabled_local_evaluation_schedule(t *testing.T) {
+	starts, _ := ptypes.TimestampProto(time.Now().Add(50 * time.Millisecond))
+	service := &fakeService{
+		properties:[]*api.Property{{Name:"username"}},
+		features:[]*api.Feature{{Name:"feature 1", Enabled:true}},
+		toggleRules:[]*api.ToggleRule{{Id:"1", Name:"feature 1", Enabled:true, Properties:map[string]string{"username":"adam"}, Starts:starts}},
+	}
+	c := New(service, Options{LocalEvaluation:true, RefreshInterval:time.Hour})
+	defer c.Close()
+	ctx := context.Background()
+	require.Nil(t, c.Refresh(ctx))
+
+	assert.False(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"}), "Should not be active before it starts")
+	time.Sleep(200 * time.Millisecond)
+	assert.True(t, c.IsEnabled(ctx, "feature 1", map[string]string{"username":"adam"}), "Should be active without a refresh")
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+	"golang.org/x/net/context"
+)
+
+// ruleSet is what the client pulled from the service, the rules may not have
+// started yet.
+type ruleSet struct {
+	propertyNames []string
+	rules         []featuretree.ToggleRule
+	variants      []featuretree.FeatureVariants
+}
+
+// Refresh pulls the enabled features, their toggle rules and the properties
+// and swaps in a new tree, the same one the service evaluates. On failure the
+// current tree is kept.
+func (c *Client) Refresh(ctx context.Context) error {
+	rules, err := c.loadRules(ctx)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rules = rules
+	c.rebuildLocked()
+	return nil
+}
+
+// rebuild swaps in a tree of the rules active now, when a rule starts or
+// expires.
+func (c *Client) rebuild() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rebuildLocked()
+}
+
+func (c *Client) rebuildLocked() {
+	if c.rules == nil {
+		return
+	}
+	now := time.Now()
+	builder := featuretree.NewTreeBuilder(c.rules.propertyNames)
+	for _, fv := range c.rules.variants {
+		err := builder.SetVariants(fv)
+		if err != nil {
+			c.onError(err)
+		}
+	}
+	for _, rule := range featuretree.ActiveRules(c.rules.rules, now) {
+		err := builder.AddFeature(rule)
+		if err != nil {
+			c.onError(err)
+		}
+	}
+	c.tree = builder.Build()
+
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	next := featuretree.NextChange(c.rules.rules, now)
+	if !next.IsZero() && !c.closed {
+		c.timer = time.AfterFunc(next.Sub(now), c.rebuild)
+	}
+}
+
+func (c *Client) refreshEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := c.Refresh(ctx)
+		cancel()
+		if err != nil {
+			c.onError(err)
+		}
+		select {
+		case <-ticker.C:
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *Client) loadRules(ctx context.Context) (*ruleSet, error) {
+	properties, err := c.api.SearchProperty(ctx, &api.SearchPropertyRequest{})
+	if err != nil {
+		return nil, err
+	}
+	features, err := c.api.SearchFeature(ctx, &api.SearchFeatureRequest{Enabled:&wrappers.BoolValue{Value:true}})
+	if err != nil {
+		return nil, err
+	}
+	rules, err := c.api.SearchToggleRule(ctx, &api.SearchToggleRuleRequest{Enabled:&wrappers.BoolValue{Value:true}})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ruleSet{propertyNames:[]string{}, rules:[]featuretree.ToggleRule{}, variants:[]featuretree.FeatureVariants{}}
+	for _, p := range properties.Properties {
+		res.propertyNames = append(res.propertyNames, p.Name)
+	}
+
+	enabled := make(map[string]*api.Feature)
+	for _, f := range features.Features {
+		enabled[f.Name] = f
+		if len(f.Variants) > 0 {
+			res.variants = append(res.variants, featuretree.FeatureVariants{Feature:f.Name, Variants:fromApiVariants(f.Variants), Default:f.DefaultVariant})
+		}
+	}
+	now := time.Now()
+	for _, r := range rules.ToggleRules {
+		feature, ok := enabled[r.Name]
+		if !ok {
+			continue
+		}
+		rule := fromApiToggleRule(r).Within(fromApiTime(feature.Starts), fromApiTime(feature.Expires))
+		if !rule.ExpiredAt(now) {
+			res.rules = append(res.rules, rule)
+		}
+	}
+	return res, nil
+}
+
+func fromApiToggleRule(rule *api.ToggleRule) featuretree.ToggleRule {
+	variants := []featuretree.WeightedVariant{}
+	for _, v := range rule.Variants {
+		variants = append(variants, featuretree.WeightedVariant{Variant:v.Variant, Weight:int(v.Weight)})
+	}
+	return featuretree.ToggleRule{
+		Id:rule.Id,
+		Name:rule.Name,
+		Properties:rule.Properties,
+		RolloutPercentage:int(rule.RolloutPercentage),
+		RolloutProperty:rule.RolloutProperty,
+		Operators:rule.Operators,
+		Deny:rule.Deny,
+		Priority:int(rule.Priority),
+		Variants:variants,
+		Starts:fromApiTime(rule.Starts),
+		Expires:fromApiTime(rule.Expires),
+	}
+}
+
+// fromApiTime returns the zero time for unset and invalid times.
+func fromApiTime(ts *timestamp.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func fromApiVariants(variants []*api.Variant) []featuretree.Variant {
+	res := []featuretree.Variant{}
+	for _, v := range variants {
+		res = append(res, featuretree.Variant{Name:v.Name, Type:strings.ToLower(v.Type.String()), Value:v.Value})
+	}
+	return res
+}
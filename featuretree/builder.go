@@ -0,0 +1,146 @@
+package featuretree
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// TreeBuilder collects toggle rules and produces immutable ToggleRuleTree
+// snapshots. A TreeBuilder is not safe for concurrent use, the snapshots it
+// builds are.
+type TreeBuilder struct {
+	tree  ToggleRuleTree
+	rules map[string]ToggleRule
+}
+
+func NewTreeBuilder(propertyNames []string) *TreeBuilder {
+	names := make([]string, len(propertyNames))
+	copy(names, propertyNames)
+	tree := ToggleRuleTree{root:Node{}, propertyNames:names, variants:make(map[string]FeatureVariants)}
+	return &TreeBuilder{tree, make(map[string]ToggleRule)}
+}
+
+// SetVariants sets the variants of a feature, replacing any set before.
+func (builder *TreeBuilder) SetVariants(fv FeatureVariants) error {
+	err := ValidateFeatureVariants(fv)
+	if err != nil {
+		return errors.New("Ignoring variants. " + err.Error())
+	}
+	variants := make([]Variant, len(fv.Variants))
+	copy(variants, fv.Variants)
+	fv.Variants = variants
+	builder.tree.variants[fv.Feature] = fv
+	return nil
+}
+
+// AddFeature adds the rule to the tree. Rules with an id can later be removed
+// or replaced, adding a rule with an id already in the tree replaces it.
+func (builder *TreeBuilder) AddFeature(rule ToggleRule) error {
+	err := builder.tree.validateToggleRule(rule)
+	if err != nil {
+		return errors.New("Ignoring feature. " + err.Error())
+	}
+	if rule.Id != "" {
+		builder.RemoveRule(rule.Id)
+		builder.rules[rule.Id] = copyRule(rule)
+	}
+	builder.tree.root.addFeature(builder.tree.propertyNames, rule)
+	return nil
+}
+
+// RemoveRule removes the features added by the rule with the given id and
+// prunes branches left empty. Features also added by other rules on the same
+// path are kept. It returns false if the rule is not in the tree.
+func (builder *TreeBuilder) RemoveRule(id string) bool {
+	rule, ok := builder.rules[id]
+	if !ok {
+		return false
+	}
+	delete(builder.rules, id)
+	builder.tree.root.removeRule(builder.tree.propertyNames, rule)
+	return true
+}
+
+// ReplaceRule replaces the rule with the same id. The old rule is kept if the
+// new one is invalid.
+func (builder *TreeBuilder) ReplaceRule(rule ToggleRule) error {
+	if rule.Id == "" {
+		return errors.New("Can't replace a rule without id.")
+	}
+	return builder.AddFeature(rule)
+}
+
+func copyRule(rule ToggleRule) ToggleRule {
+	props := make(Properties)
+	for k, v := range rule.Properties {
+		props[k] = v
+	}
+	rule.Properties = props
+	if rule.Operators != nil {
+		operators := make(map[string]string)
+		for k, v := range rule.Operators {
+			operators[k] = v
+		}
+		rule.Operators = operators
+	}
+	if rule.Variants != nil {
+		variants := make([]WeightedVariant, len(rule.Variants))
+		copy(variants, rule.Variants)
+		rule.Variants = variants
+	}
+	return rule
+}
+
+// Build returns a snapshot of the rules added so far. The snapshot shares no
+// state with the builder, so the builder can keep changing while the snapshot
+// is read from many goroutines.
+func (builder *TreeBuilder) Build() *ToggleRuleTree {
+	names := make([]string, len(builder.tree.propertyNames))
+	copy(names, builder.tree.propertyNames)
+	variants := make(map[string]FeatureVariants, len(builder.tree.variants))
+	for k, v := range builder.tree.variants {
+		variants[k] = v
+	}
+	return &ToggleRuleTree{*builder.tree.root.copy(), names, variants}
+}
+
+func (node *Node) copy() *Node {
+	n := &Node{value:node.value, condition:node.condition}
+	if node.features != nil {
+		n.features = make([]string, len(node.features))
+		copy(n.features, node.features)
+	}
+	if node.rules != nil {
+		n.rules = make([]ruleFeature, len(node.rules))
+		copy(n.rules, node.rules)
+	}
+	if node.nodes != nil {
+		n.nodes = make(NodeMap, len(node.nodes))
+		for k, v := range node.nodes {
+			n.nodes[k] = v.copy()
+		}
+	}
+	if node.conditions != nil {
+		n.conditions = make(NodeMap, len(node.conditions))
+		for k, v := range node.conditions {
+			n.conditions[k] = v.copy()
+		}
+	}
+	return n
+}
+
+// AtomicTree holds the current snapshot. Load is lock-free and can be called
+// while a new snapshot is built and stored.
+type AtomicTree struct {
+	value atomic.Value
+}
+
+func (a *AtomicTree) Load() *ToggleRuleTree {
+	tree, _ := a.value.Load().(*ToggleRuleTree)
+	return tree
+}
+
+func (a *AtomicTree) Store(tree *ToggleRuleTree) {
+	a.value.Store(tree)
+}
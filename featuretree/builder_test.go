@@ -0,0 +1,118 @@
+package featuretree
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild_snapshot_is_not_changed_by_builder(t *testing.T) {
+	builder := NewTreeBuilder([]string{"username"})
+	builder.AddFeature(ToggleRule{Name: "feature 1", Properties: Properties{"username": "adam"}})
+	tree := builder.Build()
+
+	builder.AddFeature(ToggleRule{Name: "feature 2", Properties: Properties{"username": "adam"}})
+
+	assert.Equal(t, []string{"feature 1"}, tree.FindFeatures(Properties{"username": "adam"}), "snapshot should only have the rules added before Build")
+	assert.Equal(t, 2, len(builder.Build().FindFeatures(Properties{"username": "adam"})), "new snapshot should have both rules")
+}
+
+func TestBuild_property_names_are_copied(t *testing.T) {
+	names := []string{"username"}
+	tree := NewTreeBuilder(names).Build()
+
+	names[0] = "changed"
+
+	assert.Equal(t, []string{"username"}, tree.propertyNames, "tree should not share property names with the caller")
+}
+
+func TestAtomicTree_concurrent_find_while_rebuilding(t *testing.T) {
+	var current AtomicTree
+	current.Store(NewFeatureTree([]string{"userid", "usertype"}))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				features := current.Load().FindFeatures(Properties{"userid": fmt.Sprintf("%d", r), "usertype": "beta"})
+				for _, f := range features {
+					if f != "beta feature" && f != fmt.Sprintf("feature %d", r) {
+						t.Errorf("unexpected feature %s", f)
+					}
+				}
+			}
+		}(r)
+	}
+
+	builder := NewTreeBuilder([]string{"userid", "usertype"})
+	for i := 0; i < 200; i++ {
+		builder.AddFeature(ToggleRule{Name: fmt.Sprintf("feature %d", i % 8), Properties: Properties{"userid": fmt.Sprintf("%d", i % 8)}})
+		builder.AddFeature(ToggleRule{Name: "beta feature", Properties: Properties{"usertype": "beta"}})
+		current.Store(builder.Build())
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, 2, len(current.Load().FindFeatures(Properties{"userid": "3", "usertype": "beta"})))
+}
+
+func TestRemoveRule(t *testing.T) {
+	builder := NewTreeBuilder([]string{"username", "usertype"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"username": "adam"}})
+	builder.AddFeature(ToggleRule{Id: "rule 2", Name: "feature 2", Properties: Properties{"usertype": "beta"}})
+
+	assert.True(t, builder.RemoveRule("rule 1"), "should remove an existing rule")
+	assert.False(t, builder.RemoveRule("rule 1"), "should not remove a rule twice")
+
+	tree := builder.Build()
+	assert.Empty(t, tree.FindFeatures(Properties{"username": "adam"}), "removed rule should not match")
+	assert.Equal(t, []string{"feature 2"}, tree.FindFeatures(Properties{"username": "adam", "usertype": "beta"}))
+	assert.Nil(t, tree.root.nodes["adam"], "empty branch should be pruned")
+	assert.NotNil(t, tree.root.nodes["*"], "branch of other rule should be kept")
+}
+
+func TestRemoveRule_same_feature_on_same_path(t *testing.T) {
+	builder := NewTreeBuilder([]string{"username"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"username": "adam"}})
+	builder.AddFeature(ToggleRule{Id: "rule 2", Name: "feature 1", Properties: Properties{"username": "adam"}})
+
+	builder.RemoveRule("rule 1")
+
+	assert.Equal(t, []string{"feature 1"}, builder.Build().FindFeatures(Properties{"username": "adam"}), "feature should be kept while another rule enables it")
+
+	builder.RemoveRule("rule 2")
+
+	assert.Empty(t, builder.Build().FindFeatures(Properties{"username": "adam"}), "feature should be removed with the last rule")
+	assert.Empty(t, builder.Build().root.nodes, "all branches should be pruned")
+}
+
+func TestReplaceRule(t *testing.T) {
+	builder := NewTreeBuilder([]string{"username"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"username": "adam"}})
+
+	err := builder.ReplaceRule(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"username": "bert"}})
+
+	assert.Nil(t, err)
+	tree := builder.Build()
+	assert.Empty(t, tree.FindFeatures(Properties{"username": "adam"}), "old path should not match")
+	assert.Equal(t, []string{"feature 1"}, tree.FindFeatures(Properties{"username": "bert"}), "new path should match")
+}
+
+func TestReplaceRule_invalid_keeps_old_rule(t *testing.T) {
+	builder := NewTreeBuilder([]string{"username"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"username": "adam"}})
+
+	err := builder.ReplaceRule(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"unknown": "adam"}})
+
+	assert.NotNil(t, err, "should not replace with a rule with unknown properties")
+	assert.Equal(t, []string{"feature 1"}, builder.Build().FindFeatures(Properties{"username": "adam"}))
+}
@@ -0,0 +1,343 @@
+package featuretree
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Operators of a rule property. The rule property value is the operand,
+// lists are comma separated.
+const (
+	OperatorEquals         = "eq"     // exact match, the default
+	OperatorIn             = "in"     // a,b,c
+	OperatorNotIn          = "not_in" // a,b,c
+	OperatorRegex          = "regex"  // RE2 expression, unanchored
+	OperatorLessThan       = "lt"     // number
+	OperatorLessOrEqual    = "lte"    // number
+	OperatorGreaterThan    = "gt"     // number
+	OperatorGreaterOrEqual = "gte"    // number
+	OperatorRange          = "range"  // min..max, inclusive, either side may be left out
+	OperatorSemver         = "semver" // >=1.2.0 <2.0.0, ^1.2.0, ~1.2.0 or 1.2.3
+	OperatorPrefix         = "prefix"
+	OperatorSuffix         = "suffix"
+	OperatorCidr           = "cidr" // 10.0.0.0/8,192.168.0.0/16
+)
+
+// condition is a compiled rule property operator. Exact matches don't use
+// conditions, they are looked up directly in the node map. matchAbsent is
+// the result when the context doesn't have the property, only a value that
+// is absent is not in a list.
+type condition struct {
+	operator    string
+	operand     string
+	match       func(value string) bool
+	matchAbsent bool
+}
+
+// matches tells if the condition holds for the value of a property, present
+// is false if the context doesn't have the property.
+func (c *condition) matches(value string, present bool) bool {
+	if !present {
+		return c.matchAbsent
+	}
+	return c.match(value)
+}
+
+func isExact(operator string) bool {
+	return operator == "" || operator == OperatorEquals
+}
+
+func conditionKey(operator string, operand string) string {
+	return operator + " " + operand
+}
+
+// ValidateCondition returns an error if the operator is unknown or the
+// operand can't be used with it.
+func ValidateCondition(operator string, operand string) error {
+	if isExact(operator) {
+		return nil
+	}
+	_, err := compileCondition(operator, operand)
+	return err
+}
+
+func compileCondition(operator string, operand string) (*condition, error) {
+	c := &condition{operator:operator, operand:operand}
+	switch operator {
+	case OperatorIn, OperatorNotIn:
+		values := make(map[string]bool)
+		for _, v := range splitList(operand) {
+			values[v] = true
+		}
+		in := operator == OperatorIn
+		c.match = func(value string) bool {
+			return values[value] == in
+		}
+		c.matchAbsent = !in
+	case OperatorRegex:
+		re, err := regexp.Compile(operand)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid regex '%s', %v", operand, err))
+		}
+		c.match = re.MatchString
+	case OperatorLessThan, OperatorLessOrEqual, OperatorGreaterThan, OperatorGreaterOrEqual:
+		limit, err := strconv.ParseFloat(operand, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid number '%s' for operator %s", operand, operator))
+		}
+		c.match = func(value string) bool {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false
+			}
+			switch operator {
+			case OperatorLessThan:
+				return v < limit
+			case OperatorLessOrEqual:
+				return v <= limit
+			case OperatorGreaterThan:
+				return v > limit
+			}
+			return v >= limit
+		}
+	case OperatorRange:
+		match, err := compileRange(operand)
+		if err != nil {
+			return nil, err
+		}
+		c.match = match
+	case OperatorSemver:
+		match, err := compileSemverConstraint(operand)
+		if err != nil {
+			return nil, err
+		}
+		c.match = match
+	case OperatorPrefix:
+		c.match = func(value string) bool {
+			return strings.HasPrefix(value, operand)
+		}
+	case OperatorSuffix:
+		c.match = func(value string) bool {
+			return strings.HasSuffix(value, operand)
+		}
+	case OperatorCidr:
+		nets := []*net.IPNet{}
+		for _, cidr := range splitList(operand) {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid CIDR '%s', %v", cidr, err))
+			}
+			nets = append(nets, ipNet)
+		}
+		c.match = func(value string) bool {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return false
+			}
+			for _, ipNet := range nets {
+				if ipNet.Contains(ip) {
+					return true
+				}
+			}
+			return false
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown operator '%s'.", operator))
+	}
+	return c, nil
+}
+
+func splitList(operand string) []string {
+	values := []string{}
+	for _, v := range strings.Split(operand, ",") {
+		values = append(values, strings.TrimSpace(v))
+	}
+	return values
+}
+
+func compileRange(operand string) (func(string) bool, error) {
+	parts := strings.Split(operand, "..")
+	if len(parts) != 2 {
+		return nil, errors.New(fmt.Sprintf("Invalid range '%s', must be min..max", operand))
+	}
+	var bounds [2]*float64
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		b, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid number '%s' in range '%s'", part, operand))
+		}
+		bounds[i] = &b
+	}
+	return func(value string) bool {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		return (bounds[0] == nil || v >= *bounds[0]) && (bounds[1] == nil || v <= *bounds[1])
+	}, nil
+}
+
+type semver struct {
+	numbers    [3]int
+	prerelease string
+}
+
+func parseSemver(s string) (*semver, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	v := &semver{}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.prerelease = s[i + 1:]
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, errors.New(fmt.Sprintf("Invalid version '%s'", s))
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, errors.New(fmt.Sprintf("Invalid version '%s'", s))
+		}
+		v.numbers[i] = n
+	}
+	return v, nil
+}
+
+func (v *semver) compare(other *semver) int {
+	for i := 0; i < 3; i++ {
+		if v.numbers[i] != other.numbers[i] {
+			if v.numbers[i] < other.numbers[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	// a pre-release has lower precedence than the release
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.prerelease, other.prerelease)
+}
+
+// comparePrerelease compares the dot separated identifiers of two
+// pre-releases, semver 2.0.0 section 11. Numeric identifiers are compared as
+// numbers and have lower precedence than alphanumeric ones, which are
+// compared as strings, and a pre-release with fewer identifiers is lower if
+// the others are equal.
+func comparePrerelease(a string, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if cmp := strings.Compare(as[i], bs[i]); cmp != 0 {
+				return cmp
+			}
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+type semverComparator struct {
+	operator string
+	version  *semver
+}
+
+func (c semverComparator) matches(v *semver) bool {
+	cmp := v.compare(c.version)
+	switch c.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+// compileSemverConstraint compiles space or comma separated comparators,
+// all of which must match. ^1.2.3 means >=1.2.3 <2.0.0 and ~1.2.3 means
+// >=1.2.3 <1.3.0.
+func compileSemverConstraint(operand string) (func(string) bool, error) {
+	comparators := []semverComparator{}
+	for _, part := range strings.Fields(strings.Replace(operand, ",", " ", -1)) {
+		operator := ""
+		for _, op := range []string{"<=", ">=", "<", ">", "=", "^", "~"} {
+			if strings.HasPrefix(part, op) {
+				operator = op
+				break
+			}
+		}
+		v, err := parseSemver(part[len(operator):])
+		if err != nil {
+			return nil, err
+		}
+		switch operator {
+		case "^":
+			upper := &semver{numbers:[3]int{v.numbers[0] + 1, 0, 0}}
+			if v.numbers[0] == 0 {
+				upper = &semver{numbers:[3]int{0, v.numbers[1] + 1, 0}}
+			}
+			comparators = append(comparators, semverComparator{">=", v}, semverComparator{"<", upper})
+		case "~":
+			upper := &semver{numbers:[3]int{v.numbers[0], v.numbers[1] + 1, 0}}
+			comparators = append(comparators, semverComparator{">=", v}, semverComparator{"<", upper})
+		default:
+			comparators = append(comparators, semverComparator{operator, v})
+		}
+	}
+	if len(comparators) == 0 {
+		return nil, errors.New(fmt.Sprintf("Invalid version constraint '%s'", operand))
+	}
+	return func(value string) bool {
+		v, err := parseSemver(value)
+		if err != nil {
+			return false
+		}
+		for _, c := range comparators {
+			if !c.matches(v) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
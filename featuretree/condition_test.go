@@ -0,0 +1,153 @@
+package featuretree
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileCondition(t *testing.T) {
+	tests := []struct {
+		operator string
+		operand  string
+		value    string
+		match    bool
+	}{
+		{OperatorIn, "se, no,dk", "no", true},
+		{OperatorIn, "se,no,dk", "fi", false},
+		{OperatorNotIn, "se,no,dk", "fi", true},
+		{OperatorNotIn, "se,no,dk", "se", false},
+		{OperatorRegex, "^user-[0-9]+$", "user-42", true},
+		{OperatorRegex, "^user-[0-9]+$", "admin", false},
+		{OperatorLessThan, "10", "9.5", true},
+		{OperatorLessThan, "10", "10", false},
+		{OperatorLessOrEqual, "10", "10", true},
+		{OperatorGreaterThan, "10", "11", true},
+		{OperatorGreaterOrEqual, "10", "9", false},
+		{OperatorGreaterOrEqual, "10", "ten", false},
+		{OperatorRange, "18..65", "18", true},
+		{OperatorRange, "18..65", "66", false},
+		{OperatorRange, "18..", "100", true},
+		{OperatorRange, "..65", "-1", true},
+		{OperatorSemver, ">=1.2.0 <2.0.0", "1.10.3", true},
+		{OperatorSemver, ">=1.2.0 <2.0.0", "2.0.0", false},
+		{OperatorSemver, ">=1.2.0", "1.2.0-beta", false},
+		{OperatorSemver, "^1.2.0", "v1.9", true},
+		{OperatorSemver, "^0.2.0", "0.3.0", false},
+		{OperatorSemver, "~1.2.0", "1.2.9", true},
+		{OperatorSemver, "~1.2.0", "1.3.0", false},
+		{OperatorSemver, "1.2.3", "1.2.3", true},
+		{OperatorSemver, "1.2.3", "not a version", false},
+		{OperatorSemver, ">1.0.0-alpha.2", "1.0.0-alpha.10", true},
+		{OperatorSemver, "<1.0.0-alpha.1", "1.0.0-alpha", true},
+		{OperatorSemver, ">1.0.0-alpha.1", "1.0.0-alpha.beta", true},
+		{OperatorSemver, "<1.0.0-alpha", "1.0.0-1", true},
+		{OperatorSemver, ">1.0.0-beta.11", "1.0.0-rc.1", true},
+		{OperatorSemver, ">1.0.0-beta.2", "1.0.0-beta.11", true},
+		{OperatorSemver, "1.0.0-rc.1", "1.0.0-rc.1+build.5", true},
+		{OperatorPrefix, "android-", "android-8", true},
+		{OperatorPrefix, "android-", "ios-11", false},
+		{OperatorSuffix, "@example.com", "adam@example.com", true},
+		{OperatorCidr, "10.0.0.0/8, 192.168.0.0/16", "192.168.1.10", true},
+		{OperatorCidr, "10.0.0.0/8", "11.0.0.1", false},
+		{OperatorCidr, "10.0.0.0/8", "not an ip", false},
+	}
+	for _, test := range tests {
+		c, err := compileCondition(test.operator, test.operand)
+		require.Nil(t, err, "%s %s should compile, %v", test.operator, test.operand, err)
+		assert.Equal(t, test.match, c.match(test.value), "%s %s on '%s'", test.operator, test.operand, test.value)
+	}
+}
+
+func TestCondition_absent_property(t *testing.T) {
+	tests := []struct {
+		operator string
+		operand  string
+		match    bool
+	}{
+		{OperatorNotIn, "se,no,dk", true},
+		{OperatorIn, "se,no,dk", false},
+		{OperatorRegex, ".*", false},
+		{OperatorRange, "..", false},
+		{OperatorSemver, ">=0.0.0", false},
+		{OperatorPrefix, "", false},
+	}
+	for _, test := range tests {
+		c, err := compileCondition(test.operator, test.operand)
+		require.Nil(t, err, "%s %s should compile, %v", test.operator, test.operand, err)
+		assert.Equal(t, test.match, c.matches("", false), "%s %s on an absent property", test.operator, test.operand)
+	}
+}
+
+func TestValidateCondition_invalid(t *testing.T) {
+	invalid := [][]string{
+		{"like", "a%"},
+		{OperatorRegex, "(unclosed"},
+		{OperatorLessThan, "ten"},
+		{OperatorRange, "1-10"},
+		{OperatorSemver, ">=one"},
+		{OperatorSemver, ""},
+		{OperatorCidr, "10.0.0.0"},
+	}
+	for _, i := range invalid {
+		assert.NotNil(t, ValidateCondition(i[0], i[1]), "%s %s should be invalid", i[0], i[1])
+	}
+	assert.Nil(t, ValidateCondition("", "anything"), "no operator is an exact match")
+	assert.Nil(t, ValidateCondition(OperatorEquals, "anything"))
+}
+
+func TestFindFeatures_operators(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country", "appversion"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "nordic", Properties: Properties{"country": "se,no,dk"},
+		Operators: map[string]string{"country": OperatorIn}})
+	builder.AddFeature(ToggleRule{Id: "rule 2", Name: "new ui", Properties: Properties{"country": "se", "appversion": ">=2.0.0"},
+		Operators: map[string]string{"appversion": OperatorSemver}})
+	builder.AddFeature(ToggleRule{Id: "rule 3", Name: "swedish", Properties: Properties{"country": "se"}})
+	tree := builder.Build()
+
+	features := tree.FindFeatures(Properties{"country": "se", "appversion": "2.1.0"})
+	assert.ElementsMatch(t, []string{"nordic", "new ui", "swedish"}, features)
+
+	features = tree.FindFeatures(Properties{"country": "se", "appversion": "1.9.0"})
+	assert.ElementsMatch(t, []string{"nordic", "swedish"}, features)
+
+	features = tree.FindFeatures(Properties{"country": "no"})
+	assert.ElementsMatch(t, []string{"nordic"}, features)
+
+	features = tree.FindFeatures(Properties{"appversion": "3.0.0"})
+	assert.Empty(t, features, "a condition should not match a missing property")
+}
+
+func TestFindFeatures_not_in_absent_property(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "outside nordic", Properties: Properties{"country": "se,no,dk"},
+		Operators: map[string]string{"country": OperatorNotIn}})
+	tree := builder.Build()
+
+	assert.Equal(t, []string{"outside nordic"}, tree.FindFeatures(Properties{"country": "fi"}))
+	assert.Empty(t, tree.FindFeatures(Properties{"country": "se"}))
+	assert.Equal(t, []string{"outside nordic"}, tree.FindFeatures(Properties{}), "an absent country is not in the list")
+	assert.True(t, tree.Explain(Properties{})[0].Enabled)
+}
+
+func TestRemoveRule_operators(t *testing.T) {
+	builder := NewTreeBuilder([]string{"ip"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "internal", Properties: Properties{"ip": "10.0.0.0/8"},
+		Operators: map[string]string{"ip": OperatorCidr}})
+
+	assert.True(t, builder.RemoveRule("rule 1"))
+	assert.Empty(t, builder.Build().FindFeatures(Properties{"ip": "10.1.2.3"}))
+	assert.Empty(t, builder.tree.root.conditions, "empty condition branches should be pruned")
+}
+
+func TestAddFeature_invalid_operator(t *testing.T) {
+	builder := NewTreeBuilder([]string{"age"})
+
+	err := builder.AddFeature(ToggleRule{Name: "adult", Properties: Properties{"age": "eighteen"},
+		Operators: map[string]string{"age": OperatorGreaterOrEqual}})
+	assert.NotNil(t, err, "an operand that isn't a number should be rejected")
+
+	err = builder.AddFeature(ToggleRule{Name: "adult", Properties: Properties{},
+		Operators: map[string]string{"age": OperatorGreaterOrEqual}})
+	assert.NotNil(t, err, "an operator without a property value should be rejected")
+}
@@ -0,0 +1,154 @@
+package featuretree
+
+import "sort"
+
+// PathStep is the branch taken on one property level, the exact value, "*"
+// or the operator and operand of a condition.
+type PathStep struct {
+	Property string
+	Value    string
+}
+
+// RuleMatch is a rule that matched the context and the path to its leaf.
+type RuleMatch struct {
+	RuleId   string
+	Deny     bool
+	Priority int
+	Path     []PathStep
+}
+
+// NearMiss is a rule that failed on a single property. Expected is the
+// branch the rule requires, Actual the value of the context, "" if missing.
+// A rule that matched but was left out of its rollout fails on the rollout
+// property.
+type NearMiss struct {
+	RuleId   string
+	Property string
+	Expected string
+	Actual   string
+	Path     []PathStep
+}
+
+// Explanation tells why a feature is enabled or not for a context.
+type Explanation struct {
+	Feature    string
+	Enabled    bool
+	WinnerId   string
+	Variant    string
+	Matched    []RuleMatch
+	NearMisses []NearMiss
+}
+
+type explainedMatch struct {
+	match
+	path []PathStep
+}
+
+type explainer struct {
+	properties Properties
+	matches    []explainedMatch
+	nearMisses map[string][]NearMiss
+	order      []string
+	seen       map[string]bool
+}
+
+func (e *explainer) addFeature(feature string) {
+	if !e.seen[feature] {
+		e.seen[feature] = true
+		e.order = append(e.order, feature)
+	}
+}
+
+// walk follows every branch the context matches and, as long as no property
+// has failed yet, every branch it doesn't match, to find the near misses.
+func (e *explainer) walk(node *Node, propertyNames []string, specificity int, path []PathStep, miss *NearMiss) {
+	if len(propertyNames) == 0 {
+		e.leaf(node, specificity, path, miss)
+		return
+	}
+	name := propertyNames[0]
+	val, ok := e.properties[name]
+	for key, nextNode := range node.nodes {
+		step := PathStep{name, key}
+		switch {
+		case key == unspecifiedProperty:
+			e.walk(nextNode, propertyNames[1:], specificity, appendStep(path, step), miss)
+		case ok && key == val:
+			e.walk(nextNode, propertyNames[1:], specificity + 1, appendStep(path, step), miss)
+		case miss == nil:
+			e.walk(nextNode, propertyNames[1:], specificity + 1, appendStep(path, step), &NearMiss{Property:name, Expected:key, Actual:val})
+		}
+	}
+	for key, nextNode := range node.conditions {
+		step := PathStep{name, key}
+		if nextNode.condition.matches(val, ok) {
+			e.walk(nextNode, propertyNames[1:], specificity + 1, appendStep(path, step), miss)
+		} else if miss == nil {
+			e.walk(nextNode, propertyNames[1:], specificity + 1, appendStep(path, step), &NearMiss{Property:name, Expected:key, Actual:val})
+		}
+	}
+}
+
+func (e *explainer) leaf(node *Node, specificity int, path []PathStep, miss *NearMiss) {
+	for _, r := range node.rules {
+		if miss != nil {
+			e.nearMiss(r, *miss, path)
+			continue
+		}
+		if !r.rollout.includes(r.feature, e.properties) {
+			e.nearMiss(r, NearMiss{Property:r.rollout.property, Expected:"rollout", Actual:e.properties[r.rollout.property]}, path)
+			continue
+		}
+		e.addFeature(r.feature)
+		e.matches = append(e.matches, explainedMatch{match{r, specificity}, path})
+	}
+}
+
+func (e *explainer) nearMiss(r ruleFeature, miss NearMiss, path []PathStep) {
+	miss.RuleId = r.ruleId
+	miss.Path = path
+	e.addFeature(r.feature)
+	e.nearMisses[r.feature] = append(e.nearMisses[r.feature], miss)
+}
+
+func appendStep(path []PathStep, step PathStep) []PathStep {
+	res := make([]PathStep, len(path), len(path) + 1)
+	copy(res, path)
+	return append(res, step)
+}
+
+// Explain returns, for every feature with a rule that matched or nearly
+// matched the properties, the rules that matched, the path through the tree
+// to them and the rules that failed on a single property, ordered by feature.
+func (tree *ToggleRuleTree) Explain(properties Properties) []Explanation {
+	e := &explainer{properties:properties, nearMisses:make(map[string][]NearMiss), seen:make(map[string]bool)}
+	e.walk(&tree.root, tree.propertyNames, 0, []PathStep{}, nil)
+
+	sort.Strings(e.order)
+	explanations := []Explanation{}
+	for _, feature := range e.order {
+		explanation := Explanation{Feature:feature, Matched:[]RuleMatch{}, NearMisses:e.nearMisses[feature]}
+		if explanation.NearMisses == nil {
+			explanation.NearMisses = []NearMiss{}
+		}
+		var winner *explainedMatch
+		for i, m := range e.matches {
+			if m.rule.feature != feature {
+				continue
+			}
+			explanation.Matched = append(explanation.Matched, RuleMatch{m.rule.ruleId, m.rule.deny, m.rule.priority, m.path})
+			if winner == nil || m.precedes(winner.match) {
+				winner = &e.matches[i]
+			}
+		}
+		if winner != nil {
+			explanation.WinnerId = winner.rule.ruleId
+			if !winner.rule.deny {
+				explanation.Enabled = true
+				explanation.Variant = tree.evaluation(winner.rule, properties).Variant
+			}
+		}
+		explanations = append(explanations, explanation)
+	}
+	return explanations
+}
@@ -0,0 +1,75 @@
+package featuretree
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country", "usertype"})
+	builder.AddFeature(ToggleRule{Id: "allow SE", Name: "feature 1", Properties: Properties{"country": "SE"}})
+	builder.AddFeature(ToggleRule{Id: "deny interns", Name: "feature 1", Properties: Properties{"country": "SE", "usertype": "intern"}, Deny: true})
+	builder.AddFeature(ToggleRule{Id: "allow NO", Name: "feature 2", Properties: Properties{"country": "NO"}})
+	builder.AddFeature(ToggleRule{Id: "beta", Name: "feature 3", Properties: Properties{"usertype": "beta"}})
+	tree := builder.Build()
+
+	explanations := tree.Explain(Properties{"country": "SE", "usertype": "employee"})
+	require.Equal(t, 3, len(explanations), "%v", explanations)
+
+	f1 := explanations[0]
+	assert.Equal(t, "feature 1", f1.Feature)
+	assert.True(t, f1.Enabled)
+	assert.Equal(t, "allow SE", f1.WinnerId)
+	require.Equal(t, 1, len(f1.Matched))
+	assert.Equal(t, []PathStep{{"country", "SE"}, {"usertype", "*"}}, f1.Matched[0].Path)
+	require.Equal(t, 1, len(f1.NearMisses))
+	assert.Equal(t, NearMiss{"deny interns", "usertype", "intern", "employee", []PathStep{{"country", "SE"}, {"usertype", "intern"}}},
+		f1.NearMisses[0])
+
+	f2 := explanations[1]
+	assert.Equal(t, "feature 2", f2.Feature)
+	assert.False(t, f2.Enabled)
+	assert.Empty(t, f2.Matched)
+	require.Equal(t, 1, len(f2.NearMisses))
+	assert.Equal(t, "country", f2.NearMisses[0].Property)
+	assert.Equal(t, "NO", f2.NearMisses[0].Expected)
+	assert.Equal(t, "SE", f2.NearMisses[0].Actual)
+
+	assert.Equal(t, "feature 3", explanations[2].Feature)
+	assert.Equal(t, "usertype", explanations[2].NearMisses[0].Property)
+}
+
+func TestExplain_denied(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country", "usertype"})
+	builder.AddFeature(ToggleRule{Id: "allow SE", Name: "feature 1", Properties: Properties{"country": "SE"}})
+	builder.AddFeature(ToggleRule{Id: "deny interns", Name: "feature 1", Properties: Properties{"country": "SE", "usertype": "intern"}, Deny: true})
+
+	explanations := builder.Build().Explain(Properties{"country": "SE", "usertype": "intern"})
+
+	require.Equal(t, 1, len(explanations))
+	assert.False(t, explanations[0].Enabled)
+	assert.Equal(t, "deny interns", explanations[0].WinnerId)
+	assert.Equal(t, 2, len(explanations[0].Matched), "both rules matched")
+	assert.Empty(t, explanations[0].NearMisses)
+}
+
+func TestExplain_two_failed_properties_is_not_a_near_miss(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country", "usertype"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"country": "SE", "usertype": "beta"}})
+
+	assert.Empty(t, builder.Build().Explain(Properties{"country": "NO", "usertype": "alpha"}))
+}
+
+func TestExplain_operator_and_rollout(t *testing.T) {
+	builder := NewTreeBuilder([]string{"appversion"})
+	builder.AddFeature(ToggleRule{Id: "new app", Name: "feature 1", Properties: Properties{"appversion": ">=2.0.0"},
+		Operators: map[string]string{"appversion": OperatorSemver}})
+	builder.AddFeature(ToggleRule{Id: "rollout", Name: "feature 2", Properties: Properties{}, RolloutPercentage: 1, RolloutProperty: "userid"})
+	tree := builder.Build()
+
+	explanations := tree.Explain(Properties{"appversion": "1.0.0"})
+	require.Equal(t, 2, len(explanations))
+	assert.Equal(t, "semver >=2.0.0", explanations[0].NearMisses[0].Expected)
+	assert.Equal(t, "userid", explanations[1].NearMisses[0].Property, "context without userid is not in the rollout")
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+	"time"
 	"github.com/pkg/errors"
 )
 
@@ -14,19 +15,57 @@ type NodeMap map[string]*Node
 type Node struct {
 	value    string
 	features []string
+	rules    []ruleFeature
 	nodes    NodeMap
+	// conditions holds the children matched by an operator, keyed by
+	// operator and operand. Exact values are looked up in nodes.
+	condition  *condition
+	conditions NodeMap
 }
 
+// ruleFeature records which rule added a feature to a leaf, so the feature
+// is kept as long as any rule on the leaf still enables it.
+type ruleFeature struct {
+	ruleId   string
+	feature  string
+	rollout  rollout
+	deny     bool
+	priority int
+	split    *split
+}
+
+// ToggleRuleTree is an immutable snapshot of the toggle rules, built with a
+// TreeBuilder. It is safe for concurrent use.
 type ToggleRuleTree struct {
 	root          Node
 	propertyNames []string
+	variants      map[string]FeatureVariants
 }
 
 type Properties map[string]string
 
 type ToggleRule struct {
+	Id         string
 	Name       string
 	Properties Properties
+	// RolloutPercentage enables the feature for a share, 1-100, of the
+	// contexts, bucketed by the value of RolloutProperty. 0 means all contexts.
+	RolloutPercentage int
+	RolloutProperty   string
+	// Operators maps a property to the operator its value is matched with,
+	// properties without an operator are matched exactly.
+	Operators map[string]string
+	// Deny turns the feature off for matching contexts, see FindFeatures for
+	// how allow and deny rules are weighed.
+	Deny     bool
+	Priority int
+	// Variants chooses the variant served by the rule, several variants split
+	// the contexts by weight, bucketed by RolloutProperty.
+	Variants []WeightedVariant
+	// Starts and Expires is the window the rule is active in, zero leaves
+	// that side open. The tree holds the rules it is given, see ActiveAt.
+	Starts  time.Time
+	Expires time.Time
 }
 
 func NewNode(key string) *Node {
@@ -50,21 +89,93 @@ func (node *Node) getOrCreateNode(value string) *Node {
 	return nextNode
 }
 
+func (node *Node) getOrCreateConditionNode(operator string, operand string) *Node {
+	key := conditionKey(operator, operand)
+	nextNode, ok := node.conditions[key]
+	if !ok {
+		// the rule is validated before it is added
+		c, _ := compileCondition(operator, operand)
+		nextNode = &Node{value:key, condition:c}
+		if node.conditions == nil {
+			node.conditions = make(NodeMap)
+		}
+		node.conditions[key] = nextNode
+	}
+	return nextNode
+}
+
 func (node *Node) addFeature(propertyNames []string, rule ToggleRule) {
 	if len(propertyNames) == 0 {
-		node.features = addToFeatureList(node.features, rule.Name)
+		r := ruleFeature{rule.Id, rule.Name, rollout{rule.RolloutPercentage, rule.RolloutProperty}, rule.Deny, rule.Priority, newSplit(rule.Variants)}
+		if r.rollout.all() && !r.deny {
+			node.features = addToFeatureList(node.features, rule.Name)
+		}
+		node.rules = addToRuleList(node.rules, r)
 	} else {
-		val, ok := rule.Properties[propertyNames[0]]
-		nextNode := &Node{}
-		if ok {
-			nextNode = node.getOrCreateNode(val)
+		var nextNode *Node
+		if operator := rule.Operators[propertyNames[0]]; isExact(operator) {
+			nextNode = node.getOrCreateNode(pathValue(propertyNames[0], rule))
 		} else {
-			// feature does not have a property on this level, add to wildcard
-			nextNode = node.getOrCreateNode("*")
+			nextNode = node.getOrCreateConditionNode(operator, rule.Properties[propertyNames[0]])
 		}
 		(nextNode).addFeature(propertyNames[1:], rule)
 	}
 }
+
+// pathValue returns the node value the rule follows on the level of the property.
+func pathValue(propertyName string, rule ToggleRule) string {
+	if val, ok := rule.Properties[propertyName]; ok {
+		return val
+	}
+	// feature does not have a property on this level, add to wildcard
+	return unspecifiedProperty
+}
+
+// removeRule removes the features the rule added and prunes the branches left
+// empty. It returns true if the node itself is empty afterwards.
+func (node *Node) removeRule(propertyNames []string, rule ToggleRule) bool {
+	if len(propertyNames) == 0 {
+		rules := []ruleFeature{}
+		var features []string
+		for _, r := range node.rules {
+			if strings.Compare(r.ruleId, rule.Id) != 0 {
+				rules = append(rules, r)
+				if r.rollout.all() && !r.deny {
+					features = addToFeatureList(features, r.feature)
+				}
+			}
+		}
+		node.rules = rules
+		node.features = features
+		return len(node.rules) == 0
+	}
+	nodes := node.nodes
+	value := pathValue(propertyNames[0], rule)
+	if operator := rule.Operators[propertyNames[0]]; !isExact(operator) {
+		nodes = node.conditions
+		value = conditionKey(operator, value)
+	}
+	if nextNode, ok := nodes[value]; ok {
+		if nextNode.removeRule(propertyNames[1:], rule) {
+			delete(nodes, value)
+		}
+	}
+	return len(node.nodes) == 0 && len(node.conditions) == 0
+}
+
+func (r ruleFeature) equals(other ruleFeature) bool {
+	return r.ruleId == other.ruleId && r.feature == other.feature && r.rollout == other.rollout &&
+		r.deny == other.deny && r.priority == other.priority && r.split.equals(other.split)
+}
+
+func addToRuleList(ruleList []ruleFeature, rule ruleFeature) []ruleFeature {
+	for _, r := range ruleList {
+		if r.equals(rule) {
+			return ruleList
+		}
+	}
+	return append(ruleList, rule)
+}
 func addToFeatureList(featureList []string, feature string) []string {
 	for _, f := range featureList {
 		if strings.Compare(f, feature) == 0 {
@@ -74,20 +185,18 @@ func addToFeatureList(featureList []string, feature string) []string {
 	return append(featureList, feature)
 }
 
-func (tree *ToggleRuleTree) AddFeature(rule ToggleRule) error {
-	err := tree.validateToggleRule(rule)
+func (tree *ToggleRuleTree) validateToggleRule(rule ToggleRule) error {
+	err := rollout{rule.RolloutPercentage, rule.RolloutProperty}.validate()
 	if err != nil {
-		return errors.New("Ignoring feature. " + err.Error())
+		return err
+	}
+	err = ValidateSplit(rule.Variants, rule.RolloutProperty)
+	if err != nil {
+		return err
 	}
-	tree.root.addFeature(tree.propertyNames, rule)
-	return nil
-}
-
-func (tree *ToggleRuleTree) validateToggleRule(rule ToggleRule) error {
 	for propName, _ := range rule.Properties {
 		var found bool = false
 		for _, name := range tree.propertyNames {
-			fmt.Printf("propName %v == name %v\n", propName, name)
 			if strings.Compare(name, propName) == 0 {
 				found = true
 				break
@@ -97,37 +206,62 @@ func (tree *ToggleRuleTree) validateToggleRule(rule ToggleRule) error {
 			return errors.New(fmt.Sprintf("Property '%s' is unknown.", propName))
 		}
 	}
+	for propName, operator := range rule.Operators {
+		value, ok := rule.Properties[propName]
+		if !ok {
+			return errors.New(fmt.Sprintf("Operator '%s' on property '%s' without value.", operator, propName))
+		}
+		err := ValidateCondition(operator, value)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (node *Node) findFeature(propertyNames []string, properties Properties) []string {
-	if node.features != nil {
-		return node.features
+// findFeature collects the rules on all leaves the properties lead to.
+// specificity is the number of levels matched on a value rather than "*".
+func (node *Node) findFeature(propertyNames []string, properties Properties, specificity int, matches []match) []match {
+	if len(propertyNames) == 0 {
+		return node.leafMatches(properties, specificity, matches)
 	} else {
-		features := []string{}
-		if len(propertyNames) == 0 {
-			return []string{}
-		} else {
-			nextPropertyName := propertyNames[0]
-			fmt.Printf("props: %v, nextName: %v\n", properties, nextPropertyName)
-			if val, ok := properties[nextPropertyName]; ok {
-				if nextNode, ok := node.nodes[val]; ok {
-					features = nextNode.findFeature(propertyNames[1:], properties)
-				}
+		nextPropertyName := propertyNames[0]
+		val, ok := properties[nextPropertyName]
+		if ok {
+			if nextNode, ok := node.nodes[val]; ok {
+				matches = nextNode.findFeature(propertyNames[1:], properties, specificity + 1, matches)
 			}
-			if nextNode, ok := node.nodes[unspecifiedProperty]; ok {
-				unspecFeatures := nextNode.findFeature(propertyNames[1:], properties)
-				features = append(features, unspecFeatures...)
+		}
+		for _, nextNode := range node.conditions {
+			if nextNode.condition.matches(val, ok) {
+				matches = nextNode.findFeature(propertyNames[1:], properties, specificity + 1, matches)
 			}
+		}
+		if nextNode, ok := node.nodes[unspecifiedProperty]; ok {
+			matches = nextNode.findFeature(propertyNames[1:], properties, specificity, matches)
+		}
+		return matches
+	}
+}
 
+// leafMatches adds the rules of a leaf, rollout rules only when the context
+// lands in the rollout bucket.
+func (node *Node) leafMatches(properties Properties, specificity int, matches []match) []match {
+	for _, r := range node.rules {
+		if !r.rollout.all() && !r.rollout.includes(r.feature, properties) {
+			continue
 		}
-		return features
+		matches = append(matches, match{r, specificity})
 	}
+	return matches
 }
 
+// FindFeatures returns the features enabled for the properties. When both
+// allow and deny rules match a feature the rule with the highest priority
+// wins, then the most specific rule, the one matching the most properties
+// on a value rather than "*". A deny rule wins a remaining tie.
 func (tree *ToggleRuleTree) FindFeatures(properties Properties) []string {
-
-	return tree.root.findFeature(tree.propertyNames, properties)
+	return resolve(tree.root.findFeature(tree.propertyNames, properties, 0, nil))
 }
 
 func (tree *ToggleRuleTree) String() string {
@@ -153,12 +287,17 @@ func (node *Node) writeToBuf(buf *bytes.Buffer, indent int) {
 		buf.WriteString(node.value)
 		//buf.WriteString("\n")
 	}
-	if ( node.nodes != nil) {
+	if ( node.nodes != nil || node.conditions != nil) {
 		for _, n := range node.nodes {
 			addIndent(buf, indent)
 			buf.WriteString("->")
 			n.writeToBuf(buf, indent + 2)
 		}
+		for _, n := range node.conditions {
+			addIndent(buf, indent)
+			buf.WriteString("->")
+			n.writeToBuf(buf, indent + 2)
+		}
 	} else {
 		// write features
 		if indent > 0 {
@@ -186,7 +325,7 @@ func addIndent(buf *bytes.Buffer, indent int) {
 	}
 }
 
+// NewFeatureTree returns an empty tree, use a TreeBuilder to add rules.
 func NewFeatureTree(propertyNames []string) *ToggleRuleTree {
-	tree := ToggleRuleTree{Node{}, propertyNames}
-	return &tree
+	return NewTreeBuilder(propertyNames).Build()
 }
\ No newline at end of file
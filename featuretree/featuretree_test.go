@@ -43,7 +43,7 @@ func TestAddFeature_leaf(t *testing.T) {
 	props := Properties{}
 	props[propertyName] = propertyValue
 
-	feature := ToggleRule{featureName, props}
+	feature := ToggleRule{Name: featureName, Properties: props}
 
 	node.addFeature([]string{}, feature)
 
@@ -55,14 +55,15 @@ func TestAddFeature_simple(t *testing.T) {
 	propertyName := "username"
 	propertyValue := "adam"
 
-	tree := NewFeatureTree([]string{propertyName})
+	builder := NewTreeBuilder([]string{propertyName})
 
 	props := Properties{}
 	props[propertyName] = propertyValue
 
-	feature := ToggleRule{"feature 1", props}
+	feature := ToggleRule{Name: "feature 1", Properties: props}
 
-	tree.AddFeature(feature)
+	builder.AddFeature(feature)
+	tree := builder.Build()
 
 	assert.Equal(t, []string{propertyName}, tree.propertyNames, "should find property " + propertyName + " on tree")
 
@@ -79,15 +80,16 @@ func TestAddFeature_2_props__1_feature_prop(t *testing.T) {
 	propertyValue := "adam"
 
 	propertyNames := []string{property1Name, property2Name}
-	tree := NewFeatureTree(propertyNames)
+	builder := NewTreeBuilder(propertyNames)
 
 	//require.FailNow(t, "Stop here")
 	props := Properties{}
 	props[property1Name] = propertyValue
 
-	feature := ToggleRule{"feature 1", props}
+	feature := ToggleRule{Name: "feature 1", Properties: props}
 
-	tree.AddFeature(feature)
+	builder.AddFeature(feature)
+	tree := builder.Build()
 
 	assert.Equal(t, propertyNames, tree.propertyNames, "should find value 'username' and 'usertype' on tree")
 
@@ -107,15 +109,16 @@ func TestAddFeature_3_props__1_feature_prop(t *testing.T) {
 	propertyValue := "adam"
 
 	propertyNames := []string{property0Name, property1Name, property2Name}
-	tree := NewFeatureTree(propertyNames)
+	builder := NewTreeBuilder(propertyNames)
 
 
 	props := Properties{}
 	props[property1Name] = propertyValue
 
-	feature := ToggleRule{"feature 1", props}
+	feature := ToggleRule{Name: "feature 1", Properties: props}
 
-	tree.AddFeature(feature)
+	builder.AddFeature(feature)
+	tree := builder.Build()
 
 	assert.Equal(t, propertyNames, tree.propertyNames, "should find value 'username' and 'usertype' on tree")
 
@@ -141,18 +144,19 @@ func TestAddFeature_3_props__2_feature_prop(t *testing.T) {
 	featureName2 := "feature 2"
 
 	propertyNames := []string{property0Name, property1Name, property2Name}
-	tree := NewFeatureTree(propertyNames)
+	builder := NewTreeBuilder(propertyNames)
 
 
 	props := Properties{}
 	props[property1Name] = property1Value
-	feature := ToggleRule{featureName1, props}
-	tree.AddFeature(feature)
+	feature := ToggleRule{Name: featureName1, Properties: props}
+	builder.AddFeature(feature)
 
 	props2 := Properties{}
 	props2[property1Name] = property1Value2
-	feature2 := ToggleRule{featureName2, props2}
-	tree.AddFeature(feature2)
+	feature2 := ToggleRule{Name: featureName2, Properties: props2}
+	builder.AddFeature(feature2)
+	tree := builder.Build()
 
 	assert.Equal(t, propertyNames, tree.propertyNames, "should find value 'username' and 'usertype' on tree")
 
@@ -186,19 +190,20 @@ func TestAddFeature_3_props__3_feature_prop(t *testing.T) {
 	featureName2 := "feature 2"
 
 	propertyNames := []string{property0Name, property1Name, property2Name}
-	tree := NewFeatureTree(propertyNames)
+	builder := NewTreeBuilder(propertyNames)
 
 
 	props := Properties{}
 	props[property1Name] = property1Value
-	feature := ToggleRule{featureName1, props}
-	tree.AddFeature(feature)
+	feature := ToggleRule{Name: featureName1, Properties: props}
+	builder.AddFeature(feature)
 
 	props2 := Properties{}
 	props2[property1Name] = property1Value
 	props2[property2Name] = property2Value2
-	feature2 := ToggleRule{featureName2, props2}
-	tree.AddFeature(feature2)
+	feature2 := ToggleRule{Name: featureName2, Properties: props2}
+	builder.AddFeature(feature2)
+	tree := builder.Build()
 
 	print(tree.String())
 	assert.Equal(t, propertyNames, tree.propertyNames, "should find value 'username' and 'usertype' on tree")
@@ -278,19 +283,20 @@ func createFeatureTree() *ToggleRuleTree {
 	featureName2 := "feature 2"
 
 	propertyNames := []string{property0Name, property1Name, property2Name}
-	tree := NewFeatureTree(propertyNames)
+	builder := NewTreeBuilder(propertyNames)
 
 
 	props := Properties{}
 	props[property1Name] = property1Value
-	feature := ToggleRule{featureName1, props}
-	tree.AddFeature(feature)
+	feature := ToggleRule{Name: featureName1, Properties: props}
+	builder.AddFeature(feature)
 
 	props2 := Properties{}
 	props2[property1Name] = property1Value
 	props2[property2Name] = property2Value2
-	feature2 := ToggleRule{featureName2, props2}
-	tree.AddFeature(feature2)
+	feature2 := ToggleRule{Name: featureName2, Properties: props2}
+	builder.AddFeature(feature2)
+	tree := builder.Build()
 	return tree
 }
 
@@ -305,6 +311,6 @@ func contains(s []string, e string) bool {
 
 func (m NodeMap) printMap() {
 	for k, v := range m {
-		fmt.Printf("key[%s] value[%s]\n", k, v)
+		fmt.Printf("key[%s] value[%v]\n", k, v)
 	}
 }
\ No newline at end of file
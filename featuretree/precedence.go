@@ -0,0 +1,55 @@
+package featuretree
+
+// match is a rule found on a leaf the context leads to.
+type match struct {
+	rule        ruleFeature
+	specificity int
+}
+
+// precedes returns true if m overrides other for the same feature. Rules that
+// tie on priority, specificity and deny are ordered by id, so the winner
+// doesn't depend on the order the tree is walked.
+func (m match) precedes(other match) bool {
+	if m.rule.priority != other.rule.priority {
+		return m.rule.priority > other.rule.priority
+	}
+	if m.specificity != other.specificity {
+		return m.specificity > other.specificity
+	}
+	if m.rule.deny != other.rule.deny {
+		return m.rule.deny
+	}
+	return m.rule.ruleId < other.rule.ruleId
+}
+
+// winners picks the winning match per feature and returns the ones that are
+// allow rules, in the order the features were first found.
+func winners(matches []match) []match {
+	byFeature := make(map[string]match)
+	order := []string{}
+	for _, m := range matches {
+		winner, ok := byFeature[m.rule.feature]
+		if !ok {
+			order = append(order, m.rule.feature)
+		}
+		if !ok || m.precedes(winner) {
+			byFeature[m.rule.feature] = m
+		}
+	}
+	res := []match{}
+	for _, feature := range order {
+		if !byFeature[feature].rule.deny {
+			res = append(res, byFeature[feature])
+		}
+	}
+	return res
+}
+
+// resolve returns the names of the winning features.
+func resolve(matches []match) []string {
+	features := []string{}
+	for _, m := range winners(matches) {
+		features = append(features, m.rule.feature)
+	}
+	return features
+}
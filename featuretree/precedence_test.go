@@ -0,0 +1,92 @@
+package featuretree
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindFeatures_deny_more_specific_wins(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country", "usertype"})
+	builder.AddFeature(ToggleRule{Id: "allow", Name: "feature 1", Properties: Properties{"country": "SE"}})
+	builder.AddFeature(ToggleRule{Id: "deny", Name: "feature 1", Properties: Properties{"country": "SE", "usertype": "intern"}, Deny: true})
+	tree := builder.Build()
+
+	assert.Equal(t, []string{"feature 1"}, tree.FindFeatures(Properties{"country": "SE", "usertype": "employee"}))
+	assert.Empty(t, tree.FindFeatures(Properties{"country": "SE", "usertype": "intern"}), "interns in SE should be denied")
+	assert.Empty(t, tree.FindFeatures(Properties{"country": "NO", "usertype": "employee"}))
+}
+
+func TestFindFeatures_allow_more_specific_wins(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country", "usertype"})
+	builder.AddFeature(ToggleRule{Id: "deny", Name: "feature 1", Properties: Properties{"country": "SE"}, Deny: true})
+	builder.AddFeature(ToggleRule{Id: "allow", Name: "feature 1", Properties: Properties{"usertype": "beta"}})
+	builder.AddFeature(ToggleRule{Id: "allow SE beta", Name: "feature 1", Properties: Properties{"country": "SE", "usertype": "beta"}})
+	tree := builder.Build()
+
+	assert.Equal(t, []string{"feature 1"}, tree.FindFeatures(Properties{"country": "SE", "usertype": "beta"}))
+	assert.Equal(t, []string{"feature 1"}, tree.FindFeatures(Properties{"country": "NO", "usertype": "beta"}))
+	assert.Empty(t, tree.FindFeatures(Properties{"country": "SE", "usertype": "alpha"}))
+}
+
+func TestFindFeatures_deny_wins_tie(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country", "usertype"})
+	builder.AddFeature(ToggleRule{Id: "allow", Name: "feature 1", Properties: Properties{"country": "SE"}})
+	builder.AddFeature(ToggleRule{Id: "deny", Name: "feature 1", Properties: Properties{"usertype": "intern"}, Deny: true})
+	builder.AddFeature(ToggleRule{Id: "other", Name: "feature 2", Properties: Properties{"country": "SE"}})
+	tree := builder.Build()
+
+	assert.Equal(t, []string{"feature 2"}, tree.FindFeatures(Properties{"country": "SE", "usertype": "intern"}),
+		"equally specific allow and deny should deny, other features are not affected")
+}
+
+func TestEvaluate_allow_tie_is_won_by_rule_id(t *testing.T) {
+	rules := []ToggleRule{
+		{Id: "rule b", Name: "feature 1", Properties: Properties{"country": "SE"}, Variants: []WeightedVariant{{"b", 1}}},
+		{Id: "rule a", Name: "feature 1", Properties: Properties{"usertype": "beta"}, Variants: []WeightedVariant{{"a", 1}}},
+		{Id: "rule c", Name: "feature 1", Properties: Properties{"country": "SE"}, Variants: []WeightedVariant{{"c", 1}}},
+	}
+	orders := [][]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+	props := Properties{"country": "SE", "usertype": "beta"}
+	for _, order := range orders {
+		builder := NewTreeBuilder([]string{"country", "usertype"})
+		builder.SetVariants(FeatureVariants{"feature 1", []Variant{{"a", VariantTypeString, "A"}, {"b", VariantTypeString, "B"}, {"c", VariantTypeString, "C"}}, ""})
+		for _, i := range order {
+			assert.Nil(t, builder.AddFeature(rules[i]))
+		}
+		tree := builder.Build()
+
+		assert.Equal(t, []Evaluation{{"feature 1", "a", VariantTypeString, "A"}}, tree.Evaluate(props), "order %v", order)
+		assert.Equal(t, "rule a", tree.Explain(props)[0].WinnerId, "order %v", order)
+	}
+}
+
+func TestFindFeatures_priority_wins_over_specificity(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country", "usertype"})
+	builder.AddFeature(ToggleRule{Id: "allow", Name: "feature 1", Properties: Properties{"country": "SE", "usertype": "intern"}})
+	builder.AddFeature(ToggleRule{Id: "kill switch", Name: "feature 1", Properties: Properties{}, Deny: true, Priority: 100})
+	tree := builder.Build()
+
+	assert.Empty(t, tree.FindFeatures(Properties{"country": "SE", "usertype": "intern"}))
+
+	builder.RemoveRule("kill switch")
+	assert.Equal(t, []string{"feature 1"}, builder.Build().FindFeatures(Properties{"country": "SE", "usertype": "intern"}))
+}
+
+func TestFindFeatures_deny_with_operator(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country", "appversion"})
+	builder.AddFeature(ToggleRule{Id: "allow", Name: "feature 1", Properties: Properties{"country": "SE"}})
+	builder.AddFeature(ToggleRule{Id: "deny", Name: "feature 1", Properties: Properties{"country": "SE", "appversion": "<2.0.0"},
+		Operators: map[string]string{"appversion": OperatorSemver}, Deny: true})
+	tree := builder.Build()
+
+	assert.Empty(t, tree.FindFeatures(Properties{"country": "SE", "appversion": "1.4.0"}))
+	assert.Equal(t, []string{"feature 1"}, tree.FindFeatures(Properties{"country": "SE", "appversion": "2.1.0"}))
+}
+
+func TestFindFeatures_result_has_no_duplicates(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"country": "SE"}})
+	builder.AddFeature(ToggleRule{Id: "rule 2", Name: "feature 1", Properties: Properties{}})
+
+	assert.Equal(t, []string{"feature 1"}, builder.Build().FindFeatures(Properties{"country": "SE"}))
+}
@@ -0,0 +1,69 @@
+package featuretree
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/pkg/errors"
+)
+
+// rollout limits a rule to a percentage of the contexts. The bucket of a
+// context is a stable hash of the feature name and the value of the rollout
+// property, so the same user always gets the same answer for a feature while
+// different features get independent buckets.
+type rollout struct {
+	percentage int
+	property   string
+}
+
+func (r rollout) all() bool {
+	return r.percentage == 0 || r.percentage >= 100
+}
+
+func (r rollout) validate() error {
+	err := ValidateRolloutPercentage(r.percentage)
+	if err != nil {
+		return err
+	}
+	return ValidateRolloutProperty(r.percentage, r.property)
+}
+
+// ValidateRolloutPercentage checks that the percentage is 0-100, 0 being all
+// contexts.
+func ValidateRolloutPercentage(percentage int) error {
+	if percentage < 0 || percentage > 100 {
+		return errors.New(fmt.Sprintf("Rollout percentage %d is not in 0-100.", percentage))
+	}
+	return nil
+}
+
+// ValidateRolloutProperty checks that a rollout has a property to bucket the
+// contexts on.
+func ValidateRolloutProperty(percentage int, property string) error {
+	if percentage > 0 && property == "" {
+		return errors.New("Rollout percentage requires a rollout property.")
+	}
+	return nil
+}
+
+// includes returns true if the context lands in the rollout. Contexts
+// without the rollout property are never included.
+func (r rollout) includes(feature string, properties Properties) bool {
+	if r.all() {
+		return true
+	}
+	value, ok := properties[r.property]
+	if !ok {
+		return false
+	}
+	return Bucket(feature, value) < r.percentage
+}
+
+// Bucket returns the rollout bucket, 0-99, of a property value for a feature.
+func Bucket(feature string, value string) int {
+	h := fnv.New32a()
+	h.Write([]byte(feature))
+	h.Write([]byte{0})
+	h.Write([]byte(value))
+	return int(h.Sum32() % 100)
+}
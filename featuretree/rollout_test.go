@@ -0,0 +1,65 @@
+package featuretree
+
+import (
+	"fmt"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucket_is_stable(t *testing.T) {
+	assert.Equal(t, Bucket("feature 1", "user 42"), Bucket("feature 1", "user 42"), "same input should give the same bucket")
+
+	for i := 0; i < 1000; i++ {
+		b := Bucket("feature 1", fmt.Sprintf("user %d", i))
+		assert.True(t, b >= 0 && b < 100, "bucket %d should be in 0-99", b)
+	}
+}
+
+func TestFindFeatures_rollout(t *testing.T) {
+	builder := NewTreeBuilder([]string{"usertype"})
+	err := builder.AddFeature(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"usertype": "beta"},
+		RolloutPercentage: 30, RolloutProperty: "userid"})
+	assert.Nil(t, err)
+	tree := builder.Build()
+
+	included := 0
+	for i := 0; i < 1000; i++ {
+		props := Properties{"usertype": "beta", "userid": fmt.Sprintf("%d", i)}
+		features := tree.FindFeatures(props)
+		if contains(features, "feature 1") {
+			included++
+			assert.True(t, Bucket("feature 1", props["userid"]) < 30)
+		}
+		assert.Equal(t, features, tree.FindFeatures(props), "same context should always get the same answer")
+	}
+	assert.InDelta(t, 300, included, 60, "about 30%% of the users should get the feature")
+
+	assert.Empty(t, tree.FindFeatures(Properties{"usertype": "beta"}), "context without the rollout property should not be included")
+	assert.Empty(t, tree.FindFeatures(Properties{"usertype": "alpha", "userid": "1"}), "rule properties must still match")
+}
+
+func TestFindFeatures_rollout_and_full_rule_on_same_path(t *testing.T) {
+	builder := NewTreeBuilder([]string{"usertype"})
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "feature 1", Properties: Properties{"usertype": "beta"},
+		RolloutPercentage: 1, RolloutProperty: "userid"})
+	builder.AddFeature(ToggleRule{Id: "rule 2", Name: "feature 1", Properties: Properties{"usertype": "beta"}})
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, []string{"feature 1"}, builder.Build().FindFeatures(Properties{"usertype": "beta", "userid": fmt.Sprintf("%d", i)}))
+	}
+
+	builder.RemoveRule("rule 2")
+	tree := builder.Build()
+	included := 0
+	for i := 0; i < 100; i++ {
+		included += len(tree.FindFeatures(Properties{"usertype": "beta", "userid": fmt.Sprintf("%d", i)}))
+	}
+	assert.True(t, included < 100, "only the rollout rule should be left")
+}
+
+func TestAddFeature_invalid_rollout(t *testing.T) {
+	builder := NewTreeBuilder([]string{"usertype"})
+
+	assert.NotNil(t, builder.AddFeature(ToggleRule{Name: "feature 1", RolloutPercentage: 101, RolloutProperty: "userid"}))
+	assert.NotNil(t, builder.AddFeature(ToggleRule{Name: "feature 1", RolloutPercentage: 50}))
+}
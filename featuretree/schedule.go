@@ -0,0 +1,88 @@
+package featuretree
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	ScheduleActivate   = "activate"
+	ScheduleDeactivate = "deactivate"
+)
+
+// ScheduledChange is a rule starting or expiring at Time.
+type ScheduledChange struct {
+	Time    time.Time
+	RuleId  string
+	Feature string
+	Action  string
+}
+
+// ActiveAt tells if t is within the window of the rule, from Starts up to
+// but not including Expires.
+func (rule ToggleRule) ActiveAt(t time.Time) bool {
+	return (rule.Starts.IsZero() || !t.Before(rule.Starts)) && (rule.Expires.IsZero() || t.Before(rule.Expires))
+}
+
+// ExpiredAt tells if the rule won't be active at or after t, which is also
+// the case for an empty window.
+func (rule ToggleRule) ExpiredAt(t time.Time) bool {
+	return !rule.Expires.IsZero() && (!rule.Expires.After(t) || !rule.Expires.After(rule.Starts))
+}
+
+// Within narrows the window of the rule to starts and expires, e.g. the
+// window of its feature.
+func (rule ToggleRule) Within(starts time.Time, expires time.Time) ToggleRule {
+	if rule.Starts.IsZero() || starts.After(rule.Starts) {
+		rule.Starts = starts
+	}
+	if rule.Expires.IsZero() || (!expires.IsZero() && expires.Before(rule.Expires)) {
+		rule.Expires = expires
+	}
+	return rule
+}
+
+// ActiveRules returns the rules active at t.
+func ActiveRules(rules []ToggleRule, t time.Time) []ToggleRule {
+	res := []ToggleRule{}
+	for _, rule := range rules {
+		if rule.ActiveAt(t) {
+			res = append(res, rule)
+		}
+	}
+	return res
+}
+
+// NextChange returns the first time after t a rule starts or expires, the
+// zero time if none does.
+func NextChange(rules []ToggleRule, t time.Time) time.Time {
+	next := time.Time{}
+	for _, rule := range rules {
+		for _, change := range []time.Time{rule.Starts, rule.Expires} {
+			if change.After(t) && (next.IsZero() || change.Before(next)) {
+				next = change
+			}
+		}
+	}
+	return next
+}
+
+// Schedule returns the changes of the rules after t, ordered by time.
+func Schedule(rules []ToggleRule, t time.Time) []ScheduledChange {
+	res := []ScheduledChange{}
+	for _, rule := range rules {
+		if rule.Starts.After(t) {
+			res = append(res, ScheduledChange{Time:rule.Starts, RuleId:rule.Id, Feature:rule.Name, Action:ScheduleActivate})
+		}
+		if rule.Expires.After(t) {
+			res = append(res, ScheduledChange{Time:rule.Expires, RuleId:rule.Id, Feature:rule.Name, Action:ScheduleDeactivate})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Time.Equal(res[j].Time) {
+			return res[i].RuleId < res[j].RuleId
+		}
+		return res[i].Time.Before(res[j].Time)
+	})
+	return res
+}
@@ -0,0 +1,45 @@
+package featuretree
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveAt(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	rule := ToggleRule{Starts: now, Expires: now.Add(time.Hour)}
+
+	assert.False(t, rule.ActiveAt(now.Add(-time.Nanosecond)))
+	assert.True(t, rule.ActiveAt(now))
+	assert.True(t, rule.ActiveAt(now.Add(time.Hour - time.Nanosecond)))
+	assert.False(t, rule.ActiveAt(now.Add(time.Hour)))
+	assert.True(t, ToggleRule{}.ActiveAt(now))
+}
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	rules := []ToggleRule{
+		{Id: "rule 1", Name: "feature 1", Starts: now.Add(-time.Hour), Expires: now.Add(2 * time.Hour)},
+		{Id: "rule 2", Name: "feature 2", Starts: now.Add(time.Hour)},
+		{Id: "rule 3", Name: "feature 3"},
+	}
+
+	assert.Equal(t, []string{"rule 1", "rule 3"}, ruleIds(ActiveRules(rules, now)))
+	assert.Equal(t, now.Add(time.Hour), NextChange(rules, now))
+	assert.Equal(t, now.Add(2 * time.Hour), NextChange(rules, now.Add(time.Hour)))
+	assert.True(t, NextChange(rules, now.Add(2 * time.Hour)).IsZero())
+
+	assert.Equal(t, []ScheduledChange{
+		{Time: now.Add(time.Hour), RuleId: "rule 2", Feature: "feature 2", Action: ScheduleActivate},
+		{Time: now.Add(2 * time.Hour), RuleId: "rule 1", Feature: "feature 1", Action: ScheduleDeactivate},
+	}, Schedule(rules, now))
+}
+
+func ruleIds(rules []ToggleRule) []string {
+	ids := []string{}
+	for _, rule := range rules {
+		ids = append(ids, rule.Id)
+	}
+	return ids
+}
@@ -0,0 +1,201 @@
+package featuretree
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Types of variant values.
+const (
+	VariantTypeString = "string"
+	VariantTypeInt    = "int"
+	VariantTypeBool   = "bool"
+	VariantTypeJson   = "json"
+)
+
+// Variant is a named value a feature can resolve to.
+type Variant struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// FeatureVariants holds the variants of a feature. Default is served by rules
+// that don't choose a variant, if empty the first variant is served.
+type FeatureVariants struct {
+	Feature  string
+	Variants []Variant
+	Default  string
+}
+
+// WeightedVariant is a variant chosen by a toggle rule. A rule with several
+// variants splits the contexts by weight.
+type WeightedVariant struct {
+	Variant string
+	Weight  int
+}
+
+// Evaluation is a feature enabled for a context and the variant it resolved
+// to. Features without variants resolve to the bool value "true".
+type Evaluation struct {
+	Feature string
+	Variant string
+	Type    string
+	Value   string
+}
+
+// ValidateVariant returns an error if the variant has no name or the value
+// doesn't parse as its type.
+func ValidateVariant(variant Variant) error {
+	if variant.Name == "" {
+		return errors.New("Variant name is missing.")
+	}
+	var err error
+	switch variant.Type {
+	case VariantTypeString:
+	case VariantTypeInt:
+		_, err = strconv.ParseInt(variant.Value, 10, 64)
+	case VariantTypeBool:
+		_, err = strconv.ParseBool(variant.Value)
+	case VariantTypeJson:
+		if !json.Valid([]byte(variant.Value)) {
+			err = errors.New("invalid JSON")
+		}
+	default:
+		return errors.New(fmt.Sprintf("Variant '%s' has unknown type '%s'.", variant.Name, variant.Type))
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("Variant '%s' value is not a %s, %v", variant.Name, variant.Type, err))
+	}
+	return nil
+}
+
+// ValidateFeatureVariants validates the variants, their names must be unique
+// and the default must be one of them.
+func ValidateFeatureVariants(fv FeatureVariants) error {
+	names := make(map[string]bool)
+	for _, v := range fv.Variants {
+		err := ValidateVariant(v)
+		if err != nil {
+			return err
+		}
+		if names[v.Name] {
+			return errors.New(fmt.Sprintf("Variant '%s' is defined twice.", v.Name))
+		}
+		names[v.Name] = true
+	}
+	if fv.Default != "" && !names[fv.Default] {
+		return errors.New(fmt.Sprintf("Default variant '%s' is unknown.", fv.Default))
+	}
+	return nil
+}
+
+// split chooses a variant of a rule. With several variants the bucket of a
+// context is a stable hash of the feature name and the value of the rollout
+// property, independent of the rollout bucket.
+type split struct {
+	variants []WeightedVariant
+	total    int
+}
+
+func newSplit(variants []WeightedVariant) *split {
+	if len(variants) == 0 {
+		return nil
+	}
+	s := &split{variants:make([]WeightedVariant, len(variants))}
+	copy(s.variants, variants)
+	for _, v := range variants {
+		s.total += v.Weight
+	}
+	return s
+}
+
+// ValidateSplit returns an error if the rule variants can't split the contexts.
+func ValidateSplit(variants []WeightedVariant, property string) error {
+	for _, v := range variants {
+		if v.Variant == "" {
+			return errors.New("Rule variant name is missing.")
+		}
+		if v.Weight <= 0 && len(variants) > 1 {
+			return errors.New(fmt.Sprintf("Rule variant '%s' must have a positive weight.", v.Variant))
+		}
+	}
+	if len(variants) > 1 && property == "" {
+		return errors.New("A split between variants requires a rollout property.")
+	}
+	return nil
+}
+
+func (s *split) equals(other *split) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	if len(s.variants) != len(other.variants) {
+		return false
+	}
+	for i := range s.variants {
+		if s.variants[i] != other.variants[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pick returns the variant for the context, "" if the context lacks the
+// property the split is bucketed by.
+func (s *split) pick(feature string, property string, properties Properties) string {
+	if len(s.variants) == 1 {
+		return s.variants[0].Variant
+	}
+	value, ok := properties[property]
+	if !ok {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(feature))
+	h.Write([]byte{1})
+	h.Write([]byte(value))
+	bucket := int(h.Sum32() % uint32(s.total))
+	for _, v := range s.variants {
+		if bucket < v.Weight {
+			return v.Variant
+		}
+		bucket -= v.Weight
+	}
+	return ""
+}
+
+// evaluation resolves the variant served by the rule.
+func (tree *ToggleRuleTree) evaluation(r ruleFeature, properties Properties) Evaluation {
+	fv := tree.variants[r.feature]
+	name := ""
+	if r.split != nil {
+		name = r.split.pick(r.feature, r.rollout.property, properties)
+	}
+	if name == "" {
+		name = fv.Default
+	}
+	if name == "" && len(fv.Variants) > 0 {
+		name = fv.Variants[0].Name
+	}
+	for _, v := range fv.Variants {
+		if v.Name == name {
+			return Evaluation{r.feature, v.Name, v.Type, v.Value}
+		}
+	}
+	return Evaluation{Feature:r.feature, Type:VariantTypeBool, Value:"true"}
+}
+
+// Evaluate returns the features enabled for the properties, like
+// FindFeatures, with the variant each feature resolves to.
+func (tree *ToggleRuleTree) Evaluate(properties Properties) []Evaluation {
+	evaluations := []Evaluation{}
+	for _, m := range winners(tree.root.findFeature(tree.propertyNames, properties, 0, nil)) {
+		evaluations = append(evaluations, tree.evaluation(m.rule, properties))
+	}
+	return evaluations
+}
@@ -0,0 +1,78 @@
+package featuretree
+
+import (
+	"fmt"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateVariant(t *testing.T) {
+	assert.Nil(t, ValidateVariant(Variant{"a", VariantTypeString, "anything"}))
+	assert.Nil(t, ValidateVariant(Variant{"limit", VariantTypeInt, "100"}))
+	assert.Nil(t, ValidateVariant(Variant{"on", VariantTypeBool, "true"}))
+	assert.Nil(t, ValidateVariant(Variant{"config", VariantTypeJson, `{"color": "red"}`}))
+
+	assert.NotNil(t, ValidateVariant(Variant{"", VariantTypeString, "no name"}))
+	assert.NotNil(t, ValidateVariant(Variant{"limit", VariantTypeInt, "many"}))
+	assert.NotNil(t, ValidateVariant(Variant{"on", VariantTypeBool, "yes please"}))
+	assert.NotNil(t, ValidateVariant(Variant{"config", VariantTypeJson, `{"color": `}))
+	assert.NotNil(t, ValidateVariant(Variant{"x", "float", "1.0"}))
+}
+
+func TestSetVariants_invalid(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country"})
+
+	assert.NotNil(t, builder.SetVariants(FeatureVariants{"feature 1", []Variant{{"a", VariantTypeString, "A"}, {"a", VariantTypeString, "B"}}, ""}),
+		"variant names must be unique")
+	assert.NotNil(t, builder.SetVariants(FeatureVariants{"feature 1", []Variant{{"a", VariantTypeString, "A"}}, "b"}),
+		"default must be a variant")
+}
+
+func TestEvaluate_variants(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country"})
+	err := builder.SetVariants(FeatureVariants{"limit", []Variant{{"low", VariantTypeInt, "10"}, {"high", VariantTypeInt, "100"}}, "low"})
+	require.Nil(t, err)
+	builder.AddFeature(ToggleRule{Id: "rule 1", Name: "limit", Properties: Properties{"country": "SE"},
+		Variants: []WeightedVariant{{"high", 1}}})
+	builder.AddFeature(ToggleRule{Id: "rule 2", Name: "limit", Properties: Properties{"country": "NO"}})
+	builder.AddFeature(ToggleRule{Id: "rule 3", Name: "plain", Properties: Properties{"country": "NO"}})
+	tree := builder.Build()
+
+	assert.Equal(t, []Evaluation{{"limit", "high", VariantTypeInt, "100"}}, tree.Evaluate(Properties{"country": "SE"}))
+	assert.Equal(t, []Evaluation{{"limit", "low", VariantTypeInt, "10"}, {"plain", "", VariantTypeBool, "true"}},
+		tree.Evaluate(Properties{"country": "NO"}), "rule without variant should serve the default")
+	assert.Empty(t, tree.Evaluate(Properties{"country": "DK"}))
+}
+
+func TestEvaluate_weighted_split(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country"})
+	builder.SetVariants(FeatureVariants{"test", []Variant{{"a", VariantTypeString, "A"}, {"b", VariantTypeString, "B"}, {"c", VariantTypeString, "C"}}, ""})
+	err := builder.AddFeature(ToggleRule{Id: "rule 1", Name: "test", Properties: Properties{}, RolloutProperty: "userid",
+		Variants: []WeightedVariant{{"a", 50}, {"b", 25}, {"c", 25}}})
+	require.Nil(t, err)
+	tree := builder.Build()
+
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		props := Properties{"userid": fmt.Sprintf("%d", i)}
+		evaluations := tree.Evaluate(props)
+		require.Equal(t, 1, len(evaluations))
+		counts[evaluations[0].Variant]++
+		assert.Equal(t, evaluations, tree.Evaluate(props), "same context should always get the same variant")
+	}
+	assert.InDelta(t, 1000, counts["a"], 150)
+	assert.InDelta(t, 500, counts["b"], 120)
+	assert.InDelta(t, 500, counts["c"], 120)
+
+	assert.Equal(t, "a", tree.Evaluate(Properties{})[0].Variant, "context without the rollout property should get the first variant")
+}
+
+func TestAddFeature_invalid_split(t *testing.T) {
+	builder := NewTreeBuilder([]string{"country"})
+
+	assert.NotNil(t, builder.AddFeature(ToggleRule{Name: "test", Variants: []WeightedVariant{{"a", 50}, {"b", 50}}}),
+		"a split requires a rollout property")
+	assert.NotNil(t, builder.AddFeature(ToggleRule{Name: "test", RolloutProperty: "userid", Variants: []WeightedVariant{{"a", 0}, {"b", 50}}}),
+		"split weights must be positive")
+}
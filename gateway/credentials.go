@@ -0,0 +1,47 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/textproto"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/peterrosell/feature-toggle-service/auth"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// CredentialOptions makes the gateway forward the credentials of a request
+// to the gRPC server. The Authorization header is always forwarded, these
+// options add the X-Api-Key header and the client certificate the request
+// was verified with, which is sent along with the gateway secret. Clients
+// can't pass their own forwarded certificate or secret as Grpc-Metadata-
+// headers.
+func CredentialOptions(gatewaySecret string) []runtime.ServeMuxOption {
+	return []runtime.ServeMuxOption{
+		runtime.WithIncomingHeaderMatcher(credentialHeaderMatcher),
+		runtime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
+			return clientCertMetadata(r, gatewaySecret)
+		}),
+	}
+}
+
+func credentialHeaderMatcher(key string) (string, bool) {
+	switch textproto.CanonicalMIMEHeaderKey(key) {
+	case "X-Api-Key":
+		return auth.API_KEY_METADATA, true
+	case "Grpc-Metadata-" + textproto.CanonicalMIMEHeaderKey(auth.FORWARDED_CLIENT_CERT_METADATA),
+		"Grpc-Metadata-" + textproto.CanonicalMIMEHeaderKey(auth.GATEWAY_SECRET_METADATA):
+		return "", false
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+func clientCertMetadata(r *http.Request, gatewaySecret string) metadata.MD {
+	if gatewaySecret == "" || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return metadata.Pairs(
+		auth.FORWARDED_CLIENT_CERT_METADATA, base64.StdEncoding.EncodeToString(r.TLS.VerifiedChains[0][0].Raw),
+		auth.GATEWAY_SECRET_METADATA, gatewaySecret)
+}
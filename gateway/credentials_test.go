@@ -0,0 +1,41 @@
+package gateway
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/peterrosell/feature-toggle-service/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialHeaderMatcher(t *testing.T) {
+	key, ok := credentialHeaderMatcher("X-Api-Key")
+	assert.True(t, ok, "Should forward the API key")
+	assert.Equal(t, auth.API_KEY_METADATA, key)
+
+	_, ok = credentialHeaderMatcher("Grpc-Metadata-X-Forwarded-Client-Cert")
+	assert.False(t, ok, "Clients should not forward certificates")
+	_, ok = credentialHeaderMatcher("Grpc-Metadata-X-Gateway-Secret")
+	assert.False(t, ok, "Clients should not pass the gateway secret")
+
+	key, ok = credentialHeaderMatcher("Grpc-Metadata-Trace")
+	assert.True(t, ok, "Should keep forwarding other metadata")
+	assert.Equal(t, "Trace", key)
+}
+
+func TestClientCertMetadata(t *testing.T) {
+	cert := &x509.Certificate{Raw:[]byte("der")}
+	req := httptest.NewRequest("GET", "/featuretree?username=adam", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains:[][]*x509.Certificate{{cert}}}
+
+	md := clientCertMetadata(req, "secret")
+	assert.Equal(t, []string{base64.StdEncoding.EncodeToString([]byte("der"))}, md[auth.FORWARDED_CLIENT_CERT_METADATA])
+	assert.Equal(t, []string{"secret"}, md[auth.GATEWAY_SECRET_METADATA])
+
+	assert.Nil(t, clientCertMetadata(req, ""), "Should not forward certificates without a secret")
+	req.TLS = &tls.ConnectionState{}
+	assert.Nil(t, clientCertMetadata(req, "secret"), "Should not forward unverified certificates")
+}
@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	api "github.com/peterrosell/feature-toggle-service/api"
+	impl "github.com/peterrosell/feature-toggle-service/api-impl"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VersionOptions makes the gateway return the version of the feature,
+// property or toggle rule of a response as its ETag header, and answer an
+// update, delete or revert of a stale version with 412 Precondition Failed. The
+// If-Match header is forwarded to the gRPC server, which takes the version
+// from it when the request doesn't give one.
+func VersionOptions() []runtime.ServeMuxOption {
+	return []runtime.ServeMuxOption{
+		runtime.WithForwardResponseOption(setETag),
+		runtime.WithProtoErrorHandler(versionErrorHandler),
+	}
+}
+
+func setETag(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	var version int64
+	switch r := resp.(type) {
+	case interface{ GetFeature() *api.Feature }:
+		version = r.GetFeature().GetVersion()
+	case interface{ GetProperty() *api.Property }:
+		version = r.GetProperty().GetVersion()
+	case interface{ GetToggleRule() *api.ToggleRule }:
+		version = r.GetToggleRule().GetVersion()
+	case *api.RevertToRevisionResponse:
+		version = r.GetRevision().GetFeature().GetVersion()
+	}
+	if version > 0 {
+		w.Header().Set("ETag", impl.ETag(version))
+	}
+	return nil
+}
+
+// versionErrorHandler writes errors with runtime.GlobalHTTPErrorHandler, as
+// the gateway does without the option.
+func versionErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if isVersionMismatch(err) {
+		w = &statusWriter{ResponseWriter:w, status:http.StatusPreconditionFailed}
+	}
+	runtime.GlobalHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
+
+// isVersionMismatch tells if err is the FAILED_PRECONDITION of a stale
+// version.
+func isVersionMismatch(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.FailedPrecondition {
+		return false
+	}
+	for _, detail := range s.Details() {
+		if failure, ok := detail.(*errdetails.PreconditionFailure); ok {
+			for _, violation := range failure.Violations {
+				if violation.Type == impl.VERSION_VIOLATION {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// statusWriter replaces the status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(w.status)
+}
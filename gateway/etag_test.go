@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	api "github.com/peterrosell/feature-toggle-service/api"
+	impl "github.com/peterrosell/feature-toggle-service/api-impl"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSetETag(t *testing.T) {
+	rec := httptest.NewRecorder()
+	setETag(context.Background(), rec, &api.UpdateFeatureResponse{Feature:&api.Feature{Id:"f", Version:3}})
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	rec = httptest.NewRecorder()
+	setETag(context.Background(), rec, &api.RevertToRevisionResponse{Revision:&api.Revision{Feature:&api.Feature{Id:"f", Version:4}}})
+	assert.Equal(t, `"4"`, rec.Header().Get("ETag"), "Should tag a revert with the version of the feature")
+
+	rec = httptest.NewRecorder()
+	setETag(context.Background(), rec, &api.ReadToggleRuleResponse{})
+	assert.Equal(t, "", rec.Header().Get("ETag"), "Should not tag responses without a version")
+}
+
+func TestVersionErrorHandler(t *testing.T) {
+	mux := runtime.NewServeMux()
+	req := httptest.NewRequest("DELETE", "/property/username", nil)
+	stale, _ := status.New(codes.FailedPrecondition, "stale").WithDetails(&errdetails.PreconditionFailure{
+		Violations:[]*errdetails.PreconditionFailure_Violation{{Type:impl.VERSION_VIOLATION, Subject:"property/username"}},
+	})
+
+	rec := httptest.NewRecorder()
+	versionErrorHandler(context.Background(), mux, &runtime.JSONPb{}, rec, req, stale.Err())
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Contains(t, rec.Body.String(), "stale")
+
+	rec = httptest.NewRecorder()
+	versionErrorHandler(context.Background(), mux, &runtime.JSONPb{}, rec, req, status.Error(codes.FailedPrecondition, "referenced"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "Should keep the status of other errors")
+}
+
+func TestIfMatchIsForwarded(t *testing.T) {
+	key, ok := credentialHeaderMatcher("If-Match")
+	assert.True(t, ok)
+	assert.Equal(t, impl.IF_MATCH_METADATA, strings.ToLower(key))
+}
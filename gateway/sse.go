@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// EventStream serves the streaming methods of the gateway as Server-Sent
+// Events to requests that accept text/event-stream, like a browser
+// EventSource. The gateway writes a stream as lines of {"result": message} or
+// {"error": status}, each line is sent as an event with the message, errors
+// as an "error" event. Other requests are passed on unchanged.
+func EventStream(gateway http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok || !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			gateway.ServeHTTP(w, r)
+			return
+		}
+		ew := &eventWriter{ResponseWriter:w, flusher:flusher}
+		gateway.ServeHTTP(ew, r)
+		ew.close()
+	})
+}
+
+// eventWriter turns the lines written by the gateway into events. Responses
+// with an error status, written before any event, are passed on as they are.
+type eventWriter struct {
+	http.ResponseWriter
+	flusher     http.Flusher
+	buf         bytes.Buffer
+	wroteHeader bool
+	passThrough bool
+}
+
+type streamChunk struct {
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+func (w *eventWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code != http.StatusOK {
+		w.passThrough = true
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.passThrough {
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := w.buf.Next(i + 1)
+		err := w.writeEvent(bytes.TrimSpace(line))
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (w *eventWriter) Flush() {
+	w.flusher.Flush()
+}
+
+// close sends what is left, the response of a method that doesn't stream.
+func (w *eventWriter) close() {
+	if !w.passThrough && w.buf.Len() > 0 {
+		w.writeEvent(bytes.TrimSpace(w.buf.Bytes()))
+		w.buf.Reset()
+	}
+	w.flusher.Flush()
+}
+
+func (w *eventWriter) writeEvent(line []byte) error {
+	if len(line) == 0 {
+		return nil
+	}
+	var chunk streamChunk
+	var err error
+	switch {
+	case json.Unmarshal(line, &chunk) != nil:
+		_, err = fmt.Fprintf(w.ResponseWriter, "data: %s\n\n", line)
+	case chunk.Error != nil:
+		_, err = fmt.Fprintf(w.ResponseWriter, "event: error\ndata: %s\n\n", chunk.Error)
+	case chunk.Result != nil:
+		_, err = fmt.Fprintf(w.ResponseWriter, "data: %s\n\n", chunk.Result)
+	default:
+		_, err = fmt.Fprintf(w.ResponseWriter, "data: %s\n\n", line)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func streamingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"result":{"features":["feature 1"]}}`))
+	w.Write([]byte("\n"))
+	w.(http.Flusher).Flush()
+	w.Write([]byte(`{"result":{"features":[]}}` + "\n"))
+	w.Write([]byte(`{"error":{"code":14,"message":"unavailable"}}` + "\n"))
+}
+
+func TestEventStream(t *testing.T) {
+	req := httptest.NewRequest("GET", "/featuretree/watch?username=adam", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+
+	EventStream(http.HandlerFunc(streamingHandler)).ServeHTTP(rec, req)
+
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "data: {\"features\":[\"feature 1\"]}\n\n" +
+		"data: {\"features\":[]}\n\n" +
+		"event: error\ndata: {\"code\":14,\"message\":\"unavailable\"}\n\n", rec.Body.String())
+}
+
+func TestEventStream_passes_on_other_requests(t *testing.T) {
+	req := httptest.NewRequest("GET", "/featuretree/watch?username=adam", nil)
+	rec := httptest.NewRecorder()
+
+	EventStream(http.HandlerFunc(streamingHandler)).ServeHTTP(rec, req)
+
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `{"result":{"features":["feature 1"]}}` + "\n")
+}
+
+func TestEventStream_error_status(t *testing.T) {
+	req := httptest.NewRequest("GET", "/featuretree/watch", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+
+	EventStream(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":5}`))
+	})).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `{"code":5}`, rec.Body.String())
+}
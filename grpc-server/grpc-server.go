@@ -1,27 +1,54 @@
 package main
 
 import (
+	"flag"
 	"net"
 	"github.com/golang/glog"
 
 	api "github.com/peterrosell/feature-toggle-service/api-impl"
+	"github.com/peterrosell/feature-toggle-service/auth"
+	"github.com/peterrosell/feature-toggle-service/storage"
 	"google.golang.org/grpc"
 )
 
+var (
+	store = flag.String("store", "postgres", "feature toggle store to use, 'postgres' or 'memory'")
+	reloadInterval = flag.Duration("reload_interval", api.DEFAULT_RELOAD_INTERVAL, "interval to rebuild the toggle rule tree from the store, 0 disables it")
+	dbConfig = storage.DBConfigFromEnv()
+	authConfig = auth.ConfigFromEnv()
+)
+
+func init() {
+	dbConfig.RegisterFlags(flag.CommandLine)
+	authConfig.RegisterFlags(flag.CommandLine)
+}
 
 func Run() error {
 	l, err := net.Listen("tcp", ":9090")
 	if err != nil {
 		return err
 	}
-	s := grpc.NewServer()
-	api.RegisterFeatureToggleService(s)
+	fs, err := storage.NewFeatureToggleStore(*store, dbConfig)
+	if err != nil {
+		return err
+	}
+	opts := []grpc.ServerOption{}
+	if authConfig.Enabled() {
+		opts, err = auth.ServerOptions(authConfig, fs)
+		if err != nil {
+			return err
+		}
+	}
+	s := grpc.NewServer(opts...)
+	server := api.RegisterFeatureToggleServiceWithStore(s, fs, *reloadInterval)
+	defer server.Close()
 
 	s.Serve(l)
 	return nil
 }
 
 func main() {
+ flag.Parse()
  defer glog.Flush()
 
  if err := Run(); err != nil {
@@ -10,6 +10,7 @@ import (
 	"google.golang.org/grpc"
 
 	api "github.com/peterrosell/feature-toggle-service/api"
+	"github.com/peterrosell/feature-toggle-service/gateway"
 )
 
 var (
@@ -21,14 +22,15 @@ func run() error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	mux := runtime.NewServeMux()
+	// served without TLS, so there are no client certificates to forward
+	mux := runtime.NewServeMux(append(gateway.CredentialOptions(""), gateway.VersionOptions()...)...)
 	opts := []grpc.DialOption{grpc.WithInsecure()}
 	err := api.RegisterFeatureToggleServiceHandlerFromEndpoint(ctx, mux, *echoEndpoint, opts)
 	if err != nil {
 		return err
 	}
 
-	http.ListenAndServe(":8082", mux)
+	http.ListenAndServe(":8082", gateway.EventStream(mux))
 	return nil
 }
 
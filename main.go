@@ -1,5 +1,246 @@
 package main
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"golang.org/x/net/context"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	api "github.com/peterrosell/feature-toggle-service/api"
+	apiimpl "github.com/peterrosell/feature-toggle-service/api-impl"
+	"github.com/peterrosell/feature-toggle-service/auth"
+	"github.com/peterrosell/feature-toggle-service/gateway"
+	"github.com/peterrosell/feature-toggle-service/storage"
+)
+
+var (
+	grpcAddr = flag.String("grpc_addr", ":9090", "address of the gRPC service")
+	httpAddr = flag.String("http_addr", ":8082", "address of the REST gateway")
+	addr = flag.String("addr", "", "serve both gRPC and REST on this address, overrides grpc_addr and http_addr")
+	store = flag.String("store", "postgres", "feature toggle store to use, 'postgres' or 'memory'")
+	reloadInterval = flag.Duration("reload_interval", apiimpl.DEFAULT_RELOAD_INTERVAL, "interval to rebuild the toggle rule tree from the store, 0 disables it")
+	shutdownTimeout = flag.Duration("shutdown_timeout", 10 * time.Second, "time to wait for ongoing requests on shutdown")
+	tlsCert = flag.String("tls_cert", "", "certificate file to serve gRPC and REST over TLS with")
+	tlsKey = flag.String("tls_key", "", "key file of tls_cert")
+	clientCA = flag.String("client_ca", "", "CA file client certificates are verified with, for the mtls authentication method")
+	gatewayCA = flag.String("gateway_ca", "", "CA file the gateway verifies the certificate of the gRPC server with, the system CAs if empty")
+	dbConfig = storage.DBConfigFromEnv()
+	authConfig = auth.ConfigFromEnv()
+)
+
+func init() {
+	dbConfig.RegisterFlags(flag.CommandLine)
+	authConfig.RegisterFlags(flag.CommandLine)
+}
+
+// dialAddr turns a listen address like ":9090" into one the gateway can dial.
+func dialAddr(listenAddr string) string {
+	if strings.HasPrefix(listenAddr, ":") {
+		return "localhost" + listenAddr
+	}
+	return listenAddr
+}
+
+// serverTLSConfig returns the TLS config to serve with, nil without a
+// certificate. Client certificates are optional and verified with the client
+// CA.
+func serverTLSConfig() (*tls.Config, error) {
+	if *tlsCert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates:[]tls.Certificate{cert}, NextProtos:[]string{"h2", "http/1.1"}}
+	if *clientCA != "" {
+		config.ClientCAs, err = certPool(*clientCA)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+func certPool(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New(fmt.Sprintf("No certificates in '%s'", file))
+	}
+	return pool, nil
+}
+
+func newGateway(ctx context.Context, endpoint string, useTLS bool) (http.Handler, error) {
+	mux := runtime.NewServeMux(append(gateway.CredentialOptions(authConfig.GatewaySecret), gateway.VersionOptions()...)...)
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if useTLS {
+		config := &tls.Config{}
+		if *gatewayCA != "" {
+			pool, err := certPool(*gatewayCA)
+			if err != nil {
+				return nil, err
+			}
+			config.RootCAs = pool
+		}
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(config))}
+	}
+	err := api.RegisterFeatureToggleServiceHandlerFromEndpoint(ctx, mux, endpoint, opts)
+	if err != nil {
+		return nil, err
+	}
+	return gateway.EventStream(mux), nil
+}
+
+// grpcHandlerFunc sends gRPC requests to the gRPC server and everything else
+// to the REST gateway, so both can share one port.
+func grpcHandlerFunc(grpcServer *grpc.Server, otherHandler http.Handler) http.Handler {
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+		} else {
+			otherHandler.ServeHTTP(w, r)
+		}
+	}), &http2.Server{})
+}
+
+// authServerOptions returns the interceptors authenticating the requests,
+// none if authentication is disabled. The gateway runs in this process, so it
+// gets a random secret to forward client certificates with unless one is
+// configured.
+func authServerOptions(fs storage.FeatureToggleStore) ([]grpc.ServerOption, error) {
+	if !authConfig.Enabled() {
+		if authConfig.RBAC {
+			return nil, errors.New("-rbac requires -auth")
+		}
+		glog.Warning("Authentication is disabled, enable it with -auth")
+		return []grpc.ServerOption{}, nil
+	}
+	if authConfig.GatewaySecret == "" {
+		secret, err := auth.NewGatewaySecret()
+		if err != nil {
+			return nil, err
+		}
+		authConfig.GatewaySecret = secret
+	}
+	glog.Infof("Authenticating requests with %s, role based access control %v", authConfig.Methods, authConfig.RBAC)
+	return auth.ServerOptions(authConfig, fs)
+}
+
+func run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fs, err := storage.NewFeatureToggleStore(*store, dbConfig)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		return err
+	}
+	serverOptions, err := authServerOptions(fs)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil && *addr == "" {
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
+	server := apiimpl.RegisterFeatureToggleServiceWithStore(grpcServer, fs, *reloadInterval)
+	defer server.Close()
+
+	errc := make(chan error, 2)
+	var httpServer *http.Server
+	if *addr != "" {
+		gatewayHandler, err := newGateway(ctx, dialAddr(*addr), tlsConfig != nil)
+		if err != nil {
+			return err
+		}
+		httpServer = &http.Server{Addr: *addr, Handler: grpcHandlerFunc(grpcServer, gatewayHandler), TLSConfig: tlsConfig}
+		glog.Infof("Serving gRPC and REST on %s", *addr)
+	} else {
+		l, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			glog.Infof("Serving gRPC on %s", *grpcAddr)
+			errc <- grpcServer.Serve(l)
+		}()
+
+		gatewayHandler, err := newGateway(ctx, dialAddr(*grpcAddr), tlsConfig != nil)
+		if err != nil {
+			return err
+		}
+		httpServer = &http.Server{Addr: *httpAddr, Handler: gatewayHandler, TLSConfig: tlsConfig}
+		glog.Infof("Serving REST on %s", *httpAddr)
+	}
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			errc <- err
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err = <-errc:
+	case sig := <-signals:
+		glog.Infof("Received %v, shutting down", sig)
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancelShutdown()
+	httpServer.Shutdown(shutdownCtx)
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+	}
+	return err
+}
+
 func main() {
+	flag.Parse()
+	defer glog.Flush()
 
+	if err := run(); err != nil {
+		glog.Fatal(err)
+	}
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/peterrosell/feature-toggle-service/storage"
+)
+
+var (
+	dbConfig = storage.DBConfigFromEnv()
+)
+
+func init() {
+	dbConfig.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] up|down [steps]|goto <version>|version\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("missing command")
+	}
+	db, err := storage.OpenDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator := storage.NewMigrator(db)
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				return err
+			}
+		}
+		err = migrator.Down(steps)
+	case "goto":
+		if len(args) < 2 {
+			return errors.New("goto requires a version")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		err = migrator.MigrateTo(version)
+		if err != nil {
+			return err
+		}
+	case "version":
+	default:
+		flag.Usage()
+		return errors.New(fmt.Sprintf("unknown command '%s'", args[0]))
+	}
+	if err != nil {
+		return err
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema version %d, latest %d\n", version, migrator.LatestVersion())
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if err := run(flag.Args()); err != nil {
+		glog.Fatal(err)
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// Operations recorded in the audit log.
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// ANONYMOUS_ACTOR is the actor of changes made through a store without one.
+const ANONYMOUS_ACTOR = "anonymous"
+
+// AuditEntry records a change of the store, written along with the change.
+// Kind is one of Kind*, Before and After are the entity as JSON, empty when
+// it didn't or doesn't exist.
+type AuditEntry struct {
+	Id        string
+	Actor     string
+	Time      time.Time
+	Kind      string
+	EntityId  string
+	Operation string
+	Before    string
+	After     string
+}
+
+// AuditSearch selects audit entries, fields left unset don't filter. The time
+// range is inclusive and Limit caps the number of entries, 0 returns all.
+type AuditSearch struct {
+	Kind     string
+	EntityId string
+	Actor    string
+	Start    time.Time
+	End      time.Time
+	Limit    int
+}
+
+// newAuditEntry returns the entry of a change by actor, before and after are
+// marshalled to JSON unless nil.
+func newAuditEntry(actor string, kind string, entityId string, operation string, before interface{}, after interface{}) (*AuditEntry, error) {
+	beforeJson, err := toJson(before)
+	if err != nil {
+		return nil, err
+	}
+	afterJson, err := toJson(after)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditEntry{Id:uuid.NewV4().String(), Actor:actor, Time:time.Now(), Kind:kind, EntityId:entityId,
+		Operation:operation, Before:beforeJson, After:afterJson}, nil
+}
+
+func toJson(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Failed to marshal audit entry, %v", err))
+	}
+	return string(b), nil
+}
+
+func (search AuditSearch) matches(entry AuditEntry) bool {
+	return (search.Kind == "" || search.Kind == entry.Kind) &&
+		(search.EntityId == "" || search.EntityId == entry.EntityId) &&
+		(search.Actor == "" || search.Actor == entry.Actor) &&
+		inRange(entry.Time, search.Start, search.End)
+}
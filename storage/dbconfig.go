@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_DB_HOST = "localhost"
+	DEFAULT_DB_PORT = 5432
+	DEFAULT_DB_USER = "featuretoggle"
+	DEFAULT_DB_NAME = "featuretoggle"
+	DEFAULT_DB_SSLMODE = "disable"
+	DEFAULT_DB_CONNECT_RETRIES = 5
+	DEFAULT_DB_CONNECT_RETRY_INTERVAL = 2 * time.Second
+)
+
+var sslModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+// DBConfig holds the connection settings for FeatureToggleStoreImpl.
+// If DSN is set it is passed to the driver as is and the individual
+// connection fields are ignored, the pool and retry settings always apply.
+type DBConfig struct {
+	DSN         string
+	Host        string
+	Port        int
+	User        string
+	Password    string
+	Name        string
+	SSLMode     string
+	SSLCert     string
+	SSLKey      string
+	SSLRootCert string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	ConnectRetries       int
+	ConnectRetryInterval time.Duration
+
+	AutoMigrate bool
+
+	// malformed is the environment variables DBConfigFromEnv couldn't parse,
+	// reported by Validate unless the flag of the variable was set.
+	malformed []malformedEnv
+	flags     *flag.FlagSet
+}
+
+// malformedEnv is an environment variable with a value that couldn't be
+// parsed, its flag is the lower case name.
+type malformedEnv struct {
+	name  string
+	value string
+}
+
+func DefaultDBConfig() DBConfig {
+	return DBConfig{
+		Host: DEFAULT_DB_HOST,
+		Port: DEFAULT_DB_PORT,
+		User: DEFAULT_DB_USER,
+		Name: DEFAULT_DB_NAME,
+		SSLMode: DEFAULT_DB_SSLMODE,
+		ConnectRetries: DEFAULT_DB_CONNECT_RETRIES,
+		ConnectRetryInterval: DEFAULT_DB_CONNECT_RETRY_INTERVAL,
+		AutoMigrate: true,
+	}
+}
+
+// DBConfigFromEnv returns the default configuration overridden by any of the
+// DB_DSN, DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME, DB_SSLMODE,
+// DB_SSLCERT, DB_SSLKEY, DB_SSLROOTCERT, DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS,
+// DB_CONN_MAX_LIFETIME, DB_CONNECT_RETRIES, DB_CONNECT_RETRY_INTERVAL and
+// DB_AUTO_MIGRATE environment variables. Malformed values keep the default
+// and make Validate fail.
+func DBConfigFromEnv() DBConfig {
+	config := DefaultDBConfig()
+	malformed := []malformedEnv{}
+
+	envString(&config.DSN, "DB_DSN")
+	envString(&config.Host, "DB_HOST")
+	envInt(&config.Port, "DB_PORT", &malformed)
+	envString(&config.User, "DB_USER")
+	envString(&config.Password, "DB_PASSWORD")
+	envString(&config.Name, "DB_NAME")
+	envString(&config.SSLMode, "DB_SSLMODE")
+	envString(&config.SSLCert, "DB_SSLCERT")
+	envString(&config.SSLKey, "DB_SSLKEY")
+	envString(&config.SSLRootCert, "DB_SSLROOTCERT")
+	envInt(&config.MaxOpenConns, "DB_MAX_OPEN_CONNS", &malformed)
+	envInt(&config.MaxIdleConns, "DB_MAX_IDLE_CONNS", &malformed)
+	envDuration(&config.ConnMaxLifetime, "DB_CONN_MAX_LIFETIME", &malformed)
+	envInt(&config.ConnectRetries, "DB_CONNECT_RETRIES", &malformed)
+	envDuration(&config.ConnectRetryInterval, "DB_CONNECT_RETRY_INTERVAL", &malformed)
+	envBool(&config.AutoMigrate, "DB_AUTO_MIGRATE", &malformed)
+	config.malformed = malformed
+
+	return config
+}
+
+// RegisterFlags binds the configuration to command line flags. The current
+// values are used as flag defaults, so flags override environment variables
+// when the config comes from DBConfigFromEnv, also malformed ones.
+func (config *DBConfig) RegisterFlags(flags *flag.FlagSet) {
+	config.flags = flags
+	flags.StringVar(&config.DSN, "db_dsn", config.DSN, "database connection string, overrides the other db_ connection flags")
+	flags.StringVar(&config.Host, "db_host", config.Host, "database host")
+	flags.IntVar(&config.Port, "db_port", config.Port, "database port")
+	flags.StringVar(&config.User, "db_user", config.User, "database user")
+	flags.StringVar(&config.Password, "db_password", config.Password, "database password")
+	flags.StringVar(&config.Name, "db_name", config.Name, "database name")
+	flags.StringVar(&config.SSLMode, "db_sslmode", config.SSLMode, "database ssl mode, one of " + strings.Join(sslModes, ", "))
+	flags.StringVar(&config.SSLCert, "db_sslcert", config.SSLCert, "database client certificate file")
+	flags.StringVar(&config.SSLKey, "db_sslkey", config.SSLKey, "database client key file")
+	flags.StringVar(&config.SSLRootCert, "db_sslrootcert", config.SSLRootCert, "database root certificate file")
+	flags.IntVar(&config.MaxOpenConns, "db_max_open_conns", config.MaxOpenConns, "max open database connections, 0 is unlimited")
+	flags.IntVar(&config.MaxIdleConns, "db_max_idle_conns", config.MaxIdleConns, "max idle database connections")
+	flags.DurationVar(&config.ConnMaxLifetime, "db_conn_max_lifetime", config.ConnMaxLifetime, "max lifetime of a database connection, 0 is unlimited")
+	flags.IntVar(&config.ConnectRetries, "db_connect_retries", config.ConnectRetries, "number of retries when the database can't be reached at startup")
+	flags.DurationVar(&config.ConnectRetryInterval, "db_connect_retry_interval", config.ConnectRetryInterval, "time to wait between connection retries")
+	flags.BoolVar(&config.AutoMigrate, "db_auto_migrate", config.AutoMigrate, "apply pending schema migrations when the store is opened")
+}
+
+func (config DBConfig) Validate() error {
+	err := config.envError()
+	if err != nil {
+		return err
+	}
+	if config.DSN != "" {
+		return nil
+	}
+	if config.Host == "" {
+		return errors.New("DBConfig: host is required")
+	}
+	if config.Port <= 0 || config.Port > 65535 {
+		return errors.New(fmt.Sprintf("DBConfig: invalid port %d", config.Port))
+	}
+	if config.Name == "" {
+		return errors.New("DBConfig: database name is required")
+	}
+	for _, mode := range sslModes {
+		if strings.Compare(mode, config.SSLMode) == 0 {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("DBConfig: invalid ssl mode '%s', must be one of %s", config.SSLMode, strings.Join(sslModes, ", ")))
+}
+
+// envError returns the malformed environment variables whose flags weren't
+// set.
+func (config DBConfig) envError() error {
+	set := make(map[string]bool)
+	if config.flags != nil {
+		config.flags.Visit(func(f *flag.Flag) {
+			set[f.Name] = true
+		})
+	}
+	malformed := []string{}
+	for _, env := range config.malformed {
+		if !set[strings.ToLower(env.name)] {
+			malformed = append(malformed, fmt.Sprintf("%s='%s'", env.name, env.value))
+		}
+	}
+	if len(malformed) == 0 {
+		return nil
+	}
+	return errors.New(fmt.Sprintf("DBConfig: malformed environment variables %s", strings.Join(malformed, ", ")))
+}
+
+// DataSourceName returns the connection string given to the postgres driver.
+func (config DBConfig) DataSourceName() string {
+	if config.DSN != "" {
+		return config.DSN
+	}
+	var buffer bytes.Buffer
+	writeDSNParam(&buffer, "host", config.Host)
+	writeDSNParam(&buffer, "port", strconv.Itoa(config.Port))
+	writeDSNParam(&buffer, "user", config.User)
+	writeDSNParam(&buffer, "password", config.Password)
+	writeDSNParam(&buffer, "dbname", config.Name)
+	writeDSNParam(&buffer, "sslmode", config.SSLMode)
+	writeDSNParam(&buffer, "sslcert", config.SSLCert)
+	writeDSNParam(&buffer, "sslkey", config.SSLKey)
+	writeDSNParam(&buffer, "sslrootcert", config.SSLRootCert)
+	return buffer.String()
+}
+
+func writeDSNParam(buffer *bytes.Buffer, key string, value string) {
+	if value == "" {
+		return
+	}
+	if buffer.Len() > 0 {
+		buffer.WriteString(" ")
+	}
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `'`, `\'`, -1)
+	buffer.WriteString(fmt.Sprintf("%s='%s'", key, value))
+}
+
+func envString(value *string, name string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*value = v
+	}
+}
+
+func envInt(value *int, name string, malformed *[]malformedEnv) {
+	if v, ok := os.LookupEnv(name); ok {
+		if i, err := strconv.Atoi(v); err == nil {
+			*value = i
+		} else {
+			*malformed = append(*malformed, malformedEnv{name:name, value:v})
+		}
+	}
+}
+
+func envDuration(value *time.Duration, name string, malformed *[]malformedEnv) {
+	if v, ok := os.LookupEnv(name); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			*value = d
+		} else {
+			*malformed = append(*malformed, malformedEnv{name:name, value:v})
+		}
+	}
+}
+
+func envBool(value *bool, name string, malformed *[]malformedEnv) {
+	if v, ok := os.LookupEnv(name); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			*value = b
+		} else {
+			*malformed = append(*malformed, malformedEnv{name:name, value:v})
+		}
+	}
+}
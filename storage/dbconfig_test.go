@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBConfig_DataSourceName(t *testing.T) {
+	config := DefaultDBConfig()
+	config.Password = "it's secret"
+
+	assert.Equal(t, `host='localhost' port='5432' user='featuretoggle' password='it\'s secret' dbname='featuretoggle' sslmode='disable'`, config.DataSourceName())
+}
+
+func TestDBConfig_DataSourceName__dsn(t *testing.T) {
+	config := DefaultDBConfig()
+	config.DSN = "postgres://user:pw@db:5433/ft?sslmode=require"
+
+	assert.Equal(t, config.DSN, config.DataSourceName(), "DSN should be used as is")
+}
+
+func TestDBConfig_Validate(t *testing.T) {
+	config := DefaultDBConfig()
+	assert.Nil(t, config.Validate(), "default config should be valid")
+
+	config.SSLMode = "sometimes"
+	assert.NotNil(t, config.Validate(), "unknown ssl mode should be invalid")
+
+	config = DefaultDBConfig()
+	config.Port = 0
+	assert.NotNil(t, config.Validate(), "port 0 should be invalid")
+}
+
+func TestDBConfigFromEnv(t *testing.T) {
+	os.Setenv("DB_HOST", "db.example.com")
+	os.Setenv("DB_PORT", "5433")
+	os.Setenv("DB_SSLMODE", "verify-full")
+	os.Setenv("DB_CONN_MAX_LIFETIME", "5m")
+	defer os.Unsetenv("DB_HOST")
+	defer os.Unsetenv("DB_PORT")
+	defer os.Unsetenv("DB_SSLMODE")
+	defer os.Unsetenv("DB_CONN_MAX_LIFETIME")
+
+	config := DBConfigFromEnv()
+
+	assert.Equal(t, "db.example.com", config.Host)
+	assert.Equal(t, 5433, config.Port)
+	assert.Equal(t, "verify-full", config.SSLMode)
+	assert.Equal(t, 5 * time.Minute, config.ConnMaxLifetime)
+	assert.Equal(t, DEFAULT_DB_USER, config.User, "unset variables should keep the default")
+}
+
+func TestDBConfigFromEnv__malformed(t *testing.T) {
+	os.Setenv("DB_PORT", "five")
+	os.Setenv("DB_AUTO_MIGRATE", "sometimes")
+	os.Setenv("DB_DSN", "postgres://user:pw@db:5433/ft")
+	defer os.Unsetenv("DB_PORT")
+	defer os.Unsetenv("DB_AUTO_MIGRATE")
+	defer os.Unsetenv("DB_DSN")
+
+	config := DBConfigFromEnv()
+
+	assert.Equal(t, DEFAULT_DB_PORT, config.Port, "malformed variables should keep the default")
+	err := config.Validate()
+	if assert.NotNil(t, err, "malformed variables should be invalid, also with a DSN") {
+		assert.Contains(t, err.Error(), "DB_PORT='five'")
+		assert.Contains(t, err.Error(), "DB_AUTO_MIGRATE='sometimes'")
+	}
+}
+
+func TestDBConfigFromEnv__malformed_overridden_by_flag(t *testing.T) {
+	os.Setenv("DB_PORT", "five")
+	os.Setenv("DB_AUTO_MIGRATE", "sometimes")
+	defer os.Unsetenv("DB_PORT")
+	defer os.Unsetenv("DB_AUTO_MIGRATE")
+	config := DBConfigFromEnv()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.RegisterFlags(flags)
+
+	err := flags.Parse([]string{"-db_port=5433"})
+	assert.Nil(t, err)
+
+	err = config.Validate()
+	if assert.NotNil(t, err, "variables without a flag should still be invalid") {
+		assert.NotContains(t, err.Error(), "DB_PORT")
+		assert.Contains(t, err.Error(), "DB_AUTO_MIGRATE='sometimes'")
+	}
+
+	err = flags.Parse([]string{"-db_auto_migrate=false"})
+	assert.Nil(t, err)
+	assert.Nil(t, config.Validate(), "flags should override malformed variables")
+	assert.Equal(t, 5433, config.Port)
+}
+
+func TestDBConfig_RegisterFlags(t *testing.T) {
+	config := DefaultDBConfig()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.RegisterFlags(flags)
+
+	err := flags.Parse([]string{"-db_host=flaghost", "-db_max_open_conns=10"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "flaghost", config.Host)
+	assert.Equal(t, 10, config.MaxOpenConns)
+	assert.Equal(t, DEFAULT_DB_PORT, config.Port, "unset flags should keep the current value")
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of entities named in errors.
+const (
+	KindFeature      = "feature"
+	KindProperty     = "property"
+	KindToggleRule   = "toggle rule"
+	KindVariant      = "variant"
+	KindApiKey       = "api key"
+	KindRoleBinding  = "role binding"
+	KindFeatureOwner = "feature owner"
+	KindRevision     = "revision"
+)
+
+// NotFoundError is returned when an entity an operation refers to doesn't
+// exist.
+type NotFoundError struct {
+	Kind string
+	Key  string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("Unknown %s '%s'", e.Kind, e.Key)
+}
+
+// AlreadyExistsError is returned when an entity with the same id or name
+// already exists.
+type AlreadyExistsError struct {
+	Kind string
+	Key  string
+}
+
+func (e *AlreadyExistsError) Error() string {
+	return fmt.Sprintf("The %s '%s' already exists", e.Kind, e.Key)
+}
+
+// ReferencedError is returned when an entity can't be deleted or changed
+// because another entity refers to it.
+type ReferencedError struct {
+	Kind   string
+	Key    string
+	ByKind string
+	ByKey  string
+}
+
+func (e *ReferencedError) Error() string {
+	return fmt.Sprintf("The %s '%s' is referenced by %s '%s'", e.Kind, e.Key, e.ByKind, e.ByKey)
+}
+
+// InvalidArgumentError is returned when a field of an entity has an invalid
+// value. Field is named as in the api messages.
+type InvalidArgumentError struct {
+	Field       string
+	Description string
+}
+
+func (e *InvalidArgumentError) Error() string {
+	return fmt.Sprintf("Invalid %s, %s", e.Field, e.Description)
+}
+
+// VersionMismatchError is returned when an entity is updated or deleted with
+// another version than the stored one, it was changed since it was read.
+type VersionMismatchError struct {
+	Kind    string
+	Key     string
+	Version int64
+	Current int64
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("The %s '%s' is at version %d, not %d", e.Kind, e.Key, e.Current, e.Version)
+}
+
+// checkVersion returns a VersionMismatchError unless version is the current
+// version of the entity.
+func checkVersion(kind string, key string, version int64, current int64) error {
+	if version != current {
+		return &VersionMismatchError{Kind:kind, Key:key, Version:version, Current:current}
+	}
+	return nil
+}
+
+func invalidArgument(field string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &InvalidArgumentError{Field:field, Description:err.Error()}
+}
+
+// sqlError returns an AlreadyExistsError if err is a unique constraint
+// violation when writing the entity kind with key, otherwise fallback.
+func sqlError(err error, kind string, key string, fallback error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+		return &AlreadyExistsError{Kind:kind, Key:key}
+	}
+	return fallback
+}
+
+// isForeignKeyViolation tells if err violates the named foreign key
+// constraint of the migrations in schema.go, e.g. fk_feature.
+func isForeignKeyViolation(err error, constraint string) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code.Name() == "foreign_key_violation" && pqErr.Constraint == constraint
+}
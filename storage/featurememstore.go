@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"sync"
+)
+
+// FeatureToggleMemStore is an in-memory FeatureToggleStore. It enforces the
+// same primary key, unique and foreign key constraints as the migrations in
+// schema.go so it can be used in place of FeatureToggleStoreImpl when no
+// database is available.
+type FeatureToggleMemStore struct {
+	*memState
+	actor string
+}
+
+// memState is shared by a store and the stores returned by its WithActor.
+type memState struct {
+	mutex       sync.RWMutex
+	features    map[string]Feature
+	properties  map[string]Property
+	toggleRules map[string]ToggleRule
+	apiKeys     map[string]ApiKey
+	roles       map[string]RoleBinding
+	owners      map[string]map[string]bool
+	auditLog    []AuditEntry
+	revisions   map[string][]Revision
+}
+
+func NewFeatureToggleMemStore() *FeatureToggleMemStore {
+	fs := &FeatureToggleMemStore{memState:new(memState), actor:ANONYMOUS_ACTOR}
+	fs.init()
+	return fs
+}
+
+func (fs *FeatureToggleMemStore) init() {
+	fs.features = make(map[string]Feature)
+	fs.properties = make(map[string]Property)
+	fs.toggleRules = make(map[string]ToggleRule)
+	fs.apiKeys = make(map[string]ApiKey)
+	fs.roles = make(map[string]RoleBinding)
+	fs.owners = make(map[string]map[string]bool)
+	fs.auditLog = []AuditEntry{}
+	fs.revisions = make(map[string][]Revision)
+}
+
+func (fs *FeatureToggleMemStore) Open() error {
+	if fs.memState == nil {
+		fs.memState = new(memState)
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if fs.features == nil {
+		fs.init()
+	}
+	return nil
+}
+
+func (fs *FeatureToggleMemStore) Close() {
+}
+
+// WithActor returns a store sharing the data of this one, recording the
+// changes made through it as made by actor.
+func (fs *FeatureToggleMemStore) WithActor(actor string) FeatureToggleStore {
+	return &FeatureToggleMemStore{memState:fs.memState, actor:actor}
+}
+
+func copyProperties(properties Properties) Properties {
+	props := make(Properties)
+	for k, v := range properties {
+		props[k] = v
+	}
+	return props
+}
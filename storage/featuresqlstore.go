@@ -2,39 +2,84 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 	_ "github.com/lib/pq"
 )
 
-const (
-	DB_USER = "featuretoggle"
-	DB_PASSWORD = "ftftft"
-	DB_NAME = "featuretoggle"
-	DB_HOST = "europe"
-)
-
 
 type FeatureToggleStoreImpl struct {
-	db *sql.DB
+	config DBConfig
+	db     *sql.DB
+	actor  string
 }
 
-func NewFeatureToggleStoreImpl() *FeatureToggleStoreImpl {
-	return new(FeatureToggleStoreImpl)
+func NewFeatureToggleStoreImpl(config DBConfig) *FeatureToggleStoreImpl {
+	return &FeatureToggleStoreImpl{config:config, actor:ANONYMOUS_ACTOR}
 }
 
 func (fs *FeatureToggleStoreImpl) Open() error {
-	dbinfo := fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable",
-		DB_HOST, DB_USER, DB_PASSWORD, DB_NAME)
-	db, err := sql.Open("postgres", dbinfo)
-	if err == nil {
-		fs.db = db
+	db, err := OpenDB(fs.config)
+	if err != nil {
+		return err
 	}
-	return err
+	if fs.config.AutoMigrate {
+		err = NewMigrator(db).Up()
+		if err != nil {
+			db.Close()
+			return err
+		}
+	}
+	fs.db = db
+	return nil
 }
 
-func (fs *FeatureToggleStoreImpl) Close() {
-	fs.db.Close()
+// OpenDB opens a connection pool from the config and pings the database,
+// with retries, to make sure it can be reached.
+func OpenDB(config DBConfig) (*sql.DB, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", config.DataSourceName())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	err = ping(db, config.ConnectRetries, config.ConnectRetryInterval)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
+func ping(db *sql.DB, retries int, interval time.Duration) error {
+	for i := 0; ; i++ {
+		err := db.Ping()
+		if err == nil {
+			return nil
+		}
+		if i >= retries {
+			return errors.New(fmt.Sprintf("Failed to connect to database after %d attempts, %v", i + 1, err))
+		}
+		fmt.Printf("Failed to connect to database, retrying in %v, %v\n", interval, err)
+		time.Sleep(interval)
+	}
+}
 
+// WithActor returns a store sharing the connection pool of this one, recording
+// the changes made through it as made by actor. It must not be closed.
+func (fs *FeatureToggleStoreImpl) WithActor(actor string) FeatureToggleStore {
+	return &FeatureToggleStoreImpl{config:fs.config, db:fs.db, actor:actor}
+}
 
+func (fs *FeatureToggleStoreImpl) Close() {
+	if fs.db != nil {
+		fs.db.Close()
+	}
+}
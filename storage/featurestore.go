@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 	"github.com/satori/go.uuid"
 	"github.com/peterrosell/feature-toggle-service/featuretree"
@@ -15,8 +18,47 @@ type ToggleRule struct {
 	FeatureId  string
 	Enabled    bool
 	Created    time.Time
+	// Starts and Expires is the window the rule is active in, zero leaves
+	// that side open.
+	Starts     time.Time
 	Expires    time.Time
 	Properties Properties
+	// RolloutPercentage limits the rule to a share, 1-100, of the contexts,
+	// bucketed by the value of RolloutProperty. 0 means all contexts.
+	RolloutPercentage int
+	RolloutProperty   string
+	// Operators maps a property to the operator its value is matched with,
+	// see featuretree.Operator*. Properties without one are matched exactly.
+	Operators map[string]string
+	// Deny turns the feature off for matching contexts. The rule with the
+	// highest Priority wins, see featuretree.ToggleRuleTree.FindFeatures.
+	Deny     bool
+	Priority int
+	// Variants chooses the feature variants served by the rule, several
+	// variants split the contexts by weight, bucketed by RolloutProperty.
+	Variants []featuretree.WeightedVariant
+	// Version is 1 when the rule is created and increased by every update.
+	Version int64
+}
+
+// ToggleRuleSearch selects toggle rules, fields left unset don't filter. Name
+// is the feature name, the time ranges are inclusive and Properties must all
+// be on the rule with the given values.
+type ToggleRuleSearch struct {
+	Name         *string
+	Enabled      *bool
+	CreatedStart time.Time
+	CreatedEnd   time.Time
+	ExpiresStart time.Time
+	ExpiresEnd   time.Time
+	Properties   Filter
+}
+
+// FeatureSearch selects features, fields left unset don't filter. Name
+// matches every feature whose name contains it, ignoring case.
+type FeatureSearch struct {
+	Name    string
+	Enabled *bool
 }
 
 type Feature struct {
@@ -24,44 +66,132 @@ type Feature struct {
 	Name        string
 	Enabled     bool
 	Description string
+	// Variants are the values the feature can resolve to. DefaultVariant is
+	// served by rules that don't choose one, if empty the first variant is.
+	Variants       []featuretree.Variant
+	DefaultVariant string
+	// Starts and Expires is the window the feature is enabled in, it limits
+	// the window of each of its rules.
+	Starts  time.Time
+	Expires time.Time
+	// Version is 1 when the feature is created and increased by every update.
+	Version int64
 }
 
 type Property struct {
 	Name        string
 	Description string
+	Version     int64
+}
+
+// ApiKey is a static key clients authenticate with. Only the sha256 of the
+// key, hex encoded, is stored. Scope is what the key gives access to, see
+// auth.ScopeEvaluate and auth.ScopeAdmin.
+type ApiKey struct {
+	Id      string
+	Name    string
+	Hash    string `json:"-"`
+	Scope   string
+	Created time.Time
+}
+
+// RoleBinding gives a principal, "<method>:<name>" e.g. "jwt:adam", a role,
+// see auth.Role*.
+type RoleBinding struct {
+	Principal string
+	Role      string
+}
+
+// FeatureOwner makes a principal an owner of a feature. A feature with owners
+// can only be changed by them and by admins.
+type FeatureOwner struct {
+	FeatureId string
+	Principal string
 }
 
 type FeatureToggleStore interface {
+	// GetEnabledToggleRules returns the enabled rules of enabled features that
+	// have not expired, also those that have not started yet. The window of
+	// each rule is narrowed to the window of its feature.
 	GetEnabledToggleRules() (*[]featuretree.ToggleRule, error)
+	GetFeatureVariants() (*[]featuretree.FeatureVariants, error)
 
 	CreateFeature(feature Feature) (*string, error)
 	ReadFeature(id string) (*Feature, error)
 	ReadFeatureByName(name string) (*Feature, error)
-	DeleteFeature(id string) (*bool, error)
-	SearchFeature(name string) (*[]Feature, error)
+	// DeleteFeature and DeleteProperty refuse to delete what a toggle rule
+	// refers to, the rules must be deleted first. Deletes and updates return
+	// a VersionMismatchError unless given the current version.
+	DeleteFeature(id string, version int64) (*bool, error)
+	// SearchFeature returns the matching features ordered by name. Names and
+	// principals are ordered byte-wise, upper case first, whatever the
+	// collation of the database.
+	SearchFeature(search FeatureSearch) (*[]Feature, error)
+	// UpdateFeature changes the fields of the stored feature named by paths,
+	// see update.go, and returns the updated feature, nil if it is unknown.
+	// The version of the update must be the current one.
+	UpdateFeature(feature Feature, paths []string) (*Feature, error)
 
 	CreateProperty(property Property) (*string, error)
 	ReadProperty(name string) (*Property, error)
 	ReadAllPropertyNames() (*[]string, error)
-	DeleteProperty(name string) (*bool, error)
+	DeleteProperty(name string, version int64) (*bool, error)
+	// SearchProperty returns the properties whose name contains name,
+	// ignoring case, ordered by name.
 	SearchProperty(name string) (*[]Property, error)
+	UpdateProperty(property Property, paths []string) (*Property, error)
 
 
 	CreateToggleRule(toggleRule ToggleRule) (*string, error)
 	ReadToggleRule(id string) (*ToggleRule, error)
-	DeleteToggleRule(id string) (*bool, error)
-	SearchToggleRule(name *string, filter Filter) (*[]ToggleRule, error)
+	DeleteToggleRule(id string, version int64) (*bool, error)
+	SearchToggleRule(search ToggleRuleSearch) (*[]ToggleRule, error)
+	UpdateToggleRule(toggleRule ToggleRule, paths []string) (*ToggleRule, error)
+
+	CreateApiKey(apiKey ApiKey) (*string, error)
+	// ReadApiKeyByHash returns the key with the hash, nil if it is unknown.
+	ReadApiKeyByHash(hash string) (*ApiKey, error)
+	DeleteApiKey(id string) (*bool, error)
+	// ListApiKeys returns all keys ordered by name.
+	ListApiKeys() (*[]ApiKey, error)
+
+	// SetRoleBinding gives the principal the role, replacing its current one.
+	SetRoleBinding(binding RoleBinding) error
+	ReadRoleBinding(principal string) (*RoleBinding, error)
+	DeleteRoleBinding(principal string) (*bool, error)
+	// ListRoleBindings returns all bindings ordered by principal.
+	ListRoleBindings() (*[]RoleBinding, error)
+	// AddFeatureOwner refuses unknown features, the owners of a feature are
+	// removed with it. Adding an owner twice, or removing one that isn't,
+	// records nothing in the audit log.
+	AddFeatureOwner(owner FeatureOwner) error
+	RemoveFeatureOwner(owner FeatureOwner) (*bool, error)
+	// ReadFeatureOwners returns the principals owning the feature, sorted.
+	ReadFeatureOwners(featureId string) (*[]string, error)
+
+	// ListRevisions returns the revisions of the feature, newest first.
+	ListRevisions(featureId string) (*[]Revision, error)
+	// RevertToRevision restores the feature and its toggle rules as they were
+	// in the revision. The restored state is recorded as a new revision,
+	// which is returned. The version must be the current one of the feature.
+	RevertToRevision(featureId string, revision int, version int64) (*Revision, error)
+
+	// WithActor returns a store sharing the data of this one which records
+	// the changes made through it in the audit log as made by actor.
+	WithActor(actor string) FeatureToggleStore
+	// SearchAuditLog returns the matching entries, newest first.
+	SearchAuditLog(search AuditSearch) (*[]AuditEntry, error)
 
 	Open() error
 	Close()
 }
 
 func NewFeature(name string, enabled bool, description string) *Feature {
-	return &Feature{uuid.NewV4().String(), name, enabled, description}
+	return &Feature{Id:uuid.NewV4().String(), Name:name, Enabled:enabled, Description:description}
 }
 
 func NewProperty(name string, description string) *Property {
-	return &Property{name, description}
+	return &Property{Name:name, Description:description}
 }
 
 func NewToggleRule(featureId string, enabled bool, propArgs... string) *ToggleRule {
@@ -73,3 +203,143 @@ func NewToggleRule(featureId string, enabled bool, propArgs... string) *ToggleRu
 	toggleRule := ToggleRule{FeatureId:featureId, Properties:props, Enabled:enabled}
 	return &toggleRule
 }
+
+// NewFeatureToggleStore returns the store of the given kind, "postgres" or "memory".
+func NewFeatureToggleStore(kind string, config DBConfig) (FeatureToggleStore, error) {
+	switch kind {
+	case "postgres":
+		return NewFeatureToggleStoreImpl(config), nil
+	case "memory":
+		return NewFeatureToggleMemStore(), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown store '%s', must be 'postgres' or 'memory'", kind))
+}
+
+// nameMatches tells if name contains search, ignoring case.
+func nameMatches(name string, search string) bool {
+	return strings.Contains(strings.ToLower(name), strings.ToLower(search))
+}
+
+func validateProperties(toggleRule ToggleRule) error {
+	if len(toggleRule.Properties) == 0 {
+		return &InvalidArgumentError{Field:"properties", Description:"a toggle rule must have at least one property"}
+	}
+	return nil
+}
+
+// validateRollout checks the rollout with the rules of the toggle rule tree.
+func validateRollout(toggleRule ToggleRule) error {
+	err := featuretree.ValidateRolloutPercentage(toggleRule.RolloutPercentage)
+	if err != nil {
+		return invalidArgument("rolloutPercentage", err)
+	}
+	return invalidArgument("rolloutProperty", featuretree.ValidateRolloutProperty(toggleRule.RolloutPercentage, toggleRule.RolloutProperty))
+}
+
+func validateSchedule(starts time.Time, expires time.Time) error {
+	if !starts.IsZero() && !expires.IsZero() && !expires.After(starts) {
+		return &InvalidArgumentError{Field:"expires", Description:"must be after starts"}
+	}
+	return nil
+}
+
+func validateVariants(feature Feature) error {
+	return invalidArgument("variants", featuretree.ValidateFeatureVariants(featuretree.FeatureVariants{Feature:feature.Name, Variants:feature.Variants, Default:feature.DefaultVariant}))
+}
+
+// validateRuleVariants checks that the rule only chooses variants of its feature.
+func validateRuleVariants(toggleRule ToggleRule, feature Feature) error {
+	err := featuretree.ValidateSplit(toggleRule.Variants, toggleRule.RolloutProperty)
+	if err != nil {
+		return invalidArgument("variants", err)
+	}
+	if variant, ok := missingVariant(toggleRule, feature); ok {
+		return &InvalidArgumentError{Field:"variants", Description:fmt.Sprintf("unknown variant '%s' of feature '%s'", variant, feature.Name)}
+	}
+	return nil
+}
+
+// variantsKept returns a ReferencedError if the rule chooses a variant the
+// feature no longer has.
+func variantsKept(feature Feature, toggleRule ToggleRule) error {
+	if variant, ok := missingVariant(toggleRule, feature); ok {
+		return &ReferencedError{Kind:KindVariant, Key:variant, ByKind:KindToggleRule, ByKey:toggleRule.Id}
+	}
+	return nil
+}
+
+// missingVariant returns the first variant the rule chooses that the feature
+// doesn't have.
+func missingVariant(toggleRule ToggleRule, feature Feature) (string, bool) {
+	for _, rv := range toggleRule.Variants {
+		found := false
+		for _, v := range feature.Variants {
+			if v.Name == rv.Variant {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return rv.Variant, true
+		}
+	}
+	return "", false
+}
+
+func copyVariants(variants []featuretree.Variant) []featuretree.Variant {
+	if variants == nil {
+		return nil
+	}
+	res := make([]featuretree.Variant, len(variants))
+	copy(res, variants)
+	return res
+}
+
+func copyWeightedVariants(variants []featuretree.WeightedVariant) []featuretree.WeightedVariant {
+	if variants == nil {
+		return nil
+	}
+	res := make([]featuretree.WeightedVariant, len(variants))
+	copy(res, variants)
+	return res
+}
+
+func validateOperators(toggleRule ToggleRule) error {
+	for property, operator := range toggleRule.Operators {
+		value, ok := toggleRule.Properties[property]
+		if !ok {
+			return &InvalidArgumentError{Field:"operators", Description:fmt.Sprintf("operator '%s' on property '%s' without value", operator, property)}
+		}
+		err := featuretree.ValidateCondition(operator, value)
+		if err != nil {
+			return invalidArgument("operators", err)
+		}
+	}
+	return nil
+}
+
+// operator returns the operator stored for the property, featuretree.OperatorEquals
+// if the rule has none.
+func (toggleRule ToggleRule) operator(property string) string {
+	if operator, ok := toggleRule.Operators[property]; ok && operator != "" {
+		return operator
+	}
+	return featuretree.OperatorEquals
+}
+
+// copyOperators leaves out the equals operators, a property without an
+// operator is matched for equality. Like the sql store it returns nil when
+// no operator is left.
+func copyOperators(operators map[string]string) map[string]string {
+	var res map[string]string
+	for k, v := range operators {
+		if v == "" || v == featuretree.OperatorEquals {
+			continue
+		}
+		if res == nil {
+			res = make(map[string]string, len(operators))
+		}
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+func (fs *FeatureToggleMemStore) CreateApiKey(apiKey ApiKey) (*string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	id := apiKey.Id
+	if strings.Compare(id, "") == 0 {
+		id = uuid.NewV4().String()
+	}
+	if _, ok := fs.apiKeys[id]; ok {
+		return nil, &AlreadyExistsError{Kind:KindApiKey, Key:id}
+	}
+	for _, key := range fs.apiKeys {
+		if strings.Compare(key.Hash, apiKey.Hash) == 0 || strings.Compare(key.Name, apiKey.Name) == 0 {
+			return nil, &AlreadyExistsError{Kind:KindApiKey, Key:apiKey.Name}
+		}
+	}
+
+	key := apiKey
+	key.Id = id
+	key.Created = time.Now()
+	err := fs.record(KindApiKey, id, OperationCreate, nil, key)
+	if err != nil {
+		return nil, err
+	}
+	fs.apiKeys[id] = key
+
+	return &id, nil
+}
+
+func (fs *FeatureToggleMemStore) ReadApiKeyByHash(hash string) (*ApiKey, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	for _, key := range fs.apiKeys {
+		if strings.Compare(key.Hash, hash) == 0 {
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (fs *FeatureToggleMemStore) DeleteApiKey(id string) (*bool, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	key, b := fs.apiKeys[id]
+	if b {
+		err := fs.record(KindApiKey, id, OperationDelete, key, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	delete(fs.apiKeys, id)
+	return &b, nil
+}
+
+func (fs *FeatureToggleMemStore) ListApiKeys() (*[]ApiKey, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	keys := []ApiKey{}
+	for _, key := range fs.apiKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Name == keys[j].Name {
+			return keys[i].Id < keys[j].Id
+		}
+		return keys[i].Name < keys[j].Name
+	})
+	return &keys, nil
+}
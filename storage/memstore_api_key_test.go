@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureToggleMemStore_CreateApiKey(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	key := ApiKey{Name:randomSufix("Key-"), Hash:randomSufix("hash-"), Scope:"evaluate"}
+	id, err := fs.CreateApiKey(key)
+	require.NotNil(t, id, "Should get api key id, %v", err)
+
+	k, err := fs.ReadApiKeyByHash(key.Hash)
+	require.NotNil(t, k, "Should get api key by hash, %v", err)
+	assert.Equal(t, *id, k.Id)
+	assert.Equal(t, key.Name, k.Name)
+	assert.Equal(t, "evaluate", k.Scope)
+	assert.False(t, k.Created.IsZero(), "Should set created")
+
+	id, err = fs.CreateApiKey(ApiKey{Name:randomSufix("Key-"), Hash:key.Hash, Scope:"admin"})
+	assert.Nil(t, id, "Should not create two keys with the same hash")
+	assert.IsType(t, &AlreadyExistsError{}, err)
+
+	id, err = fs.CreateApiKey(ApiKey{Name:key.Name, Hash:randomSufix("hash-"), Scope:"admin"})
+	assert.Nil(t, id, "Should not create two keys with the same name")
+	assert.IsType(t, &AlreadyExistsError{}, err)
+
+	k, err = fs.ReadApiKeyByHash("unknown")
+	assert.Nil(t, k, "Should not get an unknown key, %v", err)
+}
+
+func TestFeatureToggleMemStore_DeleteApiKey(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	id, err := fs.CreateApiKey(ApiKey{Name:"b", Hash:randomSufix("hash-"), Scope:"admin"})
+	require.NotNil(t, id, "Should get api key id, %v", err)
+	fs.CreateApiKey(ApiKey{Name:"a", Hash:randomSufix("hash-"), Scope:"evaluate"})
+
+	keys, err := fs.ListApiKeys()
+	require.NotNil(t, keys, "Should list api keys, %v", err)
+	require.Len(t, *keys, 2)
+	assert.Equal(t, "a", (*keys)[0].Name, "Should be ordered by name")
+
+	b, err := fs.DeleteApiKey(*id)
+	require.NotNil(t, b, "Should delete api key, %v", err)
+	assert.True(t, *b)
+	b, _ = fs.DeleteApiKey(*id)
+	assert.False(t, *b, "Should not delete the key twice")
+
+	keys, _ = fs.ListApiKeys()
+	assert.Len(t, *keys, 1)
+}
@@ -0,0 +1,30 @@
+package storage
+
+// record appends the entry of a change to the audit log, it must be called
+// with the mutex held and before the change is made, so a failure leaves the
+// store unchanged.
+func (fs *FeatureToggleMemStore) record(kind string, entityId string, operation string, before interface{}, after interface{}) error {
+	entry, err := newAuditEntry(fs.actor, kind, entityId, operation, before, after)
+	if err != nil {
+		return err
+	}
+	fs.auditLog = append(fs.auditLog, *entry)
+	return nil
+}
+
+func (fs *FeatureToggleMemStore) SearchAuditLog(search AuditSearch) (*[]AuditEntry, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	// the log is in the order of the changes, newest last
+	entries := []AuditEntry{}
+	for i := len(fs.auditLog) - 1; i >= 0; i-- {
+		if search.Limit > 0 && len(entries) >= search.Limit {
+			break
+		}
+		if search.matches(fs.auditLog[i]) {
+			entries = append(entries, fs.auditLog[i])
+		}
+	}
+	return &entries, nil
+}
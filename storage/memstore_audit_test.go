@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureToggleMemStore_SearchAuditLog(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+	adam := fs.WithActor("jwt:adam")
+
+	start := time.Now()
+	featureId, _ := adam.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	adam.UpdateFeature(Feature{Id:*featureId, Description:"new description", Version:1}, []string{"description"})
+	fs.CreateProperty(*NewProperty("username", "user name"))
+	_, err := adam.CreateFeature(Feature{Id:*featureId})
+	require.NotNil(t, err, "Should fail to create a feature twice")
+
+	entries, err := fs.SearchAuditLog(AuditSearch{EntityId:*featureId})
+	require.NotNil(t, entries, "Should search audit log, %v", err)
+	require.Len(t, *entries, 2, "Should not record failed changes")
+	update := (*entries)[0]
+	assert.Equal(t, OperationUpdate, update.Operation, "Should return the newest entry first")
+	assert.Equal(t, "jwt:adam", update.Actor)
+	assert.Equal(t, KindFeature, update.Kind)
+	assert.False(t, update.Time.Before(start))
+	var before, after Feature
+	require.Nil(t, json.Unmarshal([]byte(update.Before), &before))
+	require.Nil(t, json.Unmarshal([]byte(update.After), &after))
+	assert.Equal(t, "f description", before.Description)
+	assert.Equal(t, "new description", after.Description)
+	assert.Equal(t, OperationCreate, (*entries)[1].Operation)
+	assert.Equal(t, "", (*entries)[1].Before, "A create has no before")
+
+	entries, _ = fs.SearchAuditLog(AuditSearch{Actor:ANONYMOUS_ACTOR})
+	require.Len(t, *entries, 1)
+	assert.Equal(t, KindProperty, (*entries)[0].Kind)
+
+	entries, _ = fs.SearchAuditLog(AuditSearch{Kind:KindFeature, Limit:1})
+	assert.Len(t, *entries, 1, "Should limit the entries")
+	entries, _ = fs.SearchAuditLog(AuditSearch{End:start.Add(-time.Second)})
+	assert.Len(t, *entries, 0, "Should filter by time")
+
+	adam.DeleteFeature(*featureId, 2)
+	entries, _ = fs.SearchAuditLog(AuditSearch{Kind:KindFeature, EntityId:*featureId, Limit:1})
+	require.Len(t, *entries, 1)
+	assert.Equal(t, OperationDelete, (*entries)[0].Operation)
+	assert.NotEqual(t, "", (*entries)[0].Before)
+	assert.Equal(t, "", (*entries)[0].After, "A delete has no after")
+}
+
+func TestFeatureToggleMemStore_AuditLog_records_policy_changes(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	fs.SetRoleBinding(RoleBinding{Principal:"jwt:adam", Role:"viewer"})
+	fs.SetRoleBinding(RoleBinding{Principal:"jwt:adam", Role:"editor"})
+	featureId, _ := fs.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	fs.AddFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:adam"})
+	fs.AddFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:adam"})
+	keyId, _ := fs.CreateApiKey(ApiKey{Name:"checkout", Hash:"secret", Scope:"evaluate"})
+
+	entries, _ := fs.SearchAuditLog(AuditSearch{Kind:KindRoleBinding})
+	require.Len(t, *entries, 2)
+	assert.Equal(t, OperationUpdate, (*entries)[0].Operation, "Should record replacing a role as an update")
+	entries, _ = fs.SearchAuditLog(AuditSearch{Kind:KindFeatureOwner, EntityId:*featureId})
+	assert.Len(t, *entries, 1, "Should not record adding an owner twice")
+	entries, _ = fs.SearchAuditLog(AuditSearch{Kind:KindApiKey, EntityId:*keyId})
+	require.Len(t, *entries, 1)
+	assert.NotContains(t, (*entries)[0].After, "secret", "Should not record the key hash")
+}
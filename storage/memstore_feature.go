@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+)
+
+func (fs *FeatureToggleMemStore) CreateFeature(feature Feature) (*string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, ok := fs.features[feature.Id]; ok {
+		return nil, &AlreadyExistsError{Kind:KindFeature, Key:feature.Id}
+	}
+	if fs.findFeatureByName(feature.Name) != nil {
+		return nil, &AlreadyExistsError{Kind:KindFeature, Key:feature.Name}
+	}
+	err := validateSchedule(feature.Starts, feature.Expires)
+	if err != nil {
+		return nil, err
+	}
+	err = validateVariants(feature)
+	if err != nil {
+		return nil, err
+	}
+	feature.Variants = copyVariants(feature.Variants)
+	feature.Version = 1
+	err = fs.record(KindFeature, feature.Id, OperationCreate, nil, feature)
+	if err != nil {
+		return nil, err
+	}
+	fs.features[feature.Id] = feature
+	fs.addRevision(feature.Id)
+
+	return &feature.Id, nil
+}
+
+func (fs *FeatureToggleMemStore) ReadFeature(id string) (*Feature, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	if feature, ok := fs.features[id]; ok {
+		feature.Variants = copyVariants(feature.Variants)
+		return &feature, nil
+	}
+	return nil, nil
+}
+
+func (fs *FeatureToggleMemStore) ReadFeatureByName(name string) (*Feature, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	return fs.findFeatureByName(name), nil
+}
+
+func (fs *FeatureToggleMemStore) DeleteFeature(id string, version int64) (*bool, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	b := false
+	feature, ok := fs.features[id]
+	if !ok {
+		return &b, nil
+	}
+	err := checkVersion(KindFeature, id, version, feature.Version)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range fs.toggleRules {
+		if strings.Compare(rule.FeatureId, id) == 0 {
+			return nil, &ReferencedError{Kind:KindFeature, Key:id, ByKind:KindToggleRule, ByKey:rule.Id}
+		}
+	}
+	err = fs.record(KindFeature, id, OperationDelete, feature, nil)
+	if err != nil {
+		return nil, err
+	}
+	delete(fs.features, id)
+	delete(fs.owners, id)
+	delete(fs.revisions, id)
+	b = true
+	return &b, nil
+}
+
+func (fs *FeatureToggleMemStore) SearchFeature(search FeatureSearch) (*[]Feature, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	features := []Feature{}
+	for _, feature := range fs.features {
+		if !nameMatches(feature.Name, search.Name) {
+			continue
+		}
+		if search.Enabled != nil && feature.Enabled != *search.Enabled {
+			continue
+		}
+		feature.Variants = copyVariants(feature.Variants)
+		features = append(features, feature)
+	}
+	sort.Slice(features, func(i, j int) bool {
+		return features[i].Name < features[j].Name
+	})
+	return &features, nil
+}
+
+// findFeatureByName must be called with the mutex held.
+func (fs *FeatureToggleMemStore) findFeatureByName(name string) *Feature {
+	for _, feature := range fs.features {
+		if strings.Compare(feature.Name, name) == 0 {
+			feature.Variants = copyVariants(feature.Variants)
+			return &feature
+		}
+	}
+	return nil
+}
+
+func (fs *FeatureToggleMemStore) UpdateFeature(update Feature, paths []string) (*Feature, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	feature, ok := fs.features[update.Id]
+	if !ok {
+		return nil, nil
+	}
+	err := checkVersion(KindFeature, feature.Id, update.Version, feature.Version)
+	if err != nil {
+		return nil, err
+	}
+	before := feature
+	err = applyFeatureUpdate(&feature, update, paths)
+	if err != nil {
+		return nil, err
+	}
+	feature.Version++
+	if other := fs.findFeatureByName(feature.Name); other != nil && other.Id != feature.Id {
+		return nil, &AlreadyExistsError{Kind:KindFeature, Key:feature.Name}
+	}
+	for _, rule := range fs.toggleRules {
+		if rule.FeatureId == feature.Id {
+			err = variantsKept(feature, rule)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	err = fs.record(KindFeature, feature.Id, OperationUpdate, before, feature)
+	if err != nil {
+		return nil, err
+	}
+	fs.features[feature.Id] = feature
+	fs.addRevision(feature.Id)
+
+	feature.Variants = copyVariants(feature.Variants)
+	return &feature, nil
+}
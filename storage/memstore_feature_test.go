@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+)
+
+func TestFeatureToggleMemStore_CreateFeature(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	feature := NewFeature(randomSufix("Feature-"), true, "f description")
+	featureId, err := fs.CreateFeature(*feature)
+
+	require.NotNil(t, featureId, "Should get featureId, %v", err)
+	assert.Equal(t, feature.Id, *featureId, "Should get the id of the feature")
+}
+
+func TestFeatureToggleMemStore_CreateFeature__duplicate_name(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	featureName := randomSufix("Feature-")
+	featureId, err := fs.CreateFeature(*NewFeature(featureName, true, "f description"))
+	require.NotNil(t, featureId, "Should get featureId, %v", err)
+
+	featureId, err = fs.CreateFeature(*NewFeature(featureName, true, "f description"))
+	assert.Nil(t, featureId, "Should not get a featureId for a duplicate name")
+	assert.NotNil(t, err, "Should get an error for a duplicate name")
+}
+
+func TestFeatureToggleMemStore_ReadFeature(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	feature := NewFeature(randomSufix("Feature-"), true, "f description")
+	featureId, err := fs.CreateFeature(*feature)
+	require.NotNil(t, featureId, "Should get featureId, %v", err)
+
+	f, err := fs.ReadFeature(*featureId)
+	require.NotNil(t, f, "Should get feature from featureId %s, %v", *featureId, err)
+	assert.Equal(t, feature.Name, f.Name, "Should get feature name")
+	assert.Equal(t, feature.Description, f.Description, "Should get feature description")
+	assert.Equal(t, feature.Enabled, f.Enabled, "Should get feature enabled")
+
+	f, err = fs.ReadFeatureByName(feature.Name)
+	require.NotNil(t, f, "Should get feature from name %s, %v", feature.Name, err)
+	assert.Equal(t, feature.Id, f.Id, "Should get feature id")
+}
+
+func TestFeatureToggleMemStore_ReadFeatureByName__unknown(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	f, err := fs.ReadFeatureByName("unknown feature")
+	assert.Nil(t, f, "Should not get a feature, %v", f)
+	assert.Nil(t, err, "Should not get an error, %v", err)
+}
+
+func TestFeatureToggleMemStore_DeleteFeature(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	feature := NewFeature(randomSufix("Feature-"), true, "f description")
+	featureId, err := fs.CreateFeature(*feature)
+	require.NotNil(t, featureId, "Should get featureId, %v", err)
+
+	res, err := fs.DeleteFeature(*featureId, 1)
+	require.True(t, *res, "Should get true from delete operation for featureId %s, %v", *featureId, err)
+
+	res, err = fs.DeleteFeature(*featureId, 1)
+	require.False(t, *res, "Should get false from second delete operation for featureId %s, %v", *featureId, err)
+}
+
+func TestFeatureToggleMemStore_DeleteFeature__referenced(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	featureId, err := fs.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	require.NotNil(t, featureId, "Should get featureId, %v", err)
+	p, err := fs.CreateProperty(*NewProperty("username", "p description"))
+	require.NotNil(t, p, "Should get propertyName, %v", err)
+	ruleId, err := fs.CreateToggleRule(*NewToggleRule(*featureId, true, "username", "adam"))
+	require.NotNil(t, ruleId, "Should get ruleId, %v", err)
+
+	res, err := fs.DeleteFeature(*featureId, 1)
+	assert.Nil(t, res, "Should not delete a feature referenced by a toggle rule")
+	assert.NotNil(t, err, "Should get an error when the feature is referenced by a toggle rule")
+}
+
+func TestFeatureToggleMemStore_CreateFeature__variants(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	feature := NewFeature(randomSufix("Feature-"), true, "f description")
+	feature.Variants = []featuretree.Variant{{Name: "a", Type: featuretree.VariantTypeString, Value: "A"}, {Name: "b", Type: featuretree.VariantTypeString, Value: "B"}}
+	feature.DefaultVariant = "b"
+	featureId, err := fs.CreateFeature(*feature)
+	require.NotNil(t, featureId, "Should get featureId, %v", err)
+
+	f, err := fs.ReadFeature(*featureId)
+	require.NotNil(t, f, "Should get feature, %v", err)
+	assert.Equal(t, feature.Variants, f.Variants, "Should get the variants in order")
+	assert.Equal(t, "b", f.DefaultVariant)
+
+	variants, err := fs.GetFeatureVariants()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(*variants))
+	assert.Equal(t, feature.Name, (*variants)[0].Feature)
+
+	invalid := NewFeature(randomSufix("Feature-"), true, "f description")
+	invalid.Variants = []featuretree.Variant{{Name: "limit", Type: featuretree.VariantTypeInt, Value: "lots"}}
+	featureId, err = fs.CreateFeature(*invalid)
+	assert.Nil(t, featureId, "Should not accept a value that isn't an int")
+	assert.NotNil(t, err)
+}
+
+func TestFeatureToggleMemStore_SearchFeature(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	fs.CreateFeature(*NewFeature("Checkout-v2", true, ""))
+	fs.CreateFeature(*NewFeature("new-checkout", false, ""))
+	fs.CreateFeature(*NewFeature("dark-mode", true, ""))
+
+	features, err := fs.SearchFeature(FeatureSearch{Name: "CHECKOUT"})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.Equal(t, 2, len(*features), "Should match part of the name ignoring case")
+	assert.Equal(t, "Checkout-v2", (*features)[0].Name, "Should be ordered by name")
+	assert.Equal(t, "new-checkout", (*features)[1].Name, "Should be ordered by name")
+
+	enabled := true
+	features, err = fs.SearchFeature(FeatureSearch{Enabled: &enabled})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.Equal(t, 2, len(*features))
+	assert.Equal(t, "Checkout-v2", (*features)[0].Name)
+	assert.Equal(t, "dark-mode", (*features)[1].Name)
+}
+
+func TestFeatureToggleMemStore_UpdateFeature(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	feature := NewFeature(randomSufix("Feature-"), true, "f description")
+	feature.Variants = []featuretree.Variant{{Name: "a", Type: featuretree.VariantTypeString, Value: "A"}}
+	fs.CreateFeature(*feature)
+	other := NewFeature(randomSufix("Feature-"), true, "")
+	fs.CreateFeature(*other)
+	fs.CreateProperty(*NewProperty("username", ""))
+	rule := NewToggleRule(feature.Id, true, "username", "adam")
+	rule.Variants = []featuretree.WeightedVariant{{Variant: "a"}}
+	fs.CreateToggleRule(*rule)
+
+	f, err := fs.UpdateFeature(Feature{Id: feature.Id, Name: "renamed", Description: "new", Version: 1}, []string{"name", "description"})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.NotNil(t, f)
+	assert.Equal(t, "renamed", f.Name)
+	assert.Equal(t, "new", f.Description)
+	assert.True(t, f.Enabled, "Should not change fields outside the paths")
+	assert.Equal(t, feature.Variants, f.Variants)
+
+	f, err = fs.UpdateFeature(Feature{Id: feature.Id, Name: other.Name, Version: 2}, []string{"name"})
+	assert.Nil(t, f, "Should not take the name of another feature")
+	assert.NotNil(t, err)
+
+	f, err = fs.UpdateFeature(Feature{Id: feature.Id, Version: 2}, []string{"variants"})
+	assert.Nil(t, f, "Should not remove a variant chosen by a toggle rule")
+	assert.NotNil(t, err)
+
+	f, err = fs.UpdateFeature(Feature{Id: "unknown"}, nil)
+	assert.Nil(t, f, "Should not find an unknown feature")
+	assert.Nil(t, err, "Should not get an error for an unknown feature, %v", err)
+}
+
+func TestFeatureToggleMemStore_UpdateFeature__version(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	featureId, _ := fs.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	feature, _ := fs.ReadFeature(*featureId)
+	assert.Equal(t, int64(1), feature.Version, "Should create version 1")
+
+	f, err := fs.UpdateFeature(Feature{Id: *featureId, Description: "new", Version: 1}, []string{"description"})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	assert.Equal(t, int64(2), f.Version, "Should increase the version")
+
+	f, err = fs.UpdateFeature(Feature{Id: *featureId, Description: "stale", Version: 1}, []string{"description"})
+	assert.Nil(t, f, "Should not update a stale version")
+	assert.Equal(t, &VersionMismatchError{Kind: KindFeature, Key: *featureId, Version: 1, Current: 2}, err)
+
+	res, err := fs.DeleteFeature(*featureId, 1)
+	assert.Nil(t, res, "Should not delete a stale version")
+	assert.IsType(t, &VersionMismatchError{}, err)
+	feature, _ = fs.ReadFeature(*featureId)
+	assert.Equal(t, "new", feature.Description)
+}
+
+func TestFeatureToggleMemStore_typed_errors(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	feature := NewFeature(randomSufix("Feature-"), true, "")
+	fs.CreateFeature(*feature)
+	_, err := fs.CreateFeature(*NewFeature(feature.Name, true, ""))
+	assert.IsType(t, &AlreadyExistsError{}, err)
+
+	_, err = fs.CreateToggleRule(*NewToggleRule("unknown", true))
+	assert.Equal(t, &NotFoundError{Kind: KindFeature, Key: "unknown"}, err)
+
+	_, err = fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "unknown", "value"))
+	assert.Equal(t, &NotFoundError{Kind: KindProperty, Key: "unknown"}, err)
+
+	fs.CreateProperty(*NewProperty("username", ""))
+	rule := NewToggleRule(feature.Id, true, "username", "adam")
+	rule.RolloutPercentage = 50
+	_, err = fs.CreateToggleRule(*rule)
+	require.IsType(t, &InvalidArgumentError{}, err)
+	assert.Equal(t, "rolloutProperty", err.(*InvalidArgumentError).Field)
+
+	ruleId, _ := fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "username", "adam"))
+	_, err = fs.DeleteFeature(feature.Id, 1)
+	assert.Equal(t, &ReferencedError{Kind: KindFeature, Key: feature.Id, ByKind: KindToggleRule, ByKey: *ruleId}, err)
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"sort"
+)
+
+func (fs *FeatureToggleMemStore) SetRoleBinding(binding RoleBinding) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	operation := OperationCreate
+	var before interface{}
+	if current, ok := fs.roles[binding.Principal]; ok {
+		operation = OperationUpdate
+		before = current
+	}
+	err := fs.record(KindRoleBinding, binding.Principal, operation, before, binding)
+	if err != nil {
+		return err
+	}
+	fs.roles[binding.Principal] = binding
+	return nil
+}
+
+func (fs *FeatureToggleMemStore) ReadRoleBinding(principal string) (*RoleBinding, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	if binding, ok := fs.roles[principal]; ok {
+		return &binding, nil
+	}
+	return nil, nil
+}
+
+func (fs *FeatureToggleMemStore) DeleteRoleBinding(principal string) (*bool, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	binding, b := fs.roles[principal]
+	if b {
+		err := fs.record(KindRoleBinding, principal, OperationDelete, binding, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	delete(fs.roles, principal)
+	return &b, nil
+}
+
+func (fs *FeatureToggleMemStore) ListRoleBindings() (*[]RoleBinding, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	bindings := []RoleBinding{}
+	for _, binding := range fs.roles {
+		bindings = append(bindings, binding)
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Principal < bindings[j].Principal
+	})
+	return &bindings, nil
+}
+
+func (fs *FeatureToggleMemStore) AddFeatureOwner(owner FeatureOwner) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, ok := fs.features[owner.FeatureId]; !ok {
+		return &NotFoundError{Kind:KindFeature, Key:owner.FeatureId}
+	}
+	if fs.owners[owner.FeatureId][owner.Principal] {
+		return nil
+	}
+	err := fs.record(KindFeatureOwner, owner.FeatureId, OperationCreate, nil, owner)
+	if err != nil {
+		return err
+	}
+	if _, ok := fs.owners[owner.FeatureId]; !ok {
+		fs.owners[owner.FeatureId] = make(map[string]bool)
+	}
+	fs.owners[owner.FeatureId][owner.Principal] = true
+	return nil
+}
+
+func (fs *FeatureToggleMemStore) RemoveFeatureOwner(owner FeatureOwner) (*bool, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	b := fs.owners[owner.FeatureId][owner.Principal]
+	if b {
+		err := fs.record(KindFeatureOwner, owner.FeatureId, OperationDelete, owner, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	delete(fs.owners[owner.FeatureId], owner.Principal)
+	return &b, nil
+}
+
+func (fs *FeatureToggleMemStore) ReadFeatureOwners(featureId string) (*[]string, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	owners := []string{}
+	for principal := range fs.owners[featureId] {
+		owners = append(owners, principal)
+	}
+	sort.Strings(owners)
+	return &owners, nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureToggleMemStore_SetRoleBinding(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	require.Nil(t, fs.SetRoleBinding(RoleBinding{Principal:"jwt:eve", Role:"viewer"}))
+	require.Nil(t, fs.SetRoleBinding(RoleBinding{Principal:"jwt:adam", Role:"viewer"}))
+	require.Nil(t, fs.SetRoleBinding(RoleBinding{Principal:"jwt:adam", Role:"editor"}))
+
+	binding, err := fs.ReadRoleBinding("jwt:adam")
+	require.NotNil(t, binding, "Should get role binding, %v", err)
+	assert.Equal(t, "editor", binding.Role, "Should replace the role")
+
+	bindings, _ := fs.ListRoleBindings()
+	assert.Equal(t, []RoleBinding{{Principal:"jwt:adam", Role:"editor"}, {Principal:"jwt:eve", Role:"viewer"}}, *bindings)
+
+	b, _ := fs.DeleteRoleBinding("jwt:adam")
+	assert.True(t, *b)
+	binding, _ = fs.ReadRoleBinding("jwt:adam")
+	assert.Nil(t, binding, "Should not read a deleted binding")
+}
+
+func TestFeatureToggleMemStore_FeatureOwners(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	featureId, _ := fs.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	require.Nil(t, fs.AddFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:eve"}))
+	require.Nil(t, fs.AddFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:adam"}))
+	require.Nil(t, fs.AddFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:adam"}), "Adding an owner twice should be a no-op")
+
+	owners, err := fs.ReadFeatureOwners(*featureId)
+	require.NotNil(t, owners, "Should get owners, %v", err)
+	assert.Equal(t, []string{"jwt:adam", "jwt:eve"}, *owners)
+
+	b, _ := fs.RemoveFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:eve"})
+	assert.True(t, *b)
+	owners, _ = fs.ReadFeatureOwners(*featureId)
+	assert.Equal(t, []string{"jwt:adam"}, *owners)
+
+	err = fs.AddFeatureOwner(FeatureOwner{FeatureId:"unknown", Principal:"jwt:adam"})
+	assert.IsType(t, &NotFoundError{}, err, "Should refuse owners of unknown features")
+
+	fs.DeleteFeature(*featureId, 1)
+	owners, _ = fs.ReadFeatureOwners(*featureId)
+	assert.Len(t, *owners, 0, "Should remove the owners with the feature")
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"sort"
+)
+
+func (fs *FeatureToggleMemStore) CreateProperty(property Property) (*string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, ok := fs.properties[property.Name]; ok {
+		return nil, &AlreadyExistsError{Kind:KindProperty, Key:property.Name}
+	}
+	property.Version = 1
+	err := fs.record(KindProperty, property.Name, OperationCreate, nil, property)
+	if err != nil {
+		return nil, err
+	}
+	fs.properties[property.Name] = property
+
+	return &property.Name, nil
+}
+
+func (fs *FeatureToggleMemStore) ReadProperty(name string) (*Property, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	if property, ok := fs.properties[name]; ok {
+		return &property, nil
+	}
+	return nil, nil
+}
+
+func (fs *FeatureToggleMemStore) ReadAllPropertyNames() (*[]string, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	names := []string{}
+	for name := range fs.properties {
+		names = append(names, name)
+	}
+	return &names, nil
+}
+
+func (fs *FeatureToggleMemStore) DeleteProperty(name string, version int64) (*bool, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	b := false
+	property, ok := fs.properties[name]
+	if !ok {
+		return &b, nil
+	}
+	err := checkVersion(KindProperty, name, version, property.Version)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range fs.toggleRules {
+		if _, ok := rule.Properties[name]; ok {
+			return nil, &ReferencedError{Kind:KindProperty, Key:name, ByKind:KindToggleRule, ByKey:rule.Id}
+		}
+	}
+	err = fs.record(KindProperty, name, OperationDelete, property, nil)
+	if err != nil {
+		return nil, err
+	}
+	delete(fs.properties, name)
+	b = true
+	return &b, nil
+}
+
+func (fs *FeatureToggleMemStore) SearchProperty(name string) (*[]Property, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	properties := []Property{}
+	for _, property := range fs.properties {
+		if nameMatches(property.Name, name) {
+			properties = append(properties, property)
+		}
+	}
+	sort.Slice(properties, func(i, j int) bool {
+		return properties[i].Name < properties[j].Name
+	})
+	return &properties, nil
+}
+
+func (fs *FeatureToggleMemStore) UpdateProperty(update Property, paths []string) (*Property, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	property, ok := fs.properties[update.Name]
+	if !ok {
+		return nil, nil
+	}
+	err := checkVersion(KindProperty, property.Name, update.Version, property.Version)
+	if err != nil {
+		return nil, err
+	}
+	before := property
+	err = applyPropertyUpdate(&property, update, paths)
+	if err != nil {
+		return nil, err
+	}
+	property.Version++
+	err = fs.record(KindProperty, property.Name, OperationUpdate, before, property)
+	if err != nil {
+		return nil, err
+	}
+	fs.properties[property.Name] = property
+	return &property, nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureToggleMemStore_CreateProperty(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	property := NewProperty(randomSufix("Prop-"), "p description")
+	propertyName, err := fs.CreateProperty(*property)
+	require.NotNil(t, propertyName, "Should get property name, %v", err)
+
+	propertyName, err = fs.CreateProperty(*property)
+	assert.Nil(t, propertyName, "Should not create the same property twice")
+	assert.NotNil(t, err, "Should get an error for a duplicate property")
+}
+
+func TestFeatureToggleMemStore_ReadProperty(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	property := NewProperty(randomSufix("Prop-"), "p description")
+	propertyName, err := fs.CreateProperty(*property)
+	require.NotNil(t, propertyName, "Should get property name, %v", err)
+
+	p, err := fs.ReadProperty(*propertyName)
+	require.NotNil(t, p, "Should get property from property name '%s', %v", *propertyName, err)
+	assert.Equal(t, property.Name, p.Name, "Should get property name")
+	assert.Equal(t, property.Description, p.Description, "Should get property description")
+}
+
+func TestFeatureToggleMemStore_ReadAllPropertyNames(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	propName1 := randomSufix("Prop-")
+	propName2 := randomSufix("Prop-")
+	fs.CreateProperty(*NewProperty(propName1, "p description"))
+	fs.CreateProperty(*NewProperty(propName2, "p description"))
+
+	p, err := fs.ReadAllPropertyNames()
+	require.NotNil(t, p, "Should get property names, %v", err)
+	assert.True(t, contains(p, propName1), "Should find property name %s", propName1)
+	assert.True(t, contains(p, propName2), "Should find property name %s", propName2)
+}
+
+func TestFeatureToggleMemStore_DeleteProperty(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	propertyName, err := fs.CreateProperty(*NewProperty(randomSufix("Name-"), "p description"))
+	require.NotNil(t, propertyName, "Should get property name, %v", err)
+
+	res, err := fs.DeleteProperty(*propertyName, 1)
+	require.True(t, *res, "Should get true from delete operation for property '%s', %v", *propertyName, err)
+}
+
+func TestFeatureToggleMemStore_DeleteProperty__referenced(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	featureId, err := fs.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	require.NotNil(t, featureId, "Should get featureId, %v", err)
+	propertyName, err := fs.CreateProperty(*NewProperty(randomSufix("Name-"), "p description"))
+	require.NotNil(t, propertyName, "Should get property name, %v", err)
+	ruleId, err := fs.CreateToggleRule(*NewToggleRule(*featureId, true, *propertyName, "val"))
+	require.NotNil(t, ruleId, "Should get ruleId, %v", err)
+
+	res, err := fs.DeleteProperty(*propertyName, 1)
+	assert.Nil(t, res, "Should not delete a property referenced by a toggle rule")
+	assert.NotNil(t, err, "Should get an error when the property is referenced by a toggle rule")
+}
+
+func TestFeatureToggleMemStore_SearchProperty(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	fs.CreateProperty(*NewProperty("UserType", ""))
+	fs.CreateProperty(*NewProperty("username", ""))
+	fs.CreateProperty(*NewProperty("country", ""))
+
+	properties, err := fs.SearchProperty("user")
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.Equal(t, 2, len(*properties), "Should match part of the name ignoring case")
+	assert.Equal(t, "UserType", (*properties)[0].Name, "Should be ordered by name")
+	assert.Equal(t, "username", (*properties)[1].Name, "Should be ordered by name")
+
+	properties, err = fs.SearchProperty("")
+	assert.Equal(t, 3, len(*properties), "Should get all properties")
+}
+
+func TestFeatureToggleMemStore_UpdateProperty(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+	fs.CreateProperty(*NewProperty("username", "old"))
+
+	p, err := fs.UpdateProperty(Property{Name:"username", Description:"new", Version:1}, []string{"description"})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	assert.Equal(t, "new", p.Description)
+
+	p, err = fs.UpdateProperty(Property{Name:"username", Description:"new", Version:2}, []string{"name"})
+	assert.Nil(t, p, "Should not rename a property")
+	assert.NotNil(t, err)
+
+	p, err = fs.UpdateProperty(Property{Name:"username", Description:"stale", Version:1}, []string{"description"})
+	assert.Nil(t, p, "Should not update a stale version")
+	assert.IsType(t, &VersionMismatchError{}, err)
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// addRevision records the current state of the feature and its toggle rules
+// as its next revision, it must be called with the mutex held and after the
+// change.
+func (fs *FeatureToggleMemStore) addRevision(featureId string) {
+	feature := fs.features[featureId]
+	feature.Variants = copyVariants(feature.Variants)
+	revision := Revision{FeatureId:featureId, Revision:len(fs.revisions[featureId]) + 1, Created:time.Now(),
+		Actor:fs.actor, Feature:feature, ToggleRules:fs.featureToggleRules(featureId)}
+	fs.revisions[featureId] = append(fs.revisions[featureId], revision)
+}
+
+// featureToggleRules returns copies of the rules of the feature ordered by
+// created time, it must be called with the mutex held.
+func (fs *FeatureToggleMemStore) featureToggleRules(featureId string) []ToggleRule {
+	rules := []ToggleRule{}
+	for _, rule := range fs.toggleRules {
+		if rule.FeatureId == featureId {
+			rules = append(rules, copyToggleRule(rule))
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Created.Equal(rules[j].Created) {
+			return rules[i].Id < rules[j].Id
+		}
+		return rules[i].Created.Before(rules[j].Created)
+	})
+	return rules
+}
+
+func (fs *FeatureToggleMemStore) ListRevisions(featureId string) (*[]Revision, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	revisions := []Revision{}
+	for i := len(fs.revisions[featureId]) - 1; i >= 0; i-- {
+		revisions = append(revisions, copyRevision(fs.revisions[featureId][i]))
+	}
+	return &revisions, nil
+}
+
+func (fs *FeatureToggleMemStore) RevertToRevision(featureId string, revision int, version int64) (*Revision, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	feature, ok := fs.features[featureId]
+	if !ok {
+		return nil, &NotFoundError{Kind:KindFeature, Key:featureId}
+	}
+	err := checkVersion(KindFeature, featureId, version, feature.Version)
+	if err != nil {
+		return nil, err
+	}
+	revisions := fs.revisions[featureId]
+	if revision < 1 || revision > len(revisions) {
+		return nil, revisionNotFound(featureId, revision)
+	}
+	target := copyRevision(revisions[revision - 1])
+	if other := fs.findFeatureByName(target.Feature.Name); other != nil && other.Id != featureId {
+		return nil, &AlreadyExistsError{Kind:KindFeature, Key:target.Feature.Name}
+	}
+	for _, rule := range target.ToggleRules {
+		if current, ok := fs.toggleRules[rule.Id]; ok && current.FeatureId != featureId {
+			return nil, &AlreadyExistsError{Kind:KindToggleRule, Key:rule.Id}
+		}
+		for property := range rule.Properties {
+			if _, ok := fs.properties[property]; !ok {
+				return nil, propertyDeletedSince(featureId, revision, property)
+			}
+		}
+	}
+	rules := fs.featureToggleRules(featureId)
+	target = revertedVersions(feature, rules, target)
+	err = recordRevert(fs.record, feature, rules, target)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		delete(fs.toggleRules, rule.Id)
+	}
+	fs.features[featureId] = target.Feature
+	for _, rule := range target.ToggleRules {
+		fs.toggleRules[rule.Id] = rule
+	}
+	fs.addRevision(featureId)
+
+	res := copyRevision(fs.revisions[featureId][len(fs.revisions[featureId]) - 1])
+	return &res, nil
+}
+
+func copyToggleRule(rule ToggleRule) ToggleRule {
+	rule.Properties = copyProperties(rule.Properties)
+	rule.Operators = copyOperators(rule.Operators)
+	rule.Variants = copyWeightedVariants(rule.Variants)
+	return rule
+}
+
+func copyRevision(revision Revision) Revision {
+	revision.Feature.Variants = copyVariants(revision.Feature.Variants)
+	rules := []ToggleRule{}
+	for _, rule := range revision.ToggleRules {
+		rules = append(rules, copyToggleRule(rule))
+	}
+	revision.ToggleRules = rules
+	return revision
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureToggleMemStore_RevertToRevision(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+	fs.CreateProperty(*NewProperty("username", "user name"))
+
+	featureId, _ := fs.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	ruleId, _ := fs.CreateToggleRule(*NewToggleRule(*featureId, true, "username", "adam"))
+	adam := fs.WithActor("jwt:adam")
+	adam.UpdateFeature(Feature{Id:*featureId, Description:"bad", Version:1}, []string{"description"})
+	adam.UpdateToggleRule(ToggleRule{Id:*ruleId, Properties:Properties{"username":"eve"}, Version:1}, []string{"properties"})
+	newRuleId, _ := adam.CreateToggleRule(*NewToggleRule(*featureId, true, "username", "bertil"))
+
+	revisions, err := fs.ListRevisions(*featureId)
+	require.NotNil(t, revisions, "Should list revisions, %v", err)
+	require.Len(t, *revisions, 5, "Should record a revision per change")
+	assert.Equal(t, 5, (*revisions)[0].Revision, "Should return the newest revision first")
+	assert.Equal(t, "jwt:adam", (*revisions)[0].Actor)
+	assert.Len(t, (*revisions)[0].ToggleRules, 2)
+	second := (*revisions)[3]
+	assert.Equal(t, 2, second.Revision)
+	assert.Equal(t, ANONYMOUS_ACTOR, second.Actor)
+	require.Len(t, second.ToggleRules, 1)
+	assert.Equal(t, Properties{"username":"adam"}, second.ToggleRules[0].Properties)
+
+	reverted, err := adam.RevertToRevision(*featureId, 2, 2)
+	require.NotNil(t, reverted, "Should revert, %v", err)
+	assert.Equal(t, 6, reverted.Revision, "Should record the revert as a new revision")
+	assert.Equal(t, "f description", reverted.Feature.Description)
+
+	feature, _ := fs.ReadFeature(*featureId)
+	assert.Equal(t, "f description", feature.Description, "Should restore the feature")
+	assert.Equal(t, int64(3), feature.Version, "Should increase the version of the restored feature")
+	rule, _ := fs.ReadToggleRule(*ruleId)
+	require.NotNil(t, rule)
+	assert.Equal(t, Properties{"username":"adam"}, rule.Properties, "Should restore the rule")
+	assert.Equal(t, int64(3), rule.Version)
+	rule, _ = fs.ReadToggleRule(*newRuleId)
+	assert.Nil(t, rule, "Should delete rules created after the revision")
+
+	entries, _ := fs.SearchAuditLog(AuditSearch{Kind:KindToggleRule, EntityId:*newRuleId, Limit:1})
+	require.Len(t, *entries, 1)
+	assert.Equal(t, OperationDelete, (*entries)[0].Operation, "Should record the changes of the revert")
+
+	_, err = fs.RevertToRevision(*featureId, 7, 3)
+	assert.IsType(t, &NotFoundError{}, err, "Should refuse unknown revisions")
+	_, err = fs.RevertToRevision("unknown", 1, 1)
+	assert.IsType(t, &NotFoundError{}, err, "Should refuse unknown features")
+	_, err = fs.RevertToRevision(*featureId, 1, 2)
+	assert.IsType(t, &VersionMismatchError{}, err, "Should refuse a stale version")
+	feature, _ = fs.ReadFeature(*featureId)
+	assert.Equal(t, int64(3), feature.Version, "Should leave the feature unchanged")
+}
+
+func TestFeatureToggleMemStore_RevertToRevision_refuses_conflicts(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+	fs.CreateProperty(*NewProperty("username", "user name"))
+
+	name := randomSufix("Feature-")
+	featureId, _ := fs.CreateFeature(*NewFeature(name, true, "f description"))
+	fs.UpdateFeature(Feature{Id:*featureId, Name:name + "-renamed", Version:1}, []string{"name"})
+	otherId, _ := fs.CreateFeature(*NewFeature(name, true, "other"))
+
+	_, err := fs.RevertToRevision(*featureId, 1, 2)
+	assert.IsType(t, &AlreadyExistsError{}, err, "Should refuse a name taken since")
+
+	ruleId, _ := fs.CreateToggleRule(*NewToggleRule(*featureId, true, "username", "adam"))
+	fs.UpdateToggleRule(ToggleRule{Id:*ruleId, FeatureId:*otherId, Version:1}, []string{"featureId"})
+	revisions, _ := fs.ListRevisions(*otherId)
+	assert.Len(t, *revisions, 2, "Should record a revision of the feature a rule moved to")
+
+	_, err = fs.RevertToRevision(*featureId, 3, 2)
+	assert.IsType(t, &AlreadyExistsError{}, err, "Should refuse a rule moved to another feature")
+	rule, _ := fs.ReadToggleRule(*ruleId)
+	assert.Equal(t, *otherId, rule.FeatureId, "Should leave the store unchanged")
+
+	fs.CreateProperty(*NewProperty("country", "country"))
+	countryRuleId, _ := fs.CreateToggleRule(*NewToggleRule(*otherId, true, "country", "se"))
+	fs.DeleteToggleRule(*countryRuleId, 1)
+	_, err = fs.DeleteProperty("country", 1)
+	require.Nil(t, err, "Should delete the property, %v", err)
+	revisions, _ = fs.ListRevisions(*otherId)
+	_, err = fs.RevertToRevision(*otherId, (*revisions)[1].Revision, 1)
+	require.IsType(t, &ReferencedError{}, err, "Should refuse a rule with a property deleted since")
+	assert.Equal(t, "country", err.(*ReferencedError).Key)
+
+	fs.DeleteToggleRule(*ruleId, 2)
+	fs.DeleteFeature(*featureId, 2)
+	revisions, _ = fs.ListRevisions(*featureId)
+	assert.Len(t, *revisions, 0, "Should delete the revisions with the feature")
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+	"github.com/satori/go.uuid"
+)
+
+func (fs *FeatureToggleMemStore) CreateToggleRule(toggleRule ToggleRule) (*string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	id := toggleRule.Id
+	if strings.Compare(id, "") == 0 {
+		id = uuid.NewV4().String()
+	}
+	if _, ok := fs.toggleRules[id]; ok {
+		return nil, &AlreadyExistsError{Kind:KindToggleRule, Key:id}
+	}
+	feature, ok := fs.features[toggleRule.FeatureId]
+	if !ok {
+		return nil, &NotFoundError{Kind:KindFeature, Key:toggleRule.FeatureId}
+	}
+	for property := range toggleRule.Properties {
+		if _, ok := fs.properties[property]; !ok {
+			return nil, &NotFoundError{Kind:KindProperty, Key:property}
+		}
+	}
+	err := validateProperties(toggleRule)
+	if err != nil {
+		return nil, err
+	}
+	err = validateSchedule(toggleRule.Starts, toggleRule.Expires)
+	if err != nil {
+		return nil, err
+	}
+	err = validateRollout(toggleRule)
+	if err != nil {
+		return nil, err
+	}
+	err = validateOperators(toggleRule)
+	if err != nil {
+		return nil, err
+	}
+	err = validateRuleVariants(toggleRule, feature)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := toggleRule
+	rule.Id = id
+	rule.Created = time.Now()
+	rule.Version = 1
+	rule.Properties = copyProperties(toggleRule.Properties)
+	rule.Operators = copyOperators(toggleRule.Operators)
+	rule.Variants = copyWeightedVariants(toggleRule.Variants)
+	err = fs.record(KindToggleRule, id, OperationCreate, nil, rule)
+	if err != nil {
+		return nil, err
+	}
+	fs.toggleRules[id] = rule
+	fs.addRevision(rule.FeatureId)
+
+	return &id, nil
+}
+
+func (fs *FeatureToggleMemStore) ReadToggleRule(id string) (*ToggleRule, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	if rule, ok := fs.toggleRules[id]; ok {
+		rule.Properties = copyProperties(rule.Properties)
+		rule.Operators = copyOperators(rule.Operators)
+		rule.Variants = copyWeightedVariants(rule.Variants)
+		return &rule, nil
+	}
+	return nil, nil
+}
+
+func (fs *FeatureToggleMemStore) DeleteToggleRule(id string, version int64) (*bool, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	rule, b := fs.toggleRules[id]
+	if b {
+		err := checkVersion(KindToggleRule, id, version, rule.Version)
+		if err != nil {
+			return nil, err
+		}
+		err = fs.record(KindToggleRule, id, OperationDelete, rule, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	delete(fs.toggleRules, id)
+	if b {
+		fs.addRevision(rule.FeatureId)
+	}
+	return &b, nil
+}
+
+func (fs *FeatureToggleMemStore) SearchToggleRule(search ToggleRuleSearch) (*[]ToggleRule, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	res := []ToggleRule{}
+	for _, rule := range fs.toggleRules {
+		if search.Name != nil {
+			feature, ok := fs.features[rule.FeatureId]
+			if !ok || strings.Compare(feature.Name, *search.Name) != 0 {
+				continue
+			}
+		}
+		if search.Enabled != nil && rule.Enabled != *search.Enabled {
+			continue
+		}
+		if !inRange(rule.Created, search.CreatedStart, search.CreatedEnd) || !inRange(rule.Expires, search.ExpiresStart, search.ExpiresEnd) {
+			continue
+		}
+		if !matchesFilter(rule.Properties, search.Properties) {
+			continue
+		}
+		rule.Properties = copyProperties(rule.Properties)
+		rule.Operators = copyOperators(rule.Operators)
+		rule.Variants = copyWeightedVariants(rule.Variants)
+		res = append(res, rule)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Created.Equal(res[j].Created) {
+			return res[i].Id < res[j].Id
+		}
+		return res[i].Created.Before(res[j].Created)
+	})
+	return &res, nil
+}
+
+func (fs *FeatureToggleMemStore) UpdateToggleRule(update ToggleRule, paths []string) (*ToggleRule, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	rule, ok := fs.toggleRules[update.Id]
+	if !ok {
+		return nil, nil
+	}
+	err := checkVersion(KindToggleRule, rule.Id, update.Version, rule.Version)
+	if err != nil {
+		return nil, err
+	}
+	before := rule
+	err = applyToggleRuleUpdate(&rule, update, paths)
+	if err != nil {
+		return nil, err
+	}
+	rule.Version++
+	feature, ok := fs.features[rule.FeatureId]
+	if !ok {
+		return nil, &NotFoundError{Kind:KindFeature, Key:rule.FeatureId}
+	}
+	for property := range rule.Properties {
+		if _, ok := fs.properties[property]; !ok {
+			return nil, &NotFoundError{Kind:KindProperty, Key:property}
+		}
+	}
+	err = validateRuleVariants(rule, feature)
+	if err != nil {
+		return nil, err
+	}
+	err = fs.record(KindToggleRule, rule.Id, OperationUpdate, before, rule)
+	if err != nil {
+		return nil, err
+	}
+	fs.toggleRules[rule.Id] = rule
+	fs.addRevision(rule.FeatureId)
+	if before.FeatureId != rule.FeatureId {
+		fs.addRevision(before.FeatureId)
+	}
+
+	rule.Properties = copyProperties(rule.Properties)
+	rule.Operators = copyOperators(rule.Operators)
+	rule.Variants = copyWeightedVariants(rule.Variants)
+	return &rule, nil
+}
+
+func (fs *FeatureToggleMemStore) GetEnabledToggleRules() (*[]featuretree.ToggleRule, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	now := time.Now()
+	res := []featuretree.ToggleRule{}
+	for _, rule := range fs.toggleRules {
+		feature, ok := fs.features[rule.FeatureId]
+		if !ok || !feature.Enabled || !rule.Enabled {
+			continue
+		}
+		props := make(featuretree.Properties)
+		for k, v := range rule.Properties {
+			props[k] = v
+		}
+		treeRule := featuretree.ToggleRule{Id: rule.Id, Name: feature.Name, Properties: props,
+			RolloutPercentage: rule.RolloutPercentage, RolloutProperty: rule.RolloutProperty,
+			Operators: copyOperators(rule.Operators), Deny: rule.Deny, Priority: rule.Priority,
+			Variants: copyWeightedVariants(rule.Variants), Starts: rule.Starts, Expires: rule.Expires}.Within(feature.Starts, feature.Expires)
+		if treeRule.ExpiredAt(now) {
+			continue
+		}
+		res = append(res, treeRule)
+	}
+	return &res, nil
+}
+
+func (fs *FeatureToggleMemStore) GetFeatureVariants() (*[]featuretree.FeatureVariants, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	res := []featuretree.FeatureVariants{}
+	for _, feature := range fs.features {
+		if !feature.Enabled || len(feature.Variants) == 0 {
+			continue
+		}
+		res = append(res, featuretree.FeatureVariants{Feature:feature.Name, Variants:copyVariants(feature.Variants),
+			Default:feature.DefaultVariant})
+	}
+	return &res, nil
+}
+
+// inRange returns true if t is within start and end, a zero start or end
+// leaves that side open. A zero t is only within a range open on both sides.
+func inRange(t time.Time, start time.Time, end time.Time) bool {
+	if start.IsZero() && end.IsZero() {
+		return true
+	}
+	if t.IsZero() {
+		return false
+	}
+	return (start.IsZero() || !t.Before(start)) && (end.IsZero() || !t.After(end))
+}
+
+func matchesFilter(properties Properties, filter Filter) bool {
+	for k, v := range filter {
+		if value, ok := properties[k]; !ok || strings.Compare(value, v) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"testing"
+	"time"
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMemStore(t *testing.T, enabled bool, propertyNames ...string) (FeatureToggleStore, *Feature) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+
+	feature := NewFeature(randomSufix("Name-"), enabled, "f description")
+	featureId, err := fs.CreateFeature(*feature)
+	require.NotNil(t, featureId, "Should get featureId, %v", err)
+
+	for _, name := range propertyNames {
+		p, err := fs.CreateProperty(*NewProperty(name, "p description"))
+		require.NotNil(t, p, "Should get propertyName, %v", err)
+	}
+	return fs, feature
+}
+
+func TestFeatureToggleMemStore_CreateToggleRule(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1", "prop2")
+
+	id, err := fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "val 1", "prop2", "val 2"))
+	assert.NotNil(t, id, "shall get an id in return, %v", err)
+}
+
+func TestFeatureToggleMemStore_CreateToggleRule__unknown_property(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1")
+
+	id, err := fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "val 1", "unknown", "val 2"))
+	assert.Nil(t, id, "shall not get an id for an unknown property")
+	assert.NotNil(t, err, "shall get an error for an unknown property")
+}
+
+func TestFeatureToggleMemStore_CreateToggleRule__unknown_feature(t *testing.T) {
+	fs, _ := setupMemStore(t, true, "prop1")
+
+	id, err := fs.CreateToggleRule(*NewToggleRule("unknown", true, "prop1", "val 1"))
+	assert.Nil(t, id, "shall not get an id for an unknown feature")
+	assert.NotNil(t, err, "shall get an error for an unknown feature")
+}
+
+func TestFeatureToggleMemStore_ReadToggleRule(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1", "prop2")
+
+	ruleId, err := fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "val1", "prop2", "val2"))
+	require.NotNil(t, ruleId, "Should get ruleId, %v", err)
+
+	toggleRule, err := fs.ReadToggleRule(*ruleId)
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.NotNil(t, toggleRule, "Result shall contain a toggle rule")
+	assert.Equal(t, feature.Id, toggleRule.FeatureId, "shall have feature id")
+	assert.True(t, toggleRule.Enabled, "shall be enabled")
+	assert.False(t, toggleRule.Created.IsZero(), "shall have a created time")
+	assert.Equal(t, "val1", toggleRule.Properties["prop1"], "prop1 shall have value 'val1'")
+	assert.Equal(t, "val2", toggleRule.Properties["prop2"], "prop2 shall have value 'val2'")
+}
+
+func TestFeatureToggleMemStore_DeleteToggleRule(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1")
+
+	ruleId, err := fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "val1"))
+	require.Nil(t, err, "Failed to create toggle rule, %v", err)
+
+	res, err := fs.DeleteToggleRule(*ruleId, 1)
+	require.Nil(t, err, "Failed to delete toggle rule %v", err)
+	assert.True(t, *res, "Should get true as result")
+
+	toggleRule, err := fs.ReadToggleRule(*ruleId)
+	assert.Nil(t, toggleRule, "Should not find a deleted toggle rule")
+}
+
+func TestFeatureToggleMemStore_SearchToggleRule(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1", "prop2")
+
+	fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "val1", "prop2", "val2"))
+	fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "val1", "prop2", "other"))
+
+	filter := make(Filter)
+	filter["prop1"] = "val1"
+	filter["prop2"] = "val2"
+	toggleRules, err := fs.SearchToggleRule(ToggleRuleSearch{Name: &feature.Name, Properties: filter})
+
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.Equal(t, 1, len(*toggleRules), "Result shall contain one toggle rule, %v", *toggleRules)
+	assert.Equal(t, "val2", (*toggleRules)[0].Properties["prop2"], "prop2 shall have value 'val2'")
+
+	toggleRules, err = fs.SearchToggleRule(ToggleRuleSearch{Properties: Filter{"prop1": "val1"}})
+	assert.Equal(t, 2, len(*toggleRules), "Result shall contain two toggle rules, %v", *toggleRules)
+
+	unknown := "unknown"
+	toggleRules, err = fs.SearchToggleRule(ToggleRuleSearch{Name: &unknown})
+	assert.Equal(t, 0, len(*toggleRules), "Result shall not contain any toggle rules, %v", *toggleRules)
+}
+
+func TestFeatureToggleMemStore_GetEnabledToggleRules(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1")
+
+	fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "enabled"))
+	fs.CreateToggleRule(*NewToggleRule(feature.Id, false, "prop1", "disabled"))
+	expired := NewToggleRule(feature.Id, true, "prop1", "expired")
+	expired.Expires = time.Now().Add(-time.Hour)
+	fs.CreateToggleRule(*expired)
+
+	rules, err := fs.GetEnabledToggleRules()
+
+	require.NotNil(t, rules, "Should get rules, %v", err)
+	require.Equal(t, 1, len(*rules), "Should only get the enabled rule, %v", *rules)
+	assert.Equal(t, feature.Name, (*rules)[0].Name, "Rule should have the feature name")
+	assert.Equal(t, "enabled", (*rules)[0].Properties["prop1"], "Rule should have its properties")
+}
+
+func TestFeatureToggleMemStore_GetEnabledToggleRules__disabled_feature(t *testing.T) {
+	fs, feature := setupMemStore(t, false, "prop1")
+
+	fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "val1"))
+
+	rules, err := fs.GetEnabledToggleRules()
+
+	require.NotNil(t, rules, "Should get rules, %v", err)
+	assert.Equal(t, 0, len(*rules), "Should not get rules for a disabled feature")
+}
+
+func TestFeatureToggleMemStore_CreateToggleRule__rollout(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1")
+
+	rule := NewToggleRule(feature.Id, true, "prop1", "val1")
+	rule.RolloutPercentage = 20
+	rule.RolloutProperty = "userid"
+	id, err := fs.CreateToggleRule(*rule)
+	require.NotNil(t, id, "shall get an id in return, %v", err)
+
+	rules, err := fs.GetEnabledToggleRules()
+	require.Equal(t, 1, len(*rules))
+	assert.Equal(t, 20, (*rules)[0].RolloutPercentage)
+	assert.Equal(t, "userid", (*rules)[0].RolloutProperty)
+
+	rule.RolloutPercentage = 120
+	id, err = fs.CreateToggleRule(*rule)
+	assert.Nil(t, id, "shall not accept a percentage above 100")
+	assert.NotNil(t, err)
+}
+
+func TestFeatureToggleMemStore_CreateToggleRule__operators(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "appversion")
+
+	rule := NewToggleRule(feature.Id, true, "appversion", "^1.2.0")
+	rule.Operators = map[string]string{"appversion": featuretree.OperatorSemver}
+	id, err := fs.CreateToggleRule(*rule)
+	require.NotNil(t, id, "shall get an id in return, %v", err)
+
+	rules, err := fs.GetEnabledToggleRules()
+	require.Equal(t, 1, len(*rules))
+	assert.Equal(t, featuretree.OperatorSemver, (*rules)[0].Operators["appversion"])
+
+	rule.Properties["appversion"] = "not a version"
+	id, err = fs.CreateToggleRule(*rule)
+	assert.Nil(t, id, "shall not accept an invalid operand")
+	assert.NotNil(t, err)
+}
+
+func TestFeatureToggleMemStore_GetEnabledToggleRules__deny(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "usertype")
+
+	rule := NewToggleRule(feature.Id, true, "usertype", "intern")
+	rule.Deny = true
+	rule.Priority = 10
+	fs.CreateToggleRule(*rule)
+
+	rules, err := fs.GetEnabledToggleRules()
+	require.NotNil(t, rules, "Should get rules, %v", err)
+	require.Equal(t, 1, len(*rules))
+	assert.True(t, (*rules)[0].Deny, "Rule should be a deny rule")
+	assert.Equal(t, 10, (*rules)[0].Priority)
+}
+
+func TestFeatureToggleMemStore_CreateToggleRule__variants(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+	fs.CreateProperty(*NewProperty("prop1", "p description"))
+	feature := NewFeature(randomSufix("Name-"), true, "f description")
+	feature.Variants = []featuretree.Variant{{Name: "a", Type: featuretree.VariantTypeString, Value: "A"}, {Name: "b", Type: featuretree.VariantTypeString, Value: "B"}}
+	fs.CreateFeature(*feature)
+
+	rule := NewToggleRule(feature.Id, true, "prop1", "val1")
+	rule.RolloutProperty = "userid"
+	rule.Variants = []featuretree.WeightedVariant{{Variant: "a", Weight: 30}, {Variant: "b", Weight: 70}}
+	id, err := fs.CreateToggleRule(*rule)
+	require.NotNil(t, id, "shall get an id in return, %v", err)
+
+	rules, err := fs.GetEnabledToggleRules()
+	require.Equal(t, 1, len(*rules))
+	assert.Equal(t, rule.Variants, (*rules)[0].Variants)
+
+	rule.Variants = []featuretree.WeightedVariant{{Variant: "unknown", Weight: 1}}
+	id, err = fs.CreateToggleRule(*rule)
+	assert.Nil(t, id, "shall not accept a variant the feature doesn't have")
+	assert.NotNil(t, err)
+}
+
+func TestFeatureToggleMemStore_SearchToggleRule__enabled_and_time_ranges(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1")
+
+	start := time.Now()
+	enabledId, _ := fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "enabled"))
+	expiring := NewToggleRule(feature.Id, false, "prop1", "expiring")
+	expiring.Expires = start.Add(time.Hour)
+	expiringId, _ := fs.CreateToggleRule(*expiring)
+
+	enabled := true
+	toggleRules, err := fs.SearchToggleRule(ToggleRuleSearch{Enabled: &enabled})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.Equal(t, 1, len(*toggleRules))
+	assert.Equal(t, *enabledId, (*toggleRules)[0].Id)
+
+	toggleRules, err = fs.SearchToggleRule(ToggleRuleSearch{ExpiresStart: start, ExpiresEnd: start.Add(2 * time.Hour)})
+	require.Equal(t, 1, len(*toggleRules), "Only rules with an expiry time in the range")
+	assert.Equal(t, *expiringId, (*toggleRules)[0].Id)
+
+	toggleRules, err = fs.SearchToggleRule(ToggleRuleSearch{CreatedStart: start.Add(-time.Minute)})
+	assert.Equal(t, 2, len(*toggleRules))
+	assert.True(t, !(*toggleRules)[1].Created.Before((*toggleRules)[0].Created), "Should be ordered by created time")
+
+	toggleRules, err = fs.SearchToggleRule(ToggleRuleSearch{CreatedEnd: start.Add(-time.Minute)})
+	assert.Equal(t, 0, len(*toggleRules))
+}
+
+func TestFeatureToggleMemStore_UpdateToggleRule(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1", "prop2")
+
+	id, err := fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "val 1"))
+	require.NotNil(t, id, "Should get an id, %v", err)
+	created, _ := fs.ReadToggleRule(*id)
+
+	update := NewToggleRule("", false, "prop2", "val 2")
+	update.Id = *id
+	update.Version = 1
+	rule, err := fs.UpdateToggleRule(*update, []string{"properties"})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.NotNil(t, rule)
+	assert.Equal(t, Properties{"prop2": "val 2"}, rule.Properties, "Should replace the properties")
+	assert.True(t, rule.Enabled, "Should not change fields outside the paths")
+	assert.Equal(t, feature.Id, rule.FeatureId)
+	assert.Equal(t, created.Created, rule.Created, "Should keep the created time")
+
+	read, _ := fs.ReadToggleRule(*id)
+	assert.Equal(t, rule.Properties, read.Properties, "Should store the update")
+
+	update = NewToggleRule("", false, "unknown", "val")
+	update.Id = *id
+	update.Version = 2
+	rule, err = fs.UpdateToggleRule(*update, []string{"properties"})
+	assert.Nil(t, rule, "Should not update to an unknown property")
+	assert.NotNil(t, err)
+
+	rule, err = fs.UpdateToggleRule(*update, []string{"created"})
+	assert.NotNil(t, err, "Should not update the created time")
+
+	update.Id = "unknown"
+	rule, err = fs.UpdateToggleRule(*update, []string{"enabled"})
+	assert.Nil(t, rule, "Should not find an unknown rule")
+	assert.Nil(t, err, "Should not get an error for an unknown rule, %v", err)
+}
+
+func TestFeatureToggleMemStore_UpdateToggleRule__operators(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1", "prop2")
+
+	rule := NewToggleRule(feature.Id, true, "prop1", "a,b", "prop2", "val 2")
+	rule.Operators = map[string]string{"prop1": featuretree.OperatorIn, "prop2": featuretree.OperatorEquals}
+	id, err := fs.CreateToggleRule(*rule)
+	require.NotNil(t, id, "Should get an id, %v", err)
+	read, _ := fs.ReadToggleRule(*id)
+	assert.Equal(t, map[string]string{"prop1": featuretree.OperatorIn}, read.Operators, "Should not keep the equals operator")
+
+	update := NewToggleRule("", false, "prop2", "val 3")
+	update.Id = *id
+	update.Version = 1
+	updated, err := fs.UpdateToggleRule(*update, []string{"properties"})
+	require.Nil(t, err, "Should drop the operator of the removed property, %v", err)
+	assert.Nil(t, updated.Operators)
+	read, _ = fs.ReadToggleRule(*id)
+	assert.Nil(t, read.Operators, "Should store the rule without the operator")
+}
+
+func TestFeatureToggleMemStore_GetEnabledToggleRules__schedule(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleMemStore()
+	now := time.Now()
+	feature := NewFeature("scheduled", true, "f description")
+	feature.Starts = now.Add(time.Hour)
+	feature.Expires = now.Add(3 * time.Hour)
+	_, err := fs.CreateFeature(*feature)
+	require.Nil(t, err)
+	fs.CreateProperty(*NewProperty("prop1", "p description"))
+
+	upcoming := NewToggleRule(feature.Id, true, "prop1", "upcoming")
+	upcoming.Starts = now.Add(2 * time.Hour)
+	upcoming.Expires = now.Add(4 * time.Hour)
+	upcomingId, err := fs.CreateToggleRule(*upcoming)
+	require.Nil(t, err)
+	// ends before the feature starts
+	outside := NewToggleRule(feature.Id, true, "prop1", "outside")
+	outside.Expires = now.Add(30 * time.Minute)
+	fs.CreateToggleRule(*outside)
+
+	rules, err := fs.GetEnabledToggleRules()
+
+	require.Nil(t, err)
+	require.Equal(t, 1, len(*rules), "Should get the upcoming rule only, %v", *rules)
+	assert.Equal(t, *upcomingId, (*rules)[0].Id)
+	assert.True(t, upcoming.Starts.Equal((*rules)[0].Starts), "Should keep the start of the rule")
+	assert.True(t, feature.Expires.Equal((*rules)[0].Expires), "Should expire with the feature")
+}
+
+func TestFeatureToggleMemStore_CreateToggleRule__expires_before_starts(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1")
+	rule := NewToggleRule(feature.Id, true, "prop1", "val1")
+	rule.Starts = time.Now().Add(time.Hour)
+	rule.Expires = time.Now()
+
+	_, err := fs.CreateToggleRule(*rule)
+
+	require.IsType(t, &InvalidArgumentError{}, err)
+	assert.Equal(t, "expires", err.(*InvalidArgumentError).Field)
+}
+
+func TestFeatureToggleMemStore_CreateToggleRule__no_properties(t *testing.T) {
+	fs, feature := setupMemStore(t, true, "prop1")
+
+	id, err := fs.CreateToggleRule(*NewToggleRule(feature.Id, true))
+	assert.Nil(t, id, "shall not get an id for a rule without properties")
+	require.IsType(t, &InvalidArgumentError{}, err)
+	assert.Equal(t, "properties", err.(*InvalidArgumentError).Field)
+
+	id, err = fs.CreateToggleRule(*NewToggleRule(feature.Id, true, "prop1", "val 1"))
+	require.NotNil(t, id, "Should get an id, %v", err)
+	update := NewToggleRule("", true)
+	update.Id = *id
+	update.Version = 1
+	rule, err := fs.UpdateToggleRule(*update, []string{"properties"})
+	assert.Nil(t, rule, "Should not remove all properties")
+	require.IsType(t, &InvalidArgumentError{}, err)
+	assert.Equal(t, "properties", err.(*InvalidArgumentError).Field)
+}
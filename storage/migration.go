@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	CREATE_SCHEMA_MIGRATION_SQL = "CREATE TABLE IF NOT EXISTS schema_migration (version INTEGER NOT NULL PRIMARY KEY, description TEXT NOT NULL, applied TIMESTAMP NOT NULL)"
+	READ_SCHEMA_VERSION_SQL = "SELECT COALESCE(MAX(version), 0) FROM schema_migration"
+	INSERT_SCHEMA_MIGRATION_SQL = "INSERT INTO schema_migration(version, description, applied) values ($1,$2,$3)"
+	DELETE_SCHEMA_MIGRATION_SQL = "DELETE FROM schema_migration WHERE version = $1"
+	// serializes migrations when several instances start at the same time
+	LOCK_SCHEMA_MIGRATION_SQL = "SELECT pg_advisory_xact_lock(7231964)"
+)
+
+// Migration is one versioned step of the database schema. Up and Down may
+// contain several statements.
+type Migration struct {
+	Version     int
+	Description string
+	Up          string
+	Down        string
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the schema migrations shipped with the
+// storage package.
+func NewMigrator(db *sql.DB) *Migrator {
+	return &Migrator{db, migrations}
+}
+
+// Version returns the latest applied migration version, 0 for an empty database.
+func (m *Migrator) Version() (int, error) {
+	_, err := m.db.Exec(CREATE_SCHEMA_MIGRATION_SQL)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Migrator: Failed to create schema_migration table, %v", err))
+	}
+	return readSchemaVersion(m.db)
+}
+
+// LatestVersion returns the version of the last known migration.
+func (m *Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations) - 1].Version
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	return m.MigrateTo(m.LatestVersion())
+}
+
+// Down reverts the given number of applied migrations, at least one. Use
+// MigrateTo(0) to revert all of them.
+func (m *Migrator) Down(steps int) error {
+	if steps < 1 {
+		return errors.New(fmt.Sprintf("Migrator: Invalid number of steps %d, must be at least 1", steps))
+	}
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	target := 0
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if m.migrations[i].Version <= version {
+			if steps == 0 {
+				target = m.migrations[i].Version
+				break
+			}
+			steps--
+		}
+	}
+	return m.MigrateTo(target)
+}
+
+// MigrateTo applies or reverts migrations, one transaction per migration,
+// until the schema is at the target version.
+func (m *Migrator) MigrateTo(target int) error {
+	err := validateMigrations(m.migrations)
+	if err != nil {
+		return err
+	}
+	if target != 0 && m.findMigration(target) < 0 {
+		return errors.New(fmt.Sprintf("Migrator: Unknown version %d", target))
+	}
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version > version && migration.Version <= target {
+			err = m.apply(migration, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= version && migration.Version > target {
+			err = m.apply(migration, false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(migration Migration, up bool) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Migrator: Failed to create transaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(LOCK_SCHEMA_MIGRATION_SQL)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Migrator: Failed to lock schema_migration, %v", err))
+	}
+	// another instance may have migrated while we waited for the lock
+	version, err := readSchemaVersion(tx)
+	if err != nil {
+		return err
+	}
+	if up && version >= migration.Version || !up && version < migration.Version {
+		return nil
+	}
+
+	if up {
+		fmt.Printf("Migrator: Applying migration %d, %s\n", migration.Version, migration.Description)
+		_, err = tx.Exec(migration.Up)
+		if err == nil {
+			_, err = tx.Exec(INSERT_SCHEMA_MIGRATION_SQL, migration.Version, migration.Description, time.Now().UTC())
+		}
+	} else {
+		fmt.Printf("Migrator: Reverting migration %d, %s\n", migration.Version, migration.Description)
+		_, err = tx.Exec(migration.Down)
+		if err == nil {
+			_, err = tx.Exec(DELETE_SCHEMA_MIGRATION_SQL, migration.Version)
+		}
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("Migrator: Failed to migrate version %d, %v", migration.Version, err))
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) findMigration(version int) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func readSchemaVersion(q queryRower) (int, error) {
+	var version int
+	err := q.QueryRow(READ_SCHEMA_VERSION_SQL).Scan(&version)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Migrator: Failed to read schema version, %v", err))
+	}
+	return version, nil
+}
+
+func validateMigrations(migrations []Migration) error {
+	previous := 0
+	for _, migration := range migrations {
+		if migration.Version <= previous {
+			return errors.New(fmt.Sprintf("Migrator: Migration %d must have a higher version than %d", migration.Version, previous))
+		}
+		if migration.Up == "" || migration.Down == "" {
+			return errors.New(fmt.Sprintf("Migrator: Migration %d must have both up and down statements", migration.Version))
+		}
+		previous = migration.Version
+	}
+	return nil
+}
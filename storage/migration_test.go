@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMigrations(t *testing.T) {
+	assert.Nil(t, validateMigrations(migrations), "shipped migrations should be valid")
+}
+
+func TestValidateMigrations__order(t *testing.T) {
+	m := []Migration{
+		{Version: 2, Description: "two", Up: "up", Down: "down"},
+		{Version: 1, Description: "one", Up: "up", Down: "down"},
+	}
+	assert.NotNil(t, validateMigrations(m), "versions out of order should be invalid")
+}
+
+func TestValidateMigrations__missing_down(t *testing.T) {
+	m := []Migration{
+		{Version: 1, Description: "one", Up: "up"},
+	}
+	assert.NotNil(t, validateMigrations(m), "migration without down should be invalid")
+}
+
+func TestMigrator_LatestVersion(t *testing.T) {
+	m := &Migrator{migrations: []Migration{
+		{Version: 1, Up: "up", Down: "down"},
+		{Version: 3, Up: "up", Down: "down"},
+	}}
+	assert.Equal(t, 3, m.LatestVersion())
+	assert.Equal(t, 0, (&Migrator{}).LatestVersion())
+}
+
+func TestMigrator_Down__invalid_steps(t *testing.T) {
+	m := &Migrator{migrations: []Migration{{Version: 1, Up: "up", Down: "down"}}}
+	assert.NotNil(t, m.Down(0), "zero steps should be invalid")
+	assert.NotNil(t, m.Down(-1), "negative steps should not revert every migration")
+}
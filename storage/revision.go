@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Revision is an immutable snapshot of a feature and its toggle rules. One is
+// recorded by every change of the feature or of its rules, numbered from 1,
+// and the revisions are deleted with the feature.
+type Revision struct {
+	FeatureId   string
+	Revision    int
+	Created     time.Time
+	Actor       string
+	Feature     Feature
+	ToggleRules []ToggleRule
+}
+
+func revisionNotFound(featureId string, revision int) error {
+	return &NotFoundError{Kind:KindRevision, Key:fmt.Sprintf("%s/%d", featureId, revision)}
+}
+
+// propertyDeletedSince is returned when a rule of the revision has a
+// property that has been deleted, the revision can't be restored without it.
+func propertyDeletedSince(featureId string, revision int, property string) error {
+	return &ReferencedError{Kind:KindProperty, Key:property, ByKind:KindRevision, ByKey:fmt.Sprintf("%s/%d", featureId, revision)}
+}
+
+// revertedVersions returns the revision with the versions the feature and
+// rules get when they are restored, one more than their current version. A
+// rule that has been deleted since continues from its version in the
+// revision.
+func revertedVersions(feature Feature, rules []ToggleRule, revision Revision) Revision {
+	current := make(map[string]int64)
+	for _, rule := range rules {
+		current[rule.Id] = rule.Version
+	}
+	revision.Feature.Version = feature.Version + 1
+	restored := []ToggleRule{}
+	for _, rule := range revision.ToggleRules {
+		if version, ok := current[rule.Id]; ok {
+			rule.Version = version + 1
+		} else {
+			rule.Version++
+		}
+		restored = append(restored, rule)
+	}
+	revision.ToggleRules = restored
+	return revision
+}
+
+// recordRevert records the changes of reverting the feature and its rules to
+// the revision with record, the rules are matched by id.
+func recordRevert(record func(kind string, entityId string, operation string, before interface{}, after interface{}) error,
+	feature Feature, rules []ToggleRule, revision Revision) error {
+	err := record(KindFeature, feature.Id, OperationUpdate, feature, revision.Feature)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]ToggleRule)
+	for _, rule := range rules {
+		current[rule.Id] = rule
+	}
+	restored := make(map[string]bool)
+	for _, rule := range revision.ToggleRules {
+		restored[rule.Id] = true
+		if before, ok := current[rule.Id]; ok {
+			err = record(KindToggleRule, rule.Id, OperationUpdate, before, rule)
+		} else {
+			err = record(KindToggleRule, rule.Id, OperationCreate, nil, rule)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, rule := range rules {
+		if !restored[rule.Id] {
+			err = record(KindToggleRule, rule.Id, OperationDelete, rule, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package storage
+
+// migrations holds the database schema. Append new migrations at the end with
+// a higher version, never change a migration that has been released.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Description: "initial schema",
+		Up: `
+CREATE TABLE IF NOT EXISTS public.feature (
+  id          TEXT    NOT NULL PRIMARY KEY,
+  name        TEXT    NOT NULL UNIQUE,
+  enabled     BOOLEAN NOT NULL,
+  description TEXT    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.property (
+  name        TEXT NOT NULL PRIMARY KEY,
+  description TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.toggle_rule (
+  id        TEXT      NOT NULL,
+  featureId TEXT      NOT NULL,
+  property  TEXT      NOT NULL,
+  value     TEXT      NOT NULL,
+  created   TIMESTAMP NOT NULL,
+  expires   TIMESTAMP,
+  enabled   BOOLEAN   NOT NULL,
+  PRIMARY KEY (id, property),
+  CONSTRAINT fk_property
+  FOREIGN KEY (property)
+  REFERENCES public.property (name),
+  CONSTRAINT fk_feature
+  FOREIGN KEY (featureId)
+  REFERENCES feature (id)
+);`,
+		Down: `
+DROP TABLE public.toggle_rule;
+DROP TABLE public.property;
+DROP TABLE public.feature;`,
+	},
+	{
+		Version: 2,
+		Description: "percentage rollout of toggle rules",
+		Up: `
+ALTER TABLE public.toggle_rule ADD COLUMN rollout_percentage INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE public.toggle_rule ADD COLUMN rollout_property TEXT NOT NULL DEFAULT '';`,
+		Down: `
+ALTER TABLE public.toggle_rule DROP COLUMN rollout_property;
+ALTER TABLE public.toggle_rule DROP COLUMN rollout_percentage;`,
+	},
+	{
+		Version: 3,
+		Description: "comparison operators on toggle rule properties",
+		Up: `
+ALTER TABLE public.toggle_rule ADD COLUMN operator TEXT NOT NULL DEFAULT 'eq';`,
+		Down: `
+ALTER TABLE public.toggle_rule DROP COLUMN operator;`,
+	},
+	{
+		Version: 4,
+		Description: "deny rules and rule priority",
+		Up: `
+ALTER TABLE public.toggle_rule ADD COLUMN deny BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE public.toggle_rule ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;`,
+		Down: `
+ALTER TABLE public.toggle_rule DROP COLUMN priority;
+ALTER TABLE public.toggle_rule DROP COLUMN deny;`,
+	},
+	{
+		Version: 5,
+		Description: "feature variants",
+		Up: `
+ALTER TABLE public.feature ADD COLUMN default_variant TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE public.feature_variant (
+  featureId TEXT    NOT NULL,
+  name      TEXT    NOT NULL,
+  position  INTEGER NOT NULL,
+  type      TEXT    NOT NULL,
+  value     TEXT    NOT NULL,
+  PRIMARY KEY (featureId, name),
+  CONSTRAINT fk_feature
+  FOREIGN KEY (featureId)
+  REFERENCES public.feature (id)
+);
+
+CREATE TABLE public.toggle_rule_variant (
+  ruleId   TEXT    NOT NULL,
+  variant  TEXT    NOT NULL,
+  position INTEGER NOT NULL,
+  weight   INTEGER NOT NULL,
+  PRIMARY KEY (ruleId, variant)
+);`,
+		Down: `
+DROP TABLE public.toggle_rule_variant;
+DROP TABLE public.feature_variant;
+ALTER TABLE public.feature DROP COLUMN default_variant;`,
+	},
+	{
+		Version: 6,
+		Description: "scheduling window of features and toggle rules",
+		Up: `
+ALTER TABLE public.toggle_rule ADD COLUMN starts TIMESTAMP;
+ALTER TABLE public.feature ADD COLUMN starts TIMESTAMP;
+ALTER TABLE public.feature ADD COLUMN expires TIMESTAMP;
+UPDATE public.toggle_rule SET expires = NULL WHERE expires < '0002-01-01';`,
+		Down: `
+ALTER TABLE public.feature DROP COLUMN expires;
+ALTER TABLE public.feature DROP COLUMN starts;
+ALTER TABLE public.toggle_rule DROP COLUMN starts;`,
+	},
+	{
+		Version: 7,
+		Description: "api keys",
+		Up: `
+CREATE TABLE public.api_key (
+  id      TEXT      NOT NULL,
+  name    TEXT      NOT NULL,
+  hash    TEXT      NOT NULL,
+  scope   TEXT      NOT NULL,
+  created TIMESTAMP NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT uk_api_key_hash UNIQUE (hash)
+);`,
+		Down: `
+DROP TABLE public.api_key;`,
+	},
+	{
+		Version: 8,
+		Description: "role bindings and feature owners",
+		Up: `
+CREATE TABLE public.role_binding (
+  principal TEXT NOT NULL,
+  role      TEXT NOT NULL,
+  PRIMARY KEY (principal)
+);
+
+CREATE TABLE public.feature_owner (
+  featureId TEXT NOT NULL,
+  principal TEXT NOT NULL,
+  PRIMARY KEY (featureId, principal),
+  CONSTRAINT fk_feature
+  FOREIGN KEY (featureId)
+  REFERENCES public.feature (id)
+);`,
+		Down: `
+DROP TABLE public.feature_owner;
+DROP TABLE public.role_binding;`,
+	},
+	{
+		Version: 9,
+		Description: "audit log",
+		Up: `
+CREATE TABLE public.audit_log (
+  id        TEXT      NOT NULL,
+  actor     TEXT      NOT NULL,
+  created   TIMESTAMP NOT NULL,
+  kind      TEXT      NOT NULL,
+  entityId  TEXT      NOT NULL,
+  operation TEXT      NOT NULL,
+  before    JSONB,
+  after     JSONB,
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX ix_audit_log_entity ON public.audit_log (kind, entityId);
+CREATE INDEX ix_audit_log_created ON public.audit_log (created);`,
+		Down: `
+DROP TABLE public.audit_log;`,
+	},
+	{
+		Version: 10,
+		Description: "revisions of features and toggle rules",
+		Up: `
+CREATE TABLE public.feature_revision (
+  featureId TEXT      NOT NULL,
+  revision  INTEGER   NOT NULL,
+  created   TIMESTAMP NOT NULL,
+  actor     TEXT      NOT NULL,
+  feature   JSONB     NOT NULL,
+  PRIMARY KEY (featureId, revision),
+  CONSTRAINT fk_feature
+  FOREIGN KEY (featureId)
+  REFERENCES public.feature (id)
+);
+
+CREATE TABLE public.toggle_rule_revision (
+  featureId   TEXT    NOT NULL,
+  revision    INTEGER NOT NULL,
+  ruleId      TEXT    NOT NULL,
+  position    INTEGER NOT NULL,
+  toggle_rule JSONB   NOT NULL,
+  PRIMARY KEY (featureId, revision, ruleId),
+  CONSTRAINT fk_feature_revision
+  FOREIGN KEY (featureId, revision)
+  REFERENCES public.feature_revision (featureId, revision)
+);`,
+		Down: `
+DROP TABLE public.toggle_rule_revision;
+DROP TABLE public.feature_revision;`,
+	},
+	{
+		Version: 11,
+		Description: "versions of features, properties and toggle rules",
+		Up: `
+ALTER TABLE public.feature ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE public.property ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE public.toggle_rule ADD COLUMN version BIGINT NOT NULL DEFAULT 1;`,
+		Down: `
+ALTER TABLE public.toggle_rule DROP COLUMN version;
+ALTER TABLE public.property DROP COLUMN version;
+ALTER TABLE public.feature DROP COLUMN version;`,
+	},
+	{
+		Version: 12,
+		Description: "unique api key names",
+		// The principal of a key is its name, a key sharing the name of an
+		// older key is renamed so it doesn't get the role of the older one.
+		Up: `
+UPDATE public.api_key SET name = name || '-' || id
+  WHERE EXISTS (SELECT 1 FROM public.api_key older WHERE older.name = api_key.name
+    AND (older.created, older.id) < (api_key.created, api_key.id));
+ALTER TABLE public.api_key ADD CONSTRAINT uk_api_key_name UNIQUE (name);`,
+		Down: `
+ALTER TABLE public.api_key DROP CONSTRAINT uk_api_key_name;`,
+	},
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"fmt"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	INSERT_API_KEY_SQL = "INSERT INTO api_key(id, name, hash, scope, created) values ($1,$2,$3,$4,$5)"
+	READ_API_KEY_SQL = "SELECT id, name, hash, scope, created FROM api_key WHERE id = $1"
+	READ_API_KEY_BY_HASH_SQL = "SELECT id, name, hash, scope, created FROM api_key WHERE hash = $1"
+	DELETE_API_KEY_SQL = "DELETE FROM api_key WHERE id = $1"
+	LIST_API_KEYS_SQL = "SELECT id, name, hash, scope, created FROM api_key ORDER BY name COLLATE \"C\", id"
+)
+
+func (fs *FeatureToggleStoreImpl) CreateApiKey(apiKey ApiKey) (*string, error) {
+	id := apiKey.Id
+	if strings.Compare(id, "") == 0 {
+		id = uuid.NewV4().String()
+	}
+
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("CreateApiKey: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	key := apiKey
+	key.Id = id
+	key.Created = time.Now().UTC()
+	_, err = tx.Exec(INSERT_API_KEY_SQL, key.Id, key.Name, key.Hash, key.Scope, key.Created)
+	if ( err != nil) {
+		return nil, sqlError(err, KindApiKey, apiKey.Name, errors.New(fmt.Sprintf("CreateApiKey: Failed to insert api key '%s', %v", apiKey.Name, err)))
+	}
+	err = fs.record(tx, KindApiKey, id, OperationCreate, nil, key)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("CreateApiKey: Failed to commit, %v", err))
+	}
+
+	return &id, nil
+}
+
+func (fs *FeatureToggleStoreImpl) ReadApiKeyByHash(hash string) (*ApiKey, error) {
+	rows, err := fs.db.Query(READ_API_KEY_BY_HASH_SQL, hash)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("ReadApiKeyByHash: Failed to select, %v", err))
+	}
+	defer rows.Close()
+	keys, err := rowsToApiKey(rows)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ReadApiKeyByHash: Failed to get row data, %v", err))
+	}
+	if len(keys) > 0 {
+		return &keys[0], nil
+	}
+	return nil, nil
+}
+
+func (fs *FeatureToggleStoreImpl) DeleteApiKey(id string) (*bool, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteApiKey: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(READ_API_KEY_SQL + LOCK_PART_SQL, id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteApiKey: Failed to select '%s', %v", id, err))
+	}
+	keys, err := rowsToApiKey(rows)
+	rows.Close()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("DeleteApiKey: Failed to get row data, %v", err))
+	}
+	b := len(keys) > 0
+	if !b {
+		return &b, nil
+	}
+	_, err = tx.Exec(DELETE_API_KEY_SQL, id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteApiKey: Failed to delete '%s', %v", id, err))
+	}
+	err = fs.record(tx, KindApiKey, id, OperationDelete, keys[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("DeleteApiKey: Failed to commit, %v", err))
+	}
+	return &b, nil
+}
+
+func (fs *FeatureToggleStoreImpl) ListApiKeys() (*[]ApiKey, error) {
+	rows, err := fs.db.Query(LIST_API_KEYS_SQL)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("ListApiKeys: Failed to select, %v", err))
+	}
+	defer rows.Close()
+	keys, err := rowsToApiKey(rows)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ListApiKeys: Failed to get row data, %v", err))
+	}
+	return &keys, nil
+}
+
+func rowsToApiKey(rows *sql.Rows) ([]ApiKey, error) {
+	keys := []ApiKey{}
+	for rows.Next() {
+		var key ApiKey
+		err := rows.Scan(&key.Id, &key.Name, &key.Hash, &key.Scope, &key.Created)
+		if ( err != nil) {
+			return nil, errors.New(fmt.Sprintf("ApiKey: Failed to scan row, %v", err))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
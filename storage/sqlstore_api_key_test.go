@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"testing"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureToggleStoreImpl_CreateApiKey(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	key := ApiKey{Name:randomSufix("Key-"), Hash:randomSufix("hash-"), Scope:"evaluate"}
+	id, err := fs.CreateApiKey(key)
+	require.NotNil(t, id, "Should get api key id, %v", err)
+	defer fs.DeleteApiKey(*id)
+
+	k, err := fs.ReadApiKeyByHash(key.Hash)
+	require.NotNil(t, k, "Should get api key by hash, %v", err)
+	assert.Equal(t, *id, k.Id)
+	assert.Equal(t, key.Name, k.Name)
+	assert.Equal(t, "evaluate", k.Scope)
+
+	id2, err := fs.CreateApiKey(ApiKey{Name:randomSufix("Key-"), Hash:key.Hash, Scope:"admin"})
+	assert.Nil(t, id2, "Should not create two keys with the same hash")
+	assert.IsType(t, &AlreadyExistsError{}, err)
+
+	id2, err = fs.CreateApiKey(ApiKey{Name:key.Name, Hash:randomSufix("hash-"), Scope:"admin"})
+	assert.Nil(t, id2, "Should not create two keys with the same name")
+	assert.IsType(t, &AlreadyExistsError{}, err)
+}
+
+func TestFeatureToggleStoreImpl_DeleteApiKey(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	key := ApiKey{Name:randomSufix("Key-"), Hash:randomSufix("hash-"), Scope:"admin"}
+	id, err := fs.CreateApiKey(key)
+	require.NotNil(t, id, "Should get api key id, %v", err)
+
+	keys, err := fs.ListApiKeys()
+	require.NotNil(t, keys, "Should list api keys, %v", err)
+	found := false
+	for _, k := range *keys {
+		found = found || k.Id == *id
+	}
+	assert.True(t, found, "Should list the created key")
+
+	b, err := fs.DeleteApiKey(*id)
+	require.NotNil(t, b, "Should delete api key, %v", err)
+	assert.True(t, *b)
+
+	k, _ := fs.ReadApiKeyByHash(key.Hash)
+	assert.Nil(t, k, "Should not read a deleted key")
+}
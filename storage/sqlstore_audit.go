@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	INSERT_AUDIT_ENTRY_SQL = "INSERT INTO audit_log(id, actor, created, kind, entityId, operation, before, after) values ($1,$2,$3,$4,$5,$6,$7,$8)"
+
+	SEARCH_AUDIT_LOG_SELECT_PART_SQL = "SELECT id, actor, created, kind, entityId, operation, before, after FROM audit_log "
+	SEARCH_AUDIT_LOG_KIND_PART_SQL = "kind = $%d "
+	SEARCH_AUDIT_LOG_ENTITY_ID_PART_SQL = "entityId = $%d "
+	SEARCH_AUDIT_LOG_ACTOR_PART_SQL = "actor = $%d "
+	SEARCH_AUDIT_LOG_START_PART_SQL = "created >= $%d "
+	SEARCH_AUDIT_LOG_END_PART_SQL = "created <= $%d "
+	SEARCH_AUDIT_LOG_ORDER_PART_SQL = "ORDER BY created DESC, id"
+	SEARCH_AUDIT_LOG_LIMIT_PART_SQL = " LIMIT $%d"
+)
+
+// record inserts the entry of a change by the actor of the store, q must be
+// the transaction making the change so both are committed or neither.
+func (fs *FeatureToggleStoreImpl) record(q queryer, kind string, entityId string, operation string, before interface{}, after interface{}) error {
+	entry, err := newAuditEntry(fs.actor, kind, entityId, operation, before, after)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(INSERT_AUDIT_ENTRY_SQL, entry.Id, entry.Actor, entry.Time.UTC(), entry.Kind, entry.EntityId, entry.Operation,
+		nullString(entry.Before), nullString(entry.After))
+	if ( err != nil) {
+		return errors.New(fmt.Sprintf("Failed to insert audit entry of %s '%s', %v", kind, entityId, err))
+	}
+	return nil
+}
+
+func (fs *FeatureToggleStoreImpl) SearchAuditLog(search AuditSearch) (*[]AuditEntry, error) {
+	searchQuery, params := auditLogSearchQuery(search)
+
+	rows, err := fs.db.Query(searchQuery, params...)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("SearchAuditLog: Failed to run query, %v", err))
+	}
+	defer rows.Close()
+	entries, err := rowsToAuditEntry(rows)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("SearchAuditLog: Failed to get row data, %v", err))
+	}
+	return &entries, nil
+}
+
+// auditLogSearchQuery returns the query selecting the entries matching the
+// search, newest first, and its parameters.
+func auditLogSearchQuery(search AuditSearch) (string, []interface{}) {
+	var buffer bytes.Buffer
+	params := make([]interface{}, 0)
+	conditions := []string{}
+	add := func(part string, value interface{}) {
+		params = append(params, value)
+		conditions = append(conditions, fmt.Sprintf(part, len(params)))
+	}
+
+	buffer.WriteString(SEARCH_AUDIT_LOG_SELECT_PART_SQL)
+	if search.Kind != "" {
+		add(SEARCH_AUDIT_LOG_KIND_PART_SQL, search.Kind)
+	}
+	if search.EntityId != "" {
+		add(SEARCH_AUDIT_LOG_ENTITY_ID_PART_SQL, search.EntityId)
+	}
+	if search.Actor != "" {
+		add(SEARCH_AUDIT_LOG_ACTOR_PART_SQL, search.Actor)
+	}
+	if !search.Start.IsZero() {
+		add(SEARCH_AUDIT_LOG_START_PART_SQL, search.Start.UTC())
+	}
+	if !search.End.IsZero() {
+		add(SEARCH_AUDIT_LOG_END_PART_SQL, search.End.UTC())
+	}
+
+	if len(conditions) > 0 {
+		buffer.WriteString("WHERE ")
+		buffer.WriteString(strings.Join(conditions, "AND "))
+	}
+	buffer.WriteString(SEARCH_AUDIT_LOG_ORDER_PART_SQL)
+	if search.Limit > 0 {
+		params = append(params, search.Limit)
+		buffer.WriteString(fmt.Sprintf(SEARCH_AUDIT_LOG_LIMIT_PART_SQL, len(params)))
+	}
+	return buffer.String(), params
+}
+
+func rowsToAuditEntry(rows *sql.Rows) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var before sql.NullString
+		var after sql.NullString
+		err := rows.Scan(&entry.Id, &entry.Actor, &entry.Time, &entry.Kind, &entry.EntityId, &entry.Operation, &before, &after)
+		if ( err != nil) {
+			return nil, errors.New(fmt.Sprintf("AuditEntry: Failed to scan row, %v", err))
+		}
+		entry.Before = before.String
+		entry.After = after.String
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// nullString stores the empty string as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String:s, Valid:s != ""}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"fmt"
+	"time"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureToggleStoreImpl_SearchAuditLog(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	actor := randomSufix("jwt:user-")
+	store := fs.WithActor(actor)
+	featureId, err := store.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	require.NotNil(t, featureId, "Should get feature id, %v", err)
+	_, err = store.UpdateFeature(Feature{Id:*featureId, Description:"new description", Version:1}, []string{"description"})
+	require.Nil(t, err, "Should update feature, %v", err)
+	_, err = store.CreateFeature(Feature{Id:*featureId})
+	require.NotNil(t, err, "Should fail to create a feature twice")
+
+	entries, err := fs.SearchAuditLog(AuditSearch{Actor:actor})
+	require.NotNil(t, entries, "Should search audit log, %v", err)
+	require.Len(t, *entries, 2, "Should not record failed changes")
+	update := (*entries)[0]
+	assert.Equal(t, OperationUpdate, update.Operation, "Should return the newest entry first")
+	assert.Equal(t, KindFeature, update.Kind)
+	assert.Equal(t, *featureId, update.EntityId)
+	var before, after Feature
+	require.Nil(t, json.Unmarshal([]byte(update.Before), &before))
+	require.Nil(t, json.Unmarshal([]byte(update.After), &after))
+	assert.Equal(t, "f description", before.Description)
+	assert.Equal(t, "new description", after.Description)
+	assert.Equal(t, "", (*entries)[1].Before, "A create has no before")
+
+	entries, _ = fs.SearchAuditLog(AuditSearch{Kind:KindFeature, EntityId:*featureId, Limit:1})
+	assert.Len(t, *entries, 1, "Should limit the entries")
+
+	deleted := time.Now()
+	b, err := store.DeleteFeature(*featureId, 2)
+	require.NotNil(t, b, "Should delete feature, %v", err)
+	entries, _ = fs.SearchAuditLog(AuditSearch{Actor:actor, Start:deleted})
+	require.Len(t, *entries, 1, "Should filter by time")
+	assert.Equal(t, OperationDelete, (*entries)[0].Operation)
+	assert.Equal(t, "", (*entries)[0].After, "A delete has no after")
+}
@@ -4,25 +4,64 @@ import (
 	"fmt"
 	"database/sql"
 	"errors"
+
+	"github.com/lib/pq"
 )
 
 const (
-	INSERT_FEATURE_SQL = "INSERT INTO feature(id, name, enabled, description) values ($1,$2,$3,$4)"
-	READ_FEATURE_SQL = "SELECT * FROM feature WHERE id = $1"
-	READ_FEATURE_BY_NAME_SQL = "SELECT * FROM feature WHERE name = $1"
+	INSERT_FEATURE_SQL = "INSERT INTO feature(id, name, enabled, description, default_variant, starts, expires, version) values ($1,$2,$3,$4,$5,$6,$7,$8)"
+	READ_FEATURE_SQL = "SELECT id, name, enabled, description, default_variant, starts, expires, version FROM feature WHERE id = $1"
+	READ_FEATURE_BY_NAME_SQL = "SELECT id, name, enabled, description, default_variant, starts, expires, version FROM feature WHERE name = $1"
 	DELETE_FEATURE_SQL = "DELETE FROM feature WHERE id = $1"
+	SEARCH_FEATURE_SQL = "SELECT id, name, enabled, description, default_variant, starts, expires, version FROM feature WHERE strpos(lower(name), lower($1)) > 0 AND ($2::boolean IS NULL OR enabled = $2) ORDER BY name COLLATE \"C\""
+	FEATURE_TOGGLE_RULE_SQL = "SELECT id FROM toggle_rule WHERE featureid = $1 LIMIT 1"
+	UPDATE_FEATURE_SQL = "UPDATE feature SET name = $2, enabled = $3, description = $4, default_variant = $5, starts = $6, expires = $7, version = $8 WHERE id = $1"
+	FEATURE_RULE_VARIANTS_SQL = "SELECT DISTINCT v.ruleid, v.variant FROM toggle_rule_variant v JOIN toggle_rule ON toggle_rule.id = v.ruleid WHERE toggle_rule.featureid = $1"
 
 )
 func (fs *FeatureToggleStoreImpl) CreateFeature(feature Feature) (*string, error) {
-	stmt, err := fs.db.Prepare(INSERT_FEATURE_SQL)
+	err := validateSchedule(feature.Starts, feature.Expires)
+	if err != nil {
+		return nil, err
+	}
+	err = validateVariants(feature)
+	if err != nil {
+		return nil, err
+	}
+
+	feature.Version = 1
+
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(INSERT_FEATURE_SQL)
 	if ( err != nil) {
 		return nil, errors.New(fmt.Sprintf("Failed to create prepared statement, %v", err))
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(feature.Id, feature.Name, feature.Enabled, feature.Description)
+	_, err = stmt.Exec(feature.Id, feature.Name, feature.Enabled, feature.Description, feature.DefaultVariant, nullTime(feature.Starts), nullTime(feature.Expires), feature.Version)
 	if ( err != nil) {
-		return nil, errors.New(fmt.Sprintf("Failed to insert property '%s', %v", feature.Name, err))
+		return nil, sqlError(err, KindFeature, feature.Name, errors.New(fmt.Sprintf("Failed to insert feature '%s', %v", feature.Name, err)))
+	}
+	err = insertFeatureVariants(tx, feature.Id, feature.Variants)
+	if err != nil {
+		return nil, err
+	}
+	err = fs.record(tx, KindFeature, feature.Id, OperationCreate, nil, feature)
+	if err != nil {
+		return nil, err
+	}
+	_, err = fs.addRevision(tx, feature.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to commit feature '%s', %v", feature.Name, err))
 	}
 
 	return &feature.Id, nil
@@ -39,12 +78,13 @@ func (fs *FeatureToggleStoreImpl) ReadFeature(id string) (*Feature, error) {
 	if ( err != nil) {
 		return nil, errors.New(fmt.Sprintf("ReadFeature: Failed to select '%s', %v", id, err))
 	}
+	defer rows.Close()
 	features, err := rowsToFeature(rows)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("Failed to get row data, %v", err))
 	}
 	if len(features) > 0 {
-		return &features[0], nil
+		return fs.withVariants(&features[0])
 	}
 	return nil, nil
 }
@@ -60,18 +100,59 @@ func (fs *FeatureToggleStoreImpl) ReadFeatureByName(name string) (*Feature, erro
 	if ( err != nil) {
 		return nil, errors.New(fmt.Sprintf("ReadFeatureByName: Failed to select '%s', %v", name, err))
 	}
+	defer rows.Close()
 	features, err := rowsToFeature(rows)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("ReadFeatureByName: Failed to get row data, %v", err))
 	}
 	if len(features) > 0 {
-		return &features[0], nil
+		return fs.withVariants(&features[0])
 	}
 	return nil, nil
 }
 
-func (fs *FeatureToggleStoreImpl) DeleteFeature(id string) (*bool, error) {
-	stmt, err := fs.db.Prepare(DELETE_FEATURE_SQL)
+func (fs *FeatureToggleStoreImpl) DeleteFeature(id string, version int64) (*bool, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteFeature: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	feature, err := queryFeature(tx, READ_FEATURE_SQL + LOCK_PART_SQL, id)
+	if err != nil {
+		return nil, err
+	}
+	if feature == nil {
+		b := false
+		return &b, nil
+	}
+	err = checkVersion(KindFeature, id, version, feature.Version)
+	if err != nil {
+		return nil, err
+	}
+	err = refusedByToggleRule(tx, FEATURE_TOGGLE_RULE_SQL, "DeleteFeature", KindFeature, id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(DELETE_FEATURE_VARIANTS_SQL, id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteFeature: Failed to delete variants of '%s', %v", id, err))
+	}
+	_, err = tx.Exec(DELETE_FEATURE_OWNERS_SQL, id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteFeature: Failed to delete owners of '%s', %v", id, err))
+	}
+	_, err = tx.Exec(DELETE_TOGGLE_RULE_REVISIONS_SQL, id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteFeature: Failed to delete rule revisions of '%s', %v", id, err))
+	}
+	_, err = tx.Exec(DELETE_FEATURE_REVISIONS_SQL, id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteFeature: Failed to delete revisions of '%s', %v", id, err))
+	}
+
+	stmt, err := tx.Prepare(DELETE_FEATURE_SQL)
 	if ( err != nil) {
 		return nil, errors.New(fmt.Sprintf("DeleteFeature: Failed to create prepared statement, %v", err))
 	}
@@ -86,12 +167,174 @@ func (fs *FeatureToggleStoreImpl) DeleteFeature(id string) (*bool, error) {
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("DeleteFeature: Failed to get rowsAffected, %v", err))
 	}
+	err = fs.record(tx, KindFeature, id, OperationDelete, feature, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("DeleteFeature: Failed to commit, %v", err))
+	}
 	b := rowCount > 0
 	return &b, nil
 }
 
-func (fs *FeatureToggleStoreImpl) SearchFeature(name string) (*([]Feature), error) {
-	return nil, errors.New("Not implemented")
+func (fs *FeatureToggleStoreImpl) withVariants(feature *Feature) (*Feature, error) {
+	variants, err := readFeatureVariants(fs.db, feature.Id)
+	if err != nil {
+		return nil, err
+	}
+	feature.Variants = variants
+	return feature, nil
+}
+
+func (fs *FeatureToggleStoreImpl) SearchFeature(search FeatureSearch) (*([]Feature), error) {
+	rows, err := fs.db.Query(SEARCH_FEATURE_SQL, search.Name, search.Enabled)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("SearchFeature: Failed to select '%s', %v", search.Name, err))
+	}
+	defer rows.Close()
+	features, err := rowsToFeature(rows)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("SearchFeature: Failed to get row data, %v", err))
+	}
+	for i := range features {
+		_, err = fs.withVariants(&features[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &features, nil
+}
+
+// UpdateFeature locks the feature row, the variants are rewritten if they
+// are updated. Variants chosen by toggle rules can't be removed.
+func (fs *FeatureToggleStoreImpl) UpdateFeature(update Feature, paths []string) (*Feature, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("UpdateFeature: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	feature, err := queryFeature(tx, READ_FEATURE_SQL + LOCK_PART_SQL, update.Id)
+	if err != nil || feature == nil {
+		return nil, err
+	}
+	err = checkVersion(KindFeature, feature.Id, update.Version, feature.Version)
+	if err != nil {
+		return nil, err
+	}
+	before := *feature
+	err = applyFeatureUpdate(feature, update, paths)
+	if err != nil {
+		return nil, err
+	}
+	feature.Version++
+	other, err := queryFeature(tx, READ_FEATURE_BY_NAME_SQL, feature.Name)
+	if err != nil {
+		return nil, err
+	}
+	if other != nil && other.Id != feature.Id {
+		return nil, &AlreadyExistsError{Kind:KindFeature, Key:feature.Name}
+	}
+	err = checkRuleVariants(tx, *feature)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(UPDATE_FEATURE_SQL, feature.Id, feature.Name, feature.Enabled, feature.Description, feature.DefaultVariant, nullTime(feature.Starts), nullTime(feature.Expires), feature.Version)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("UpdateFeature: Failed to update '%s', %v", feature.Id, err))
+	}
+	_, err = tx.Exec(DELETE_FEATURE_VARIANTS_SQL, feature.Id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("UpdateFeature: Failed to delete variants of '%s', %v", feature.Id, err))
+	}
+	err = insertFeatureVariants(tx, feature.Id, feature.Variants)
+	if err != nil {
+		return nil, err
+	}
+	err = fs.record(tx, KindFeature, feature.Id, OperationUpdate, before, feature)
+	if err != nil {
+		return nil, err
+	}
+	_, err = fs.addRevision(tx, feature.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("UpdateFeature: Failed to commit, %v", err))
+	}
+	return feature, nil
+}
+
+// queryFeature returns the feature with its variants selected by query with
+// key, nil if there is none.
+func queryFeature(q queryer, query string, key string) (*Feature, error) {
+	rows, err := q.Query(query, key)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to select feature '%s', %v", key, err))
+	}
+	features, err := rowsToFeature(rows)
+	rows.Close()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to get row data, %v", err))
+	}
+	if len(features) == 0 {
+		return nil, nil
+	}
+	features[0].Variants, err = readFeatureVariants(q, features[0].Id)
+	if err != nil {
+		return nil, err
+	}
+	return &features[0], nil
+}
+
+// checkRuleVariants returns an error if a toggle rule of the feature chooses
+// a variant the feature doesn't have.
+func checkRuleVariants(q queryer, feature Feature) error {
+	rows, err := q.Query(FEATURE_RULE_VARIANTS_SQL, feature.Id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Failed to select rule variants of '%s', %v", feature.Id, err))
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for _, v := range feature.Variants {
+		names[v.Name] = true
+	}
+	for rows.Next() {
+		var ruleId string
+		var variant string
+		err = rows.Scan(&ruleId, &variant)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Rule variant: Failed to scan row, %v", err))
+		}
+		if !names[variant] {
+			return &ReferencedError{Kind:KindVariant, Key:variant, ByKind:KindToggleRule, ByKey:ruleId}
+		}
+	}
+	return rows.Err()
+}
+
+// refusedByToggleRule returns a ReferencedError naming a toggle rule found by
+// query, which selects the rules referring to the kind about to be deleted.
+func refusedByToggleRule(q queryer, query string, operation string, kind string, key string) error {
+	rows, err := q.Query(query, key)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s: Failed to select toggle rules of '%s', %v", operation, key, err))
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var ruleId string
+		err = rows.Scan(&ruleId)
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s: Failed to scan row, %v", operation, err))
+		}
+		return &ReferencedError{Kind:kind, Key:key, ByKind:KindToggleRule, ByKey:ruleId}
+	}
+	return rows.Err()
 }
 
 func rowsToFeature(rows *sql.Rows) ([]Feature, error) {
@@ -101,11 +344,16 @@ func rowsToFeature(rows *sql.Rows) ([]Feature, error) {
 		var name string
 		var description string
 		var enabled bool
-		err := rows.Scan(&id, &name, &enabled, &description)
+		var defaultVariant string
+		var starts pq.NullTime
+		var expires pq.NullTime
+		var version int64
+		err := rows.Scan(&id, &name, &enabled, &description, &defaultVariant, &starts, &expires, &version)
 		if ( err != nil) {
 			return nil, errors.New(fmt.Sprintf("Feature: Failed to scan row, %v", err))
 		}
-		feature := Feature{id, name, enabled, description}
+		feature := Feature{Id:id, Name:name, Enabled:enabled, Description:description, DefaultVariant:defaultVariant,
+			Starts:fromNullTime(starts), Expires:fromNullTime(expires), Version:version}
 		features = append(features, feature)
 	}
 	return features, nil
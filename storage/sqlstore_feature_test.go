@@ -3,12 +3,13 @@ package storage
 import (
 	"testing"
 	"fmt"
+	"strings"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestFeatureToggleStoreImpl_CreateFeature(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -23,7 +24,7 @@ func TestFeatureToggleStoreImpl_CreateFeature(t *testing.T) {
 }
 
 func TestFeatureToggleStoreImpl_ReadFeature(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -44,7 +45,7 @@ func TestFeatureToggleStoreImpl_ReadFeature(t *testing.T) {
 }
 
 func TestFeatureToggleStoreImpl_ReadFeatureByName(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -66,7 +67,7 @@ func TestFeatureToggleStoreImpl_ReadFeatureByName(t *testing.T) {
 }
 
 func TestFeatureToggleStoreImpl_ReadFeatureByName__unknown(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -80,7 +81,7 @@ func TestFeatureToggleStoreImpl_ReadFeatureByName__unknown(t *testing.T) {
 }
 
 func TestFeatureToggleStoreImpl_DeleteFeature(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -93,9 +94,66 @@ func TestFeatureToggleStoreImpl_DeleteFeature(t *testing.T) {
 
 	require.NotNil(t, featureId, "Should get featureId, %v", err)
 
-	res,err := fs.DeleteFeature(*featureId)
+	res,err := fs.DeleteFeature(*featureId, 1)
 
 	require.True(t, *res, "Should get true from delete operation for featureId %s, %v", featureId, err)
 }
 
 
+
+func TestFeatureToggleStoreImpl_SearchFeature(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	suffix := randomSufix("")
+	fs.CreateFeature(*NewFeature("Search-B-" + suffix, true, ""))
+	fs.CreateFeature(*NewFeature("search-a-" + suffix, false, ""))
+
+	features, err := fs.SearchFeature(FeatureSearch{Name: "SEARCH-%-" + suffix})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	assert.Equal(t, 0, len(*features), "Should not treat % as a wildcard")
+
+	features, err = fs.SearchFeature(FeatureSearch{Name: "-" + suffix})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.Equal(t, 2, len(*features), "Should match part of the name")
+	assert.Equal(t, "Search-B-" + suffix, (*features)[0].Name, "Should be ordered by name, upper case first like the memstore")
+
+	features, err = fs.SearchFeature(FeatureSearch{Name: strings.ToUpper("search-a-" + suffix)})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	assert.Equal(t, 1, len(*features), "Should ignore case")
+
+	enabled := false
+	features, err = fs.SearchFeature(FeatureSearch{Name: suffix, Enabled: &enabled})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.Equal(t, 1, len(*features))
+	assert.Equal(t, "search-a-" + suffix, (*features)[0].Name)
+}
+
+func TestFeatureToggleStoreImpl_DeleteFeature__referenced(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	propertyName, _ := fs.CreateProperty(*NewProperty(randomSufix("Prop-"), "p description"))
+	featureId, _ := fs.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	ruleId, err := fs.CreateToggleRule(*NewToggleRule(*featureId, true, *propertyName, "adam"))
+	require.NotNil(t, ruleId, "Should get ruleId, %v", err)
+
+	res, err := fs.DeleteFeature(*featureId, 1)
+	assert.Nil(t, res, "Should not delete a feature referenced by a toggle rule")
+	require.NotNil(t, err, "Should get an error when the feature is referenced by a toggle rule")
+	assert.Contains(t, err.Error(), *ruleId, "Should name the toggle rule")
+
+	res, err = fs.DeleteProperty(*propertyName, 1)
+	assert.Nil(t, res, "Should not delete a property referenced by a toggle rule")
+	assert.NotNil(t, err, "Should get an error when the property is referenced by a toggle rule")
+}
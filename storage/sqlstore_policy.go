@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"fmt"
+	"database/sql"
+	"errors"
+)
+
+const (
+	UPSERT_ROLE_BINDING_SQL = "INSERT INTO role_binding(principal, role) values ($1,$2) ON CONFLICT (principal) DO UPDATE SET role = EXCLUDED.role"
+	READ_ROLE_BINDING_SQL = "SELECT principal, role FROM role_binding WHERE principal = $1"
+	DELETE_ROLE_BINDING_SQL = "DELETE FROM role_binding WHERE principal = $1"
+	LIST_ROLE_BINDINGS_SQL = "SELECT principal, role FROM role_binding ORDER BY principal COLLATE \"C\""
+	INSERT_FEATURE_OWNER_SQL = "INSERT INTO feature_owner(featureId, principal) values ($1,$2) ON CONFLICT DO NOTHING"
+	DELETE_FEATURE_OWNER_SQL = "DELETE FROM feature_owner WHERE featureId = $1 AND principal = $2"
+	DELETE_FEATURE_OWNERS_SQL = "DELETE FROM feature_owner WHERE featureId = $1"
+	READ_FEATURE_OWNERS_SQL = "SELECT principal FROM feature_owner WHERE featureId = $1 ORDER BY principal COLLATE \"C\""
+)
+
+func (fs *FeatureToggleStoreImpl) SetRoleBinding(binding RoleBinding) error {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return errors.New(fmt.Sprintf("SetRoleBinding: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	current, err := queryRoleBinding(tx, READ_ROLE_BINDING_SQL + LOCK_PART_SQL, binding.Principal)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(UPSERT_ROLE_BINDING_SQL, binding.Principal, binding.Role)
+	if ( err != nil) {
+		return errors.New(fmt.Sprintf("SetRoleBinding: Failed to set role of '%s', %v", binding.Principal, err))
+	}
+	if current == nil {
+		err = fs.record(tx, KindRoleBinding, binding.Principal, OperationCreate, nil, binding)
+	} else {
+		err = fs.record(tx, KindRoleBinding, binding.Principal, OperationUpdate, *current, binding)
+	}
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintf("SetRoleBinding: Failed to commit, %v", err))
+	}
+	return nil
+}
+
+func (fs *FeatureToggleStoreImpl) ReadRoleBinding(principal string) (*RoleBinding, error) {
+	return queryRoleBinding(fs.db, READ_ROLE_BINDING_SQL, principal)
+}
+
+func (fs *FeatureToggleStoreImpl) DeleteRoleBinding(principal string) (*bool, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteRoleBinding: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	binding, err := queryRoleBinding(tx, READ_ROLE_BINDING_SQL + LOCK_PART_SQL, principal)
+	if err != nil {
+		return nil, err
+	}
+	b := binding != nil
+	if !b {
+		return &b, nil
+	}
+	_, err = tx.Exec(DELETE_ROLE_BINDING_SQL, principal)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteRoleBinding: Failed to delete '%s', %v", principal, err))
+	}
+	err = fs.record(tx, KindRoleBinding, principal, OperationDelete, *binding, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("DeleteRoleBinding: Failed to commit, %v", err))
+	}
+	return &b, nil
+}
+
+// queryRoleBinding returns the binding of the principal selected by query,
+// nil if there is none.
+func queryRoleBinding(q queryer, query string, principal string) (*RoleBinding, error) {
+	rows, err := q.Query(query, principal)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to select role binding '%s', %v", principal, err))
+	}
+	defer rows.Close()
+	bindings, err := rowsToRoleBinding(rows)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to get row data, %v", err))
+	}
+	if len(bindings) > 0 {
+		return &bindings[0], nil
+	}
+	return nil, nil
+}
+
+func (fs *FeatureToggleStoreImpl) ListRoleBindings() (*[]RoleBinding, error) {
+	rows, err := fs.db.Query(LIST_ROLE_BINDINGS_SQL)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("ListRoleBindings: Failed to select, %v", err))
+	}
+	defer rows.Close()
+	bindings, err := rowsToRoleBinding(rows)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ListRoleBindings: Failed to get row data, %v", err))
+	}
+	return &bindings, nil
+}
+
+func (fs *FeatureToggleStoreImpl) AddFeatureOwner(owner FeatureOwner) error {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return errors.New(fmt.Sprintf("AddFeatureOwner: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(INSERT_FEATURE_OWNER_SQL, owner.FeatureId, owner.Principal)
+	switch {
+	case isForeignKeyViolation(err, "fk_feature"):
+		return &NotFoundError{Kind:KindFeature, Key:owner.FeatureId}
+	case err != nil:
+		return errors.New(fmt.Sprintf("AddFeatureOwner: Failed to add owner '%s', %v", owner.Principal, err))
+	}
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		return errors.New(fmt.Sprintf("AddFeatureOwner: Failed to get rowsAffected, %v", err))
+	}
+	if rowCount == 0 {
+		return nil
+	}
+	err = fs.record(tx, KindFeatureOwner, owner.FeatureId, OperationCreate, nil, owner)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintf("AddFeatureOwner: Failed to commit, %v", err))
+	}
+	return nil
+}
+
+func (fs *FeatureToggleStoreImpl) RemoveFeatureOwner(owner FeatureOwner) (*bool, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("RemoveFeatureOwner: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(DELETE_FEATURE_OWNER_SQL, owner.FeatureId, owner.Principal)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("RemoveFeatureOwner: Failed to remove owner '%s', %v", owner.Principal, err))
+	}
+	rowCount, err := res.RowsAffected()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("RemoveFeatureOwner: Failed to get rowsAffected, %v", err))
+	}
+	b := rowCount > 0
+	if !b {
+		return &b, nil
+	}
+	err = fs.record(tx, KindFeatureOwner, owner.FeatureId, OperationDelete, owner, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("RemoveFeatureOwner: Failed to commit, %v", err))
+	}
+	return &b, nil
+}
+
+func (fs *FeatureToggleStoreImpl) ReadFeatureOwners(featureId string) (*[]string, error) {
+	rows, err := fs.db.Query(READ_FEATURE_OWNERS_SQL, featureId)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("ReadFeatureOwners: Failed to select '%s', %v", featureId, err))
+	}
+	defer rows.Close()
+	owners := []string{}
+	for rows.Next() {
+		var principal string
+		err := rows.Scan(&principal)
+		if ( err != nil) {
+			return nil, errors.New(fmt.Sprintf("ReadFeatureOwners: Failed to scan row, %v", err))
+		}
+		owners = append(owners, principal)
+	}
+	return &owners, nil
+}
+
+func rowsToRoleBinding(rows *sql.Rows) ([]RoleBinding, error) {
+	bindings := []RoleBinding{}
+	for rows.Next() {
+		var binding RoleBinding
+		err := rows.Scan(&binding.Principal, &binding.Role)
+		if ( err != nil) {
+			return nil, errors.New(fmt.Sprintf("RoleBinding: Failed to scan row, %v", err))
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings, nil
+}
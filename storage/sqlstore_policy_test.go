@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"testing"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureToggleStoreImpl_SetRoleBinding(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	principal := randomSufix("jwt:user-")
+	require.Nil(t, fs.SetRoleBinding(RoleBinding{Principal:principal, Role:"viewer"}))
+	defer fs.DeleteRoleBinding(principal)
+	require.Nil(t, fs.SetRoleBinding(RoleBinding{Principal:principal, Role:"editor"}))
+
+	binding, err := fs.ReadRoleBinding(principal)
+	require.NotNil(t, binding, "Should get role binding, %v", err)
+	assert.Equal(t, "editor", binding.Role, "Should replace the role")
+
+	bindings, err := fs.ListRoleBindings()
+	require.NotNil(t, bindings, "Should list role bindings, %v", err)
+	assert.Contains(t, *bindings, RoleBinding{Principal:principal, Role:"editor"})
+}
+
+func TestFeatureToggleStoreImpl_FeatureOwners(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	featureId, err := fs.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	require.NotNil(t, featureId, "Should get feature id, %v", err)
+	require.Nil(t, fs.AddFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:eve"}))
+	require.Nil(t, fs.AddFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:adam"}))
+	require.Nil(t, fs.AddFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:adam"}), "Adding an owner twice should be a no-op")
+
+	owners, err := fs.ReadFeatureOwners(*featureId)
+	require.NotNil(t, owners, "Should get owners, %v", err)
+	assert.Equal(t, []string{"jwt:adam", "jwt:eve"}, *owners)
+
+	b, _ := fs.RemoveFeatureOwner(FeatureOwner{FeatureId:*featureId, Principal:"jwt:eve"})
+	assert.True(t, *b)
+
+	err = fs.AddFeatureOwner(FeatureOwner{FeatureId:"unknown", Principal:"jwt:adam"})
+	assert.IsType(t, &NotFoundError{}, err, "Should refuse owners of unknown features")
+
+	b, err = fs.DeleteFeature(*featureId, 1)
+	require.NotNil(t, b, "Should delete a feature with owners, %v", err)
+	owners, _ = fs.ReadFeatureOwners(*featureId)
+	assert.Len(t, *owners, 0, "Should remove the owners with the feature")
+}
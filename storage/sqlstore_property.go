@@ -7,22 +7,35 @@ import (
 )
 
 const (
-	INSERT_PROPERTY_SQL = "INSERT INTO property(name, description) values ($1,$2)"
-	READ_PROPERTY_SQL = "SELECT * FROM property WHERE name = $1"
+	INSERT_PROPERTY_SQL = "INSERT INTO property(name, description, version) values ($1,$2,$3)"
+	READ_PROPERTY_SQL = "SELECT name, description, version FROM property WHERE name = $1"
 	DELETE_PROPERTY_SQL = "DELETE FROM property WHERE name = $1"
 	READ_ALL_PROPERTY_NAMES_SQL = "SELECT name FROM property"
+	SEARCH_PROPERTY_SQL = "SELECT name, description, version FROM property WHERE strpos(lower(name), lower($1)) > 0 ORDER BY name COLLATE \"C\""
+	UPDATE_PROPERTY_SQL = "UPDATE property SET description = $2, version = $3 WHERE name = $1"
+	PROPERTY_TOGGLE_RULE_SQL = "SELECT id FROM toggle_rule WHERE property = $1 LIMIT 1"
 )
 
 func (fs *FeatureToggleStoreImpl) CreateProperty(property Property) (*string, error) {
-	stmt, err := fs.db.Prepare(INSERT_PROPERTY_SQL)
+	property.Version = 1
+
+	tx, err := fs.db.Begin()
 	if ( err != nil) {
-		return nil, errors.New(fmt.Sprintf("CreateProperty: Failed to create prepared statement, %v", err))
+		return nil, errors.New(fmt.Sprintf("CreateProperty: Failed to create trasaction, %v", err))
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	_, err = stmt.Exec(property.Name, property.Description)
+	_, err = tx.Exec(INSERT_PROPERTY_SQL, property.Name, property.Description, property.Version)
 	if ( err != nil) {
-		return nil, errors.New(fmt.Sprintf("CreateProperty: Failed to insert property '%s', %v", property.Name, err))
+		return nil, sqlError(err, KindProperty, property.Name, errors.New(fmt.Sprintf("CreateProperty: Failed to insert property '%s', %v", property.Name, err)))
+	}
+	err = fs.record(tx, KindProperty, property.Name, OperationCreate, nil, property)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("CreateProperty: Failed to commit, %v", err))
 	}
 
 	return &property.Name, nil
@@ -61,8 +74,36 @@ func (fs *FeatureToggleStoreImpl) ReadAllPropertyNames() (*[]string, error) {
 	return &names, nil
 }
 
-func (fs *FeatureToggleStoreImpl) DeleteProperty(name string) (*bool, error) {
-	stmt, err := fs.db.Prepare(DELETE_PROPERTY_SQL)
+func (fs *FeatureToggleStoreImpl) DeleteProperty(name string, version int64) (*bool, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteProperty: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(READ_PROPERTY_SQL + LOCK_PART_SQL, name)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("DeleteProperty: Failed to select '%s', %v", name, err))
+	}
+	properties, err := rowsToProperty(rows)
+	rows.Close()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("DeleteProperty: Failed to get row data, %v", err))
+	}
+	if len(properties) == 0 {
+		b := false
+		return &b, nil
+	}
+	err = checkVersion(KindProperty, name, version, properties[0].Version)
+	if err != nil {
+		return nil, err
+	}
+	err = refusedByToggleRule(tx, PROPERTY_TOGGLE_RULE_SQL, "DeleteProperty", KindProperty, name)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare(DELETE_PROPERTY_SQL)
 	if ( err != nil) {
 		return nil, errors.New(fmt.Sprintf("DeleteProperty: Failed to create prepared statement, %v", err))
 	}
@@ -77,12 +118,74 @@ func (fs *FeatureToggleStoreImpl) DeleteProperty(name string) (*bool, error) {
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("DeleteProperty: Failed to get rowsAffected, %v", err))
 	}
+	err = fs.record(tx, KindProperty, name, OperationDelete, properties[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("DeleteProperty: Failed to commit, %v", err))
+	}
 	b := rowCount > 0
 	return &b, nil
 }
 
 func (fs *FeatureToggleStoreImpl) SearchProperty(name string) (*[]Property, error) {
-	return nil, errors.New("Not implemented")
+	rows, err := fs.db.Query(SEARCH_PROPERTY_SQL, name)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("SearchProperty: Failed to select '%s', %v", name, err))
+	}
+	defer rows.Close()
+	properties, err := rowsToProperty(rows)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("SearchProperty: Failed to get row data, %v", err))
+	}
+	return &properties, nil
+}
+
+func (fs *FeatureToggleStoreImpl) UpdateProperty(update Property, paths []string) (*Property, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("UpdateProperty: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(READ_PROPERTY_SQL + LOCK_PART_SQL, update.Name)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("UpdateProperty: Failed to select '%s', %v", update.Name, err))
+	}
+	properties, err := rowsToProperty(rows)
+	rows.Close()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("UpdateProperty: Failed to get row data, %v", err))
+	}
+	if len(properties) == 0 {
+		return nil, nil
+	}
+	property := properties[0]
+	err = checkVersion(KindProperty, property.Name, update.Version, property.Version)
+	if err != nil {
+		return nil, err
+	}
+	before := property
+	err = applyPropertyUpdate(&property, update, paths)
+	if err != nil {
+		return nil, err
+	}
+	property.Version++
+	_, err = tx.Exec(UPDATE_PROPERTY_SQL, property.Name, property.Description, property.Version)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("UpdateProperty: Failed to update '%s', %v", property.Name, err))
+	}
+	err = fs.record(tx, KindProperty, property.Name, OperationUpdate, before, property)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("UpdateProperty: Failed to commit, %v", err))
+	}
+	return &property, nil
 }
 
 func rowsToProperty(rows *sql.Rows) ([]Property, error) {
@@ -90,11 +193,12 @@ func rowsToProperty(rows *sql.Rows) ([]Property, error) {
 	for rows.Next() {
 		var name string
 		var description string
-		err := rows.Scan(&name, &description)
+		var version int64
+		err := rows.Scan(&name, &description, &version)
 		if ( err != nil) {
 			return nil, errors.New(fmt.Sprintf("Property: Failed to scan row, %v", err))
 		}
-		feature := Property{name, description}
+		feature := Property{Name:name, Description:description, Version:version}
 		properties = append(properties, feature)
 	}
 	return properties, nil
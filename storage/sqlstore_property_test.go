@@ -9,7 +9,7 @@ import (
 )
 
 func TestFeatureToggleStoreImpl_CreateProperty(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -24,7 +24,7 @@ func TestFeatureToggleStoreImpl_CreateProperty(t *testing.T) {
 }
 
 func TestFeatureToggleStoreImpl_ReadProperty(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -44,7 +44,7 @@ func TestFeatureToggleStoreImpl_ReadProperty(t *testing.T) {
 }
 
 func TestFeatureToggleStoreImpl_ReadAllPropertyNames(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -70,6 +70,25 @@ func TestFeatureToggleStoreImpl_ReadAllPropertyNames(t *testing.T) {
 	assert.True(t, contains(p, propName2), "Should find property name, %v", err)
 }
 
+func TestFeatureToggleStoreImpl_SearchProperty(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	suffix := randomSufix("")
+	fs.CreateProperty(*NewProperty("user-" + suffix, ""))
+	fs.CreateProperty(*NewProperty("User-" + suffix, ""))
+
+	properties, err := fs.SearchProperty("USER-" + suffix)
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.Equal(t, 2, len(*properties), "Should ignore case")
+	assert.Equal(t, "User-" + suffix, (*properties)[0].Name, "Should be ordered by name, upper case first like the memstore")
+}
+
 func contains(ss *[]string, str string) bool {
 	for _, s := range *ss {
 		if strings.Compare(s, str) == 0 {
@@ -80,7 +99,7 @@ func contains(ss *[]string, str string) bool {
 }
 
 func TestFeatureToggleStoreImpl_DeleteProperty(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -93,7 +112,7 @@ func TestFeatureToggleStoreImpl_DeleteProperty(t *testing.T) {
 
 	require.NotNil(t, propertyName, "Should get property name, %v", err)
 
-	res, err := fs.DeleteProperty(*propertyName)
+	res, err := fs.DeleteProperty(*propertyName, 1)
 
 	require.True(t, *res, "Should get true from delete operation for property '%s', %v", propertyName, err)
 }
@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// NO KEY UPDATE doesn't conflict with the KEY SHARE lock taken by inserting
+	// a toggle rule of the feature, so it can't deadlock with another insert
+	LOCK_FEATURE_REVISIONS_SQL = "SELECT id FROM feature WHERE id = $1 FOR NO KEY UPDATE"
+	NEXT_REVISION_SQL = "SELECT COALESCE(MAX(revision), 0) + 1 FROM feature_revision WHERE featureId = $1"
+	INSERT_FEATURE_REVISION_SQL = "INSERT INTO feature_revision(featureId, revision, created, actor, feature) values ($1,$2,$3,$4,$5)"
+	INSERT_TOGGLE_RULE_REVISION_SQL = "INSERT INTO toggle_rule_revision(featureId, revision, ruleId, position, toggle_rule) values ($1,$2,$3,$4,$5)"
+	LIST_FEATURE_REVISIONS_SQL = "SELECT featureId, revision, created, actor, feature FROM feature_revision WHERE featureId = $1 ORDER BY revision DESC"
+	READ_FEATURE_REVISION_SQL = "SELECT featureId, revision, created, actor, feature FROM feature_revision WHERE featureId = $1 AND revision = $2"
+	LIST_TOGGLE_RULE_REVISIONS_SQL = "SELECT revision, toggle_rule FROM toggle_rule_revision WHERE featureId = $1 ORDER BY revision, position"
+	READ_TOGGLE_RULE_REVISIONS_SQL = "SELECT revision, toggle_rule FROM toggle_rule_revision WHERE featureId = $1 AND revision = $2 ORDER BY position"
+	DELETE_TOGGLE_RULE_REVISIONS_SQL = "DELETE FROM toggle_rule_revision WHERE featureId = $1"
+	DELETE_FEATURE_REVISIONS_SQL = "DELETE FROM feature_revision WHERE featureId = $1"
+	TOGGLE_RULE_EXISTS_SQL = "SELECT id FROM toggle_rule WHERE id = $1 LIMIT 1"
+	FEATURE_TOGGLE_RULES_SQL = SEARCH_TOGGLE_RULE_SELECT_PART_SQL + "WHERE toggle_rule.featureid = $1 " + SEARCH_TOGGLE_RULE_ORDER_PART_SQL
+)
+
+// addRevision records the current state of the feature and its toggle rules
+// as its next revision, q must be the transaction making the change. The
+// feature row is locked so the revisions are numbered in order.
+func (fs *FeatureToggleStoreImpl) addRevision(q queryer, featureId string) (*Revision, error) {
+	_, err := q.Exec(LOCK_FEATURE_REVISIONS_SQL, featureId)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to lock revisions of '%s', %v", featureId, err))
+	}
+	feature, err := queryFeature(q, READ_FEATURE_SQL, featureId)
+	if err != nil {
+		return nil, err
+	}
+	if feature == nil {
+		return nil, &NotFoundError{Kind:KindFeature, Key:featureId}
+	}
+	rules, err := queryFeatureToggleRules(q, featureId)
+	if err != nil {
+		return nil, err
+	}
+	number, err := nextRevision(q, featureId)
+	if err != nil {
+		return nil, err
+	}
+	revision := Revision{FeatureId:featureId, Revision:number, Created:time.Now().UTC(), Actor:fs.actor, Feature:*feature, ToggleRules:rules}
+
+	featureJson, err := toJson(revision.Feature)
+	if err != nil {
+		return nil, err
+	}
+	_, err = q.Exec(INSERT_FEATURE_REVISION_SQL, featureId, number, revision.Created.UTC(), revision.Actor, featureJson)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to insert revision %d of '%s', %v", number, featureId, err))
+	}
+	for i, rule := range rules {
+		ruleJson, err := toJson(rule)
+		if err != nil {
+			return nil, err
+		}
+		_, err = q.Exec(INSERT_TOGGLE_RULE_REVISION_SQL, featureId, number, rule.Id, i, ruleJson)
+		if ( err != nil) {
+			return nil, errors.New(fmt.Sprintf("Failed to insert revision %d of rule '%s', %v", number, rule.Id, err))
+		}
+	}
+	return &revision, nil
+}
+
+func nextRevision(q queryer, featureId string) (int, error) {
+	rows, err := q.Query(NEXT_REVISION_SQL, featureId)
+	if ( err != nil) {
+		return 0, errors.New(fmt.Sprintf("Failed to select revision of '%s', %v", featureId, err))
+	}
+	defer rows.Close()
+	revision := 1
+	if rows.Next() {
+		err = rows.Scan(&revision)
+		if ( err != nil) {
+			return 0, errors.New(fmt.Sprintf("Revision: Failed to scan row, %v", err))
+		}
+	}
+	return revision, rows.Err()
+}
+
+// queryFeatureToggleRules returns the rules of the feature with their
+// variants, ordered by created time.
+func queryFeatureToggleRules(q queryer, featureId string) ([]ToggleRule, error) {
+	rows, err := q.Query(FEATURE_TOGGLE_RULES_SQL, featureId)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to select toggle rules of '%s', %v", featureId, err))
+	}
+	rules, err := rowsToToggleRule(rows)
+	rows.Close()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to get row data, %v", err))
+	}
+	for i := range rules {
+		rules[i].Variants, err = readToggleRuleVariants(q, rules[i].Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func (fs *FeatureToggleStoreImpl) ListRevisions(featureId string) (*[]Revision, error) {
+	revisions, err := queryRevisions(fs.db, LIST_FEATURE_REVISIONS_SQL, LIST_TOGGLE_RULE_REVISIONS_SQL, featureId)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("ListRevisions: %v", err))
+	}
+	return &revisions, nil
+}
+
+// RevertToRevision locks the feature and rewrites it and the rows of its
+// toggle rules as they were in the revision.
+func (fs *FeatureToggleStoreImpl) RevertToRevision(featureId string, revision int, version int64) (*Revision, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("RevertToRevision: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	feature, err := queryFeature(tx, READ_FEATURE_SQL + LOCK_PART_SQL, featureId)
+	if err != nil {
+		return nil, err
+	}
+	if feature == nil {
+		return nil, &NotFoundError{Kind:KindFeature, Key:featureId}
+	}
+	err = checkVersion(KindFeature, featureId, version, feature.Version)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := queryRevisions(tx, READ_FEATURE_REVISION_SQL, READ_TOGGLE_RULE_REVISIONS_SQL, featureId, revision)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("RevertToRevision: %v", err))
+	}
+	if len(revisions) == 0 {
+		return nil, revisionNotFound(featureId, revision)
+	}
+	target := revisions[0]
+	other, err := queryFeature(tx, READ_FEATURE_BY_NAME_SQL, target.Feature.Name)
+	if err != nil {
+		return nil, err
+	}
+	if other != nil && other.Id != featureId {
+		return nil, &AlreadyExistsError{Kind:KindFeature, Key:target.Feature.Name}
+	}
+	rules, err := queryFeatureToggleRules(tx, featureId)
+	if err != nil {
+		return nil, err
+	}
+	target = revertedVersions(*feature, rules, target)
+
+	restored := target.Feature
+	_, err = tx.Exec(UPDATE_FEATURE_SQL, featureId, restored.Name, restored.Enabled, restored.Description, restored.DefaultVariant, nullTime(restored.Starts), nullTime(restored.Expires), restored.Version)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("RevertToRevision: Failed to update '%s', %v", featureId, err))
+	}
+	_, err = tx.Exec(DELETE_FEATURE_VARIANTS_SQL, featureId)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("RevertToRevision: Failed to delete variants of '%s', %v", featureId, err))
+	}
+	err = insertFeatureVariants(tx, featureId, restored.Variants)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		_, err = tx.Exec(DELETE_TOGGLE_RULE_VARIANTS_SQL, rule.Id)
+		if ( err != nil) {
+			return nil, errors.New(fmt.Sprintf("RevertToRevision: Failed to delete variants of rule '%s', %v", rule.Id, err))
+		}
+		_, err = tx.Exec(DELETE_TOGGLE_RULE_SQL, rule.Id)
+		if ( err != nil) {
+			return nil, errors.New(fmt.Sprintf("RevertToRevision: Failed to delete rule '%s', %v", rule.Id, err))
+		}
+	}
+	for _, rule := range target.ToggleRules {
+		// the rows of the rule are gone unless it was moved to another feature
+		err = refusedByToggleRule(tx, TOGGLE_RULE_EXISTS_SQL, "RevertToRevision", KindToggleRule, rule.Id)
+		if _, ok := err.(*ReferencedError); ok {
+			return nil, &AlreadyExistsError{Kind:KindToggleRule, Key:rule.Id}
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = insertToggleRule(tx, rule)
+		if e, ok := err.(*NotFoundError); ok && e.Kind == KindProperty {
+			return nil, propertyDeletedSince(featureId, revision, e.Key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = recordRevert(func(kind string, entityId string, operation string, before interface{}, after interface{}) error {
+		return fs.record(tx, kind, entityId, operation, before, after)
+	}, *feature, rules, target)
+	if err != nil {
+		return nil, err
+	}
+	reverted, err := fs.addRevision(tx, featureId)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("RevertToRevision: Failed to commit, %v", err))
+	}
+	return reverted, nil
+}
+
+// queryRevisions returns the revisions selected by query with args, with the
+// rules selected by rulesQuery with the same args. rulesQuery may select the
+// rules of more revisions.
+func queryRevisions(q queryer, query string, rulesQuery string, args ...interface{}) ([]Revision, error) {
+	rows, err := q.Query(query, args...)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to select revisions, %v", err))
+	}
+	revisions, err := rowsToRevision(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return revisions, nil
+	}
+
+	rows, err = q.Query(rulesQuery, args...)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to select rule revisions, %v", err))
+	}
+	defer rows.Close()
+	rules := make(map[int][]ToggleRule)
+	for rows.Next() {
+		var revision int
+		var ruleJson string
+		err = rows.Scan(&revision, &ruleJson)
+		if ( err != nil) {
+			return nil, errors.New(fmt.Sprintf("Rule revision: Failed to scan row, %v", err))
+		}
+		var rule ToggleRule
+		err = json.Unmarshal([]byte(ruleJson), &rule)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Failed to unmarshal revision %d of a rule, %v", revision, err))
+		}
+		rules[revision] = append(rules[revision], rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to read rows, %v", err))
+	}
+	for i := range revisions {
+		revisions[i].ToggleRules = rules[revisions[i].Revision]
+		if revisions[i].ToggleRules == nil {
+			revisions[i].ToggleRules = []ToggleRule{}
+		}
+	}
+	return revisions, nil
+}
+
+func rowsToRevision(rows *sql.Rows) ([]Revision, error) {
+	revisions := []Revision{}
+	for rows.Next() {
+		var revision Revision
+		var featureJson string
+		err := rows.Scan(&revision.FeatureId, &revision.Revision, &revision.Created, &revision.Actor, &featureJson)
+		if ( err != nil) {
+			return nil, errors.New(fmt.Sprintf("Revision: Failed to scan row, %v", err))
+		}
+		err = json.Unmarshal([]byte(featureJson), &revision.Feature)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Failed to unmarshal revision %d, %v", revision.Revision, err))
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"testing"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureToggleStoreImpl_RevertToRevision(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	prop := *NewProperty(randomSufix("prop-"), "p description")
+	p, err := fs.CreateProperty(prop); require.NotNil(t, p, "Should get propertyName, %v", err)
+	featureId, err := fs.CreateFeature(*NewFeature(randomSufix("Feature-"), true, "f description"))
+	require.NotNil(t, featureId, "Should get feature id, %v", err)
+	ruleId, err := fs.CreateToggleRule(*NewToggleRule(*featureId, true, prop.Name, "adam"))
+	require.NotNil(t, ruleId, "Should get rule id, %v", err)
+	adam := fs.WithActor("jwt:adam")
+	_, err = adam.UpdateFeature(Feature{Id:*featureId, Description:"bad", Version:1}, []string{"description"})
+	require.Nil(t, err, "Should update feature, %v", err)
+	_, err = adam.UpdateToggleRule(ToggleRule{Id:*ruleId, Properties:Properties{prop.Name:"eve"}, Version:1}, []string{"properties"})
+	require.Nil(t, err, "Should update toggle rule, %v", err)
+	newRuleId, err := adam.CreateToggleRule(*NewToggleRule(*featureId, true, prop.Name, "bertil"))
+	require.NotNil(t, newRuleId, "Should get rule id, %v", err)
+
+	revisions, err := fs.ListRevisions(*featureId)
+	require.NotNil(t, revisions, "Should list revisions, %v", err)
+	require.Len(t, *revisions, 5, "Should record a revision per change")
+	assert.Equal(t, 5, (*revisions)[0].Revision, "Should return the newest revision first")
+	assert.Equal(t, "jwt:adam", (*revisions)[0].Actor)
+	assert.Len(t, (*revisions)[0].ToggleRules, 2)
+	require.Len(t, (*revisions)[3].ToggleRules, 1)
+	assert.Equal(t, Properties{prop.Name:"adam"}, (*revisions)[3].ToggleRules[0].Properties)
+
+	reverted, err := adam.RevertToRevision(*featureId, 2, 2)
+	require.NotNil(t, reverted, "Should revert, %v", err)
+	assert.Equal(t, 6, reverted.Revision, "Should record the revert as a new revision")
+
+	feature, _ := fs.ReadFeature(*featureId)
+	assert.Equal(t, "f description", feature.Description, "Should restore the feature")
+	assert.Equal(t, int64(3), feature.Version, "Should increase the version of the restored feature")
+	rule, _ := fs.ReadToggleRule(*ruleId)
+	require.NotNil(t, rule)
+	assert.Equal(t, Properties{prop.Name:"adam"}, rule.Properties, "Should restore the rule")
+	assert.Equal(t, int64(3), rule.Version)
+	rule, _ = fs.ReadToggleRule(*newRuleId)
+	assert.Nil(t, rule, "Should delete rules created after the revision")
+
+	_, err = fs.RevertToRevision(*featureId, 7, 3)
+	assert.IsType(t, &NotFoundError{}, err, "Should refuse unknown revisions")
+	_, err = fs.RevertToRevision(*featureId, 1, 2)
+	assert.IsType(t, &VersionMismatchError{}, err, "Should refuse a stale version")
+
+	fs.DeleteToggleRule(*ruleId, 3)
+	_, err = fs.DeleteProperty(prop.Name, 1)
+	require.Nil(t, err, "Should delete the property, %v", err)
+	_, err = fs.RevertToRevision(*featureId, 2, 3)
+	require.IsType(t, &ReferencedError{}, err, "Should refuse a rule with a property deleted since")
+	assert.Equal(t, prop.Name, err.(*ReferencedError).Key)
+
+	b, err := fs.DeleteFeature(*featureId, 3)
+	require.NotNil(t, b, "Should delete a feature with revisions, %v", err)
+	revisions, _ = fs.ListRevisions(*featureId)
+	assert.Len(t, *revisions, 0, "Should delete the revisions with the feature")
+}
@@ -9,23 +9,61 @@ import (
 	"github.com/satori/go.uuid"
 	"bytes"
 	"errors"
+
+	"github.com/lib/pq"
 )
 
 const (
-	INSERT_TOGGLE_RULE_SQL = "INSERT INTO toggle_rule(id, featureid, property, value, created, expires, enabled) values ($1,$2,$3,$4,$5,$6,$7)"
+	INSERT_TOGGLE_RULE_SQL = "INSERT INTO toggle_rule(id, featureid, property, value, created, expires, enabled, rollout_percentage, rollout_property, operator, deny, priority, starts, version) values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)"
 	DELETE_TOGGLE_RULE_SQL = "DELETE FROM toggle_rule WHERE toggle_rule.id = $1"
 	READ_TOGGLE_RULE_WHERE_PART_SQL = "WHERE toggle_rule.id = $1"
+	LOCK_PART_SQL = " FOR UPDATE"
 
-	SEARCH_TOGGLE_RULE_SELECT_PART_SQL = "SELECT DISTINCT toggle_rule.* FROM toggle_rule "
+	SEARCH_TOGGLE_RULE_SELECT_PART_SQL = "SELECT toggle_rule.id, toggle_rule.featureid, toggle_rule.property, toggle_rule.value, toggle_rule.created, toggle_rule.expires, toggle_rule.enabled, toggle_rule.rollout_percentage, toggle_rule.rollout_property, toggle_rule.operator, toggle_rule.deny, toggle_rule.priority, toggle_rule.starts, toggle_rule.version FROM toggle_rule "
 	SEARCH_TOGGLE_RULE_FEATURE_JOIN_PART_SQL = "JOIN feature ON feature.id = toggle_rule.featureid "
-	SEARCH_TOGGLE_RULE_NAME_PART_SQL = "feature.name = $1 "
-	SEARCH_TOGGLE_RULE_FILTER_PART_SQL = "%s(p%d.property=$%d AND p%d.value = $%d) "
-	SEARCH_TOGGLE_RULE_INNER_JOIN_SQL = "INNER JOIN toggle_rule AS p%d ON toggle_rule.featureid = p%d.featureid "
-	SEARCH_ROGGLE_RULE_ENABLED_SQL = "SELECT DISTINCT tr.id, feature.name, tr.property, tr.value FROM toggle_rule tr JOIN feature ON feature.id = tr.featureid WHERE feature.enabled = true and tr.enabled = true and tr.expires < $1"
+	SEARCH_TOGGLE_RULE_NAME_PART_SQL = "feature.name = $%d "
+	SEARCH_TOGGLE_RULE_ENABLED_PART_SQL = "toggle_rule.enabled = $%d "
+	SEARCH_TOGGLE_RULE_CREATED_START_PART_SQL = "toggle_rule.created >= $%d "
+	SEARCH_TOGGLE_RULE_CREATED_END_PART_SQL = "toggle_rule.created <= $%d "
+	SEARCH_TOGGLE_RULE_EXPIRES_START_PART_SQL = "toggle_rule.expires >= $%d "
+	SEARCH_TOGGLE_RULE_EXPIRES_END_PART_SQL = "toggle_rule.expires <= $%d "
+	SEARCH_TOGGLE_RULE_FILTER_PART_SQL = "toggle_rule.id IN (SELECT id FROM toggle_rule WHERE property = $%d AND value = $%d) "
+	SEARCH_TOGGLE_RULE_ORDER_PART_SQL = "ORDER BY toggle_rule.created, toggle_rule.id"
+	SEARCH_ROGGLE_RULE_ENABLED_SQL = "SELECT DISTINCT tr.id, feature.name, tr.property, tr.value, tr.rollout_percentage, tr.rollout_property, tr.operator, tr.deny, tr.priority, tr.starts, tr.expires, feature.starts, feature.expires FROM toggle_rule tr JOIN feature ON feature.id = tr.featureid WHERE feature.enabled = true and tr.enabled = true and (tr.expires IS NULL OR tr.expires > $1) and (feature.expires IS NULL OR feature.expires > $1)"
 )
 
 func (fs *FeatureToggleStoreImpl) CreateToggleRule(toggleRule ToggleRule) (*string, error) {
-	created := time.Now()
+	created := time.Now().UTC()
+
+	err := validateProperties(toggleRule)
+	if err != nil {
+		return nil, err
+	}
+	err = validateSchedule(toggleRule.Starts, toggleRule.Expires)
+	if err != nil {
+		return nil, err
+	}
+	err = validateRollout(toggleRule)
+	if err != nil {
+		return nil, err
+	}
+	err = validateOperators(toggleRule)
+	if err != nil {
+		return nil, err
+	}
+	if len(toggleRule.Variants) > 0 {
+		feature, err := fs.ReadFeature(toggleRule.FeatureId)
+		if err != nil {
+			return nil, err
+		}
+		if feature == nil {
+			return nil, &NotFoundError{Kind:KindFeature, Key:toggleRule.FeatureId}
+		}
+		err = validateRuleVariants(toggleRule, *feature)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	tx, err := fs.db.Begin()
 	if ( err != nil) {
@@ -33,25 +71,54 @@ func (fs *FeatureToggleStoreImpl) CreateToggleRule(toggleRule ToggleRule) (*stri
 	}
 	defer tx.Rollback()
 
-	stmt, err := fs.db.Prepare(INSERT_TOGGLE_RULE_SQL)
+	id := toggleRule.Id
+	if strings.Compare(id, "") == 0 {
+		id = uuid.NewV4().String()
+	}
+	toggleRule.Id = id
+	toggleRule.Created = created
+	toggleRule.Version = 1
+	err = insertToggleRule(tx, toggleRule)
+	if err != nil {
+		return nil, err
+	}
+	err = fs.record(tx, KindToggleRule, id, OperationCreate, nil, toggleRule)
+	if err != nil {
+		return nil, err
+	}
+	_, err = fs.addRevision(tx, toggleRule.FeatureId)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to commit toggle rule, %v", err))
+	}
+
+	return &id, nil
+}
+
+// insertToggleRule inserts a row in toggle_rule per property of the rule and
+// the variants it chooses.
+func insertToggleRule(tx *sql.Tx, toggleRule ToggleRule) error {
+	stmt, err := tx.Prepare(INSERT_TOGGLE_RULE_SQL)
 	if ( err != nil) {
-		return nil, errors.New(fmt.Sprintf("Failed to create prepared statement, %v", err))
+		return errors.New(fmt.Sprintf("Failed to create prepared statement, %v", err))
 	}
 	defer stmt.Close()
 
-	var id string
-	if strings.Compare(toggleRule.Id, "") == 0 {
-		id = uuid.NewV4().String()
-	}
 	for property, value := range toggleRule.Properties {
-		_, err := stmt.Exec(id, toggleRule.FeatureId, property, value, created, toggleRule.Expires, toggleRule.Enabled)
-		if ( err != nil) {
-			return nil, errors.New(fmt.Sprintf("Failed to insert row with property '%s', %v", property, err))
+		_, err := stmt.Exec(toggleRule.Id, toggleRule.FeatureId, property, value, toggleRule.Created.UTC(), nullTime(toggleRule.Expires), toggleRule.Enabled, toggleRule.RolloutPercentage, toggleRule.RolloutProperty, toggleRule.operator(property), toggleRule.Deny, toggleRule.Priority, nullTime(toggleRule.Starts), toggleRule.Version)
+		switch {
+		case isForeignKeyViolation(err, "fk_property"):
+			return &NotFoundError{Kind:KindProperty, Key:property}
+		case isForeignKeyViolation(err, "fk_feature"):
+			return &NotFoundError{Kind:KindFeature, Key:toggleRule.FeatureId}
+		case err != nil:
+			return sqlError(err, KindToggleRule, toggleRule.Id, errors.New(fmt.Sprintf("Failed to insert row with property '%s', %v", property, err)))
 		}
 	}
-	tx.Commit()
-
-	return &id, nil
+	return insertToggleRuleVariants(tx, toggleRule.Id, toggleRule.Variants)
 }
 
 func (fs *FeatureToggleStoreImpl) ReadToggleRule(id string) (*ToggleRule, error) {
@@ -77,15 +144,41 @@ func (fs *FeatureToggleStoreImpl) ReadToggleRule(id string) (*ToggleRule, error)
 		return nil, errors.New(fmt.Sprintf("Failed to get row data, %v", err))
 	}
 	if len(toggleRules) > 0 {
+		toggleRules[0].Variants, err = readToggleRuleVariants(fs.db, toggleRules[0].Id)
+		if err != nil {
+			return nil, err
+		}
 		return &toggleRules[0], nil
 	}
 	return nil, nil
 
 }
 
-func (fs *FeatureToggleStoreImpl) DeleteToggleRule(id string) (*bool, error) {
+func (fs *FeatureToggleStoreImpl) DeleteToggleRule(id string, version int64) (*bool, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
+
+	rule, err := queryToggleRule(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		b := false
+		return &b, nil
+	}
+	err = checkVersion(KindToggleRule, id, version, rule.Version)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(DELETE_TOGGLE_RULE_VARIANTS_SQL, id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to delete toggle rule variants, %v", err))
+	}
 
-	stmt, err := fs.db.Prepare(DELETE_TOGGLE_RULE_SQL)
+	stmt, err := tx.Prepare(DELETE_TOGGLE_RULE_SQL)
 	if ( err != nil) {
 		return nil, errors.New(fmt.Sprintf("Failed to create delete prepared statement, %v", err))
 	}
@@ -99,40 +192,116 @@ func (fs *FeatureToggleStoreImpl) DeleteToggleRule(id string) (*bool, error) {
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("Failed to get rowsAffected, %v", err))
 	}
+	err = fs.record(tx, KindToggleRule, id, OperationDelete, rule, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = fs.addRevision(tx, rule.FeatureId)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to commit delete of toggle rule, %v", err))
+	}
 	b := rowCount > 0
 	return &b, nil
 }
 
-func (fs *FeatureToggleStoreImpl) SearchToggleRule(name *string, filter Filter) (*[]ToggleRule, error) {
-	var buffer bytes.Buffer
+// UpdateToggleRule locks the rows of the rule and rewrites them with the
+// updated rule.
+func (fs *FeatureToggleStoreImpl) UpdateToggleRule(update ToggleRule, paths []string) (*ToggleRule, error) {
+	tx, err := fs.db.Begin()
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("UpdateToggleRule: Failed to create trasaction, %v", err))
+	}
+	defer tx.Rollback()
 
-	buffer.WriteString(SEARCH_TOGGLE_RULE_SELECT_PART_SQL)
-	if ( name != nil) {
-		buffer.WriteString(SEARCH_TOGGLE_RULE_FEATURE_JOIN_PART_SQL)
+	current, err := queryToggleRule(tx, update.Id)
+	if err != nil || current == nil {
+		return nil, err
 	}
-	for i := 0; i < len(filter); i++ {
-		buffer.WriteString(getInnerJoinLine(i))
+	err = checkVersion(KindToggleRule, current.Id, update.Version, current.Version)
+	if err != nil {
+		return nil, err
 	}
+	rule := *current
 
-	buffer.WriteString("WHERE ")
-	if ( name != nil) {
-		buffer.WriteString(SEARCH_TOGGLE_RULE_NAME_PART_SQL)
+	err = applyToggleRuleUpdate(&rule, update, paths)
+	if err != nil {
+		return nil, err
+	}
+	rule.Version++
+	feature, err := queryFeature(tx, READ_FEATURE_SQL, rule.FeatureId)
+	if err != nil {
+		return nil, err
+	}
+	if feature == nil {
+		return nil, &NotFoundError{Kind:KindFeature, Key:rule.FeatureId}
+	}
+	err = validateRuleVariants(rule, *feature)
+	if err != nil {
+		return nil, err
 	}
 
-	var offset int
-	if ( name == nil) {
-		offset = 1
-	} else {
-		offset = 2
+	_, err = tx.Exec(DELETE_TOGGLE_RULE_VARIANTS_SQL, rule.Id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("UpdateToggleRule: Failed to delete toggle rule variants, %v", err))
+	}
+	_, err = tx.Exec(DELETE_TOGGLE_RULE_SQL, rule.Id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("UpdateToggleRule: Failed to delete toggle rule, %v", err))
+	}
+	err = insertToggleRule(tx, rule)
+	if err != nil {
+		return nil, err
+	}
+	err = fs.record(tx, KindToggleRule, rule.Id, OperationUpdate, current, rule)
+	if err != nil {
+		return nil, err
+	}
+	_, err = fs.addRevision(tx, rule.FeatureId)
+	if err != nil {
+		return nil, err
+	}
+	if current.FeatureId != rule.FeatureId {
+		_, err = fs.addRevision(tx, current.FeatureId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("UpdateToggleRule: Failed to commit, %v", err))
 	}
-	for i := 0; i < len(filter); i++ {
-		skipStartingAnd := i == 0 && name == nil
+	return &rule, nil
+}
 
-		buffer.WriteString(getPropertyFilterLine(i, skipStartingAnd, offset))
+// queryToggleRule locks the rows of the rule and returns it with its
+// variants, nil if there is none.
+func queryToggleRule(q queryer, id string) (*ToggleRule, error) {
+	rows, err := q.Query(SEARCH_TOGGLE_RULE_SELECT_PART_SQL + READ_TOGGLE_RULE_WHERE_PART_SQL + LOCK_PART_SQL, id)
+	if ( err != nil) {
+		return nil, errors.New(fmt.Sprintf("Failed to select toggle rule '%s', %v", id, err))
+	}
+	toggleRules, err := rowsToToggleRule(rows)
+	rows.Close()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to get row data, %v", err))
+	}
+	if len(toggleRules) == 0 {
+		return nil, nil
+	}
+	rule := toggleRules[0]
+	rule.Variants, err = readToggleRuleVariants(q, rule.Id)
+	if err != nil {
+		return nil, err
 	}
+	return &rule, nil
+}
 
-	searchQuery := buffer.String()
-	fmt.Println(searchQuery)
+func (fs *FeatureToggleStoreImpl) SearchToggleRule(search ToggleRuleSearch) (*[]ToggleRule, error) {
+	searchQuery, params := toggleRuleSearchQuery(search)
 
 	stmt, err := fs.db.Prepare(searchQuery)
 	if ( err != nil) {
@@ -140,7 +309,6 @@ func (fs *FeatureToggleStoreImpl) SearchToggleRule(name *string, filter Filter)
 	}
 	defer stmt.Close()
 
-	params := getParams(name, &filter)
 	rows, err := stmt.Query(params...)
 	if ( err != nil) {
 		return nil, errors.New(fmt.Sprintf("Failed to run query, %v", err))
@@ -151,22 +319,24 @@ func (fs *FeatureToggleStoreImpl) SearchToggleRule(name *string, filter Filter)
 	if ( err != nil) {
 		return nil, err
 	}
+	for i := range res {
+		res[i].Variants, err = readToggleRuleVariants(fs.db, res[i].Id)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &res, nil
 }
 
 func (fs *FeatureToggleStoreImpl) GetEnabledToggleRules() (*[]featuretree.ToggleRule, error) {
-	var buffer bytes.Buffer
-
-	searchQuery := buffer.String()
-	fmt.Println(searchQuery)
-
 	stmt, err := fs.db.Prepare(SEARCH_ROGGLE_RULE_ENABLED_SQL)
 	if ( err != nil) {
 		return nil, errors.New(fmt.Sprintf("GetEnabledToggleRules: Failed to create prepared statement, %v", err))
 	}
 	defer stmt.Close()
 
-	now := time.Now()
+	// the timestamp columns hold UTC without a time zone
+	now := time.Now().UTC()
 	rows, err := stmt.Query(now)
 	if ( err != nil) {
 		return nil, errors.New(fmt.Sprintf("GetEnabledToggleRules: Failed to run query, %v", err))
@@ -177,48 +347,106 @@ func (fs *FeatureToggleStoreImpl) GetEnabledToggleRules() (*[]featuretree.Toggle
 	if ( err != nil) {
 		return nil, err
 	}
+	variants, err := readAllToggleRuleVariants(fs.db)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		res[i].Variants = variants[res[i].Id]
+	}
 	return &res, nil
 }
 
-func getParams(featurename *string, filters *Filter) []interface{} {
+// toggleRuleSearchQuery returns the query selecting all rows of the rules
+// matching the search, and its parameters.
+func toggleRuleSearchQuery(search ToggleRuleSearch) (string, []interface{}) {
+	var buffer bytes.Buffer
 	params := make([]interface{}, 0)
-	if featurename != nil {
-		params = append(params, featurename)
-	}
-	if ( filters != nil) {
-		for propertyName, propertyValue := range *filters {
-			params = append(params, propertyName, propertyValue)
+	conditions := []string{}
+	add := func(part string, values ...interface{}) {
+		args := []interface{}{}
+		for _, v := range values {
+			params = append(params, v)
+			args = append(args, len(params))
 		}
+		conditions = append(conditions, fmt.Sprintf(part, args...))
+	}
+
+	buffer.WriteString(SEARCH_TOGGLE_RULE_SELECT_PART_SQL)
+	if search.Name != nil {
+		buffer.WriteString(SEARCH_TOGGLE_RULE_FEATURE_JOIN_PART_SQL)
+		add(SEARCH_TOGGLE_RULE_NAME_PART_SQL, *search.Name)
+	}
+	if search.Enabled != nil {
+		add(SEARCH_TOGGLE_RULE_ENABLED_PART_SQL, *search.Enabled)
 	}
-	return params
+	if !search.CreatedStart.IsZero() {
+		add(SEARCH_TOGGLE_RULE_CREATED_START_PART_SQL, search.CreatedStart.UTC())
+	}
+	if !search.CreatedEnd.IsZero() {
+		add(SEARCH_TOGGLE_RULE_CREATED_END_PART_SQL, search.CreatedEnd.UTC())
+	}
+	if !search.ExpiresStart.IsZero() {
+		add(SEARCH_TOGGLE_RULE_EXPIRES_START_PART_SQL, search.ExpiresStart.UTC())
+	}
+	if !search.ExpiresEnd.IsZero() {
+		add(SEARCH_TOGGLE_RULE_EXPIRES_END_PART_SQL, search.ExpiresEnd.UTC())
+	}
+	for propertyName, propertyValue := range search.Properties {
+		add(SEARCH_TOGGLE_RULE_FILTER_PART_SQL, propertyName, propertyValue)
+	}
+
+	if len(conditions) > 0 {
+		buffer.WriteString("WHERE ")
+		buffer.WriteString(strings.Join(conditions, "AND "))
+	}
+	buffer.WriteString(SEARCH_TOGGLE_RULE_ORDER_PART_SQL)
+	return buffer.String(), params
 }
 
+// rowsToToggleRule groups the rows, one per property, into rules. The order
+// of the rows is kept.
 func rowsToToggleRule(rows *sql.Rows) ([]ToggleRule, error) {
-	ruleMap := make(map[string]ToggleRule)
+	ruleMap := make(map[string]*ToggleRule)
+	order := []string{}
 	for rows.Next() {
 		var id string
 		var featureid string
 		var property string
 		var value string
-		var created string
-		var expires string
+		var created time.Time
+		var expires pq.NullTime
 		var enabled bool
-		err := rows.Scan(&id, &featureid, &property, &value, &created, &expires, &enabled)
+		var rolloutPercentage int
+		var rolloutProperty string
+		var operator string
+		var deny bool
+		var priority int
+		var starts pq.NullTime
+		var version int64
+		err := rows.Scan(&id, &featureid, &property, &value, &created, &expires, &enabled, &rolloutPercentage, &rolloutProperty, &operator, &deny, &priority, &starts, &version)
 		if ( err != nil) {
 			return nil, errors.New(fmt.Sprintf("Failed to scan row, %v", err))
 		}
-		rule, ok := ruleMap[featureid]
+		rule, ok := ruleMap[id]
 		if !ok {
 			props := make(Properties)
-			rule = ToggleRule{Id:id, FeatureId:featureid, Properties:props}
-			ruleMap[featureid] = rule
+			rule = &ToggleRule{Id:id, FeatureId:featureid, Enabled:enabled, Created:created, Properties:props,
+				RolloutPercentage:rolloutPercentage, RolloutProperty:rolloutProperty, Deny:deny, Priority:priority,
+				Starts:fromNullTime(starts), Expires:fromNullTime(expires), Version:version}
+			ruleMap[id] = rule
+			order = append(order, id)
 		}
 		rule.Properties[property] = value
+		*rule = addOperator(*rule, property, operator)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to read rows, %v", err))
 	}
 
 	result := []ToggleRule{}
-	for _, rule := range ruleMap {
-		result = append(result, rule)
+	for _, id := range order {
+		result = append(result, *ruleMap[id])
 	}
 	return result, nil
 }
@@ -230,39 +458,65 @@ func rowsToTreeToggleRule(rows *sql.Rows) ([]featuretree.ToggleRule, error) {
 		var featurename string
 		var property string
 		var value string
-		err := rows.Scan(&id, &featurename, &property, &value)
+		var rolloutPercentage int
+		var rolloutProperty string
+		var operator string
+		var deny bool
+		var priority int
+		var starts, expires, featureStarts, featureExpires pq.NullTime
+		err := rows.Scan(&id, &featurename, &property, &value, &rolloutPercentage, &rolloutProperty, &operator, &deny, &priority,
+			&starts, &expires, &featureStarts, &featureExpires)
 		if ( err != nil) {
 			return nil, errors.New(fmt.Sprintf("Failed to scan row, %v", err))
 		}
 		rule, ok := ruleMap[id]
 		if !ok {
 			props := make(featuretree.Properties)
-			rule = featuretree.ToggleRule{Name:featurename, Properties:props}
+			rule = featuretree.ToggleRule{Id:id, Name:featurename, Properties:props, RolloutPercentage:rolloutPercentage, RolloutProperty:rolloutProperty,
+				Deny:deny, Priority:priority, Starts:fromNullTime(starts), Expires:fromNullTime(expires)}.Within(fromNullTime(featureStarts), fromNullTime(featureExpires))
 
 			ruleMap[id] = rule
 		}
 		rule.Properties[property] = value
+		if operator != featuretree.OperatorEquals {
+			if rule.Operators == nil {
+				rule.Operators = make(map[string]string)
+			}
+			rule.Operators[property] = operator
+			ruleMap[id] = rule
+		}
 	}
 
+	now := time.Now().UTC()
 	result := []featuretree.ToggleRule{}
 	for _, rule := range ruleMap {
-		result = append(result, rule)
+		if !rule.ExpiredAt(now) {
+			result = append(result, rule)
+		}
 	}
 	return result, nil
 }
 
-func getPropertyFilterLine(i int, skipStartingAnd bool, offset int) string {
+// nullTime stores the zero time, which the store uses for unset times, as NULL.
+// nullTime is stored in UTC like every timestamp, the columns have no time
+// zone.
+func nullTime(t time.Time) pq.NullTime {
+	return pq.NullTime{Time:t.UTC(), Valid:!t.IsZero()}
+}
 
-	var prefix string
-	if ( skipStartingAnd) {
-		prefix = ""
-	} else {
-		prefix = "AND"
+func fromNullTime(t pq.NullTime) time.Time {
+	if !t.Valid {
+		return time.Time{}
 	}
-	return fmt.Sprintf(SEARCH_TOGGLE_RULE_FILTER_PART_SQL, prefix, i, offset + i * 2, i, offset + 1 + i * 2)
+	return t.Time
 }
 
-func getInnerJoinLine(i int) string {
-	return fmt.Sprintf(SEARCH_TOGGLE_RULE_INNER_JOIN_SQL, i, i)
+func addOperator(rule ToggleRule, property string, operator string) ToggleRule {
+	if operator != featuretree.OperatorEquals {
+		if rule.Operators == nil {
+			rule.Operators = make(map[string]string)
+		}
+		rule.Operators[property] = operator
+	}
+	return rule
 }
-
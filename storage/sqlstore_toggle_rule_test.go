@@ -10,7 +10,7 @@ import (
 )
 
 func TestFeatureToggleStoreImpl_CreateToggleRule(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -21,9 +21,9 @@ func TestFeatureToggleStoreImpl_CreateToggleRule(t *testing.T) {
 	feature := NewFeature(randomSufix("Name-"), true, "f description")
 	featureId, err := fs.CreateFeature(*feature); require.NotNil(t, featureId, "Should get featureId, %v", err)
 
-	prop1 := Property{randomSufix("prop-"), "p description 1"}
-	prop2 := Property{randomSufix("prop-"), "p description 2"}
-	prop3 := Property{randomSufix("prop-"), "p description 3"}
+	prop1 := *NewProperty(randomSufix("prop-"), "p description 1")
+	prop2 := *NewProperty(randomSufix("prop-"), "p description 2")
+	prop3 := *NewProperty(randomSufix("prop-"), "p description 3")
 	p, err := fs.CreateProperty(prop1); require.NotNil(t, p, "Should get propertyName, %v", err)
 	p, err = fs.CreateProperty(prop2); require.NotNil(t, p, "Should get propertyName, %v", err)
 	p, err = fs.CreateProperty(prop3); require.NotNil(t, p, "Should get propertyName, %v", err)
@@ -34,7 +34,7 @@ func TestFeatureToggleStoreImpl_CreateToggleRule(t *testing.T) {
 }
 
 func TestFeatureToggleStoreImpl_DeleteToggleRule(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if ( err != nil) {
@@ -45,9 +45,9 @@ func TestFeatureToggleStoreImpl_DeleteToggleRule(t *testing.T) {
 	feature := NewFeature(randomSufix("Name-"), true, "f description")
 	featureId, err := fs.CreateFeature(*feature); require.NotNil(t, featureId, "Should get featureId, %v", err)
 
-	prop1 := Property{randomSufix("prop-"), "p description 1"}
-	prop2 := Property{randomSufix("prop-"), "p description 2"}
-	prop3 := Property{randomSufix("prop-"), "p description 3"}
+	prop1 := *NewProperty(randomSufix("prop-"), "p description 1")
+	prop2 := *NewProperty(randomSufix("prop-"), "p description 2")
+	prop3 := *NewProperty(randomSufix("prop-"), "p description 3")
 	p, err := fs.CreateProperty(prop1); require.NotNil(t, p, "Should get propertyName, %v", err)
 	p, err = fs.CreateProperty(prop2); require.NotNil(t, p, "Should get propertyName, %v", err)
 	p, err = fs.CreateProperty(prop3); require.NotNil(t, p, "Should get propertyName, %v", err)
@@ -56,14 +56,14 @@ func TestFeatureToggleStoreImpl_DeleteToggleRule(t *testing.T) {
 	ruleId, err := fs.CreateToggleRule(*NewToggleRule(*featureId, true, prop1.Name, "val1", prop2.Name, "val2"))
 	fmt.Printf("ruleID %s\n", *ruleId)
 	require.Nil(t, err, "Failed to create toggle rule, %v", err)
-	res, err := fs.DeleteToggleRule(*ruleId)
+	res, err := fs.DeleteToggleRule(*ruleId, 1)
 	require.Nil(t, err, "Failed to delete toggle rule %v", err)
 	assert.True(t, *res, "Should get true as result")
 
 }
 
 func TestFeatureToggleStoreImpl_ReadToggleRule(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if ( err != nil) {
@@ -77,8 +77,8 @@ func TestFeatureToggleStoreImpl_ReadToggleRule(t *testing.T) {
 	feature := NewFeature(randomSufix("Name-"), true, "f description")
 	featureId, err := fs.CreateFeature(*feature); require.NotNil(t, featureId, "Should get featureId, %v", err)
 
-	prop1 := Property{randomSufix("prop-"), "p description 1"}
-	prop2 := Property{randomSufix("prop-"), "p description 2"}
+	prop1 := *NewProperty(randomSufix("prop-"), "p description 1")
+	prop2 := *NewProperty(randomSufix("prop-"), "p description 2")
 	p, err := fs.CreateProperty(prop1); require.NotNil(t, p, "Should get propertyName, %v", err)
 	p, err = fs.CreateProperty(prop2); require.NotNil(t, p, "Should get propertyName, %v", err)
 
@@ -94,7 +94,7 @@ func TestFeatureToggleStoreImpl_ReadToggleRule(t *testing.T) {
 }
 
 func TestFeatureStore_SearchToggleRule(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -108,8 +108,8 @@ func TestFeatureStore_SearchToggleRule(t *testing.T) {
 	feature := NewFeature(randomSufix("Name-"), true, "f description")
 	featureId, err := fs.CreateFeature(*feature); require.NotNil(t, featureId, "Should get featureId, %v", err)
 
-	prop1 := Property{randomSufix("prop-"), "p description 1"}
-	prop2 := Property{randomSufix("prop-"), "p description 2"}
+	prop1 := *NewProperty(randomSufix("prop-"), "p description 1")
+	prop2 := *NewProperty(randomSufix("prop-"), "p description 2")
 	p, err := fs.CreateProperty(prop1); require.NotNil(t, p, "Should get propertyName, %v", err)
 	p, err = fs.CreateProperty(prop2); require.NotNil(t, p, "Should get propertyName, %v", err)
 
@@ -119,7 +119,7 @@ func TestFeatureStore_SearchToggleRule(t *testing.T) {
 	filter := make(Filter)
 	filter[prop1.Name] = val1
 	filter[prop2.Name] = val2
-	toggleRules, err := fs.SearchToggleRule(&feature.Name, filter)
+	toggleRules, err := fs.SearchToggleRule(ToggleRuleSearch{Name: &feature.Name, Properties: filter})
 
 	PrintFeatures(*toggleRules)
 	require.Equal(t, 1, len(*toggleRules), fmt.Sprintf("Result shall contain one toggle rule, %v", *toggleRules))
@@ -129,7 +129,7 @@ func TestFeatureStore_SearchToggleRule(t *testing.T) {
 }
 
 func TestFeatureStore_SearchToggleRule__no_name(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -143,8 +143,8 @@ func TestFeatureStore_SearchToggleRule__no_name(t *testing.T) {
 	feature := NewFeature(randomSufix("Name-"), true, "f description")
 	featureId, err := fs.CreateFeature(*feature); require.NotNil(t, featureId, "Should get featureId, %v", err)
 
-	prop1 := Property{randomSufix("prop-"), "p description 1"}
-	prop2 := Property{randomSufix("prop-"), "p description 2"}
+	prop1 := *NewProperty(randomSufix("prop-"), "p description 1")
+	prop2 := *NewProperty(randomSufix("prop-"), "p description 2")
 	p, err := fs.CreateProperty(prop1); require.NotNil(t, p, "Should get propertyName, %v", err)
 	p, err = fs.CreateProperty(prop2); require.NotNil(t, p, "Should get propertyName, %v", err)
 
@@ -154,7 +154,7 @@ func TestFeatureStore_SearchToggleRule__no_name(t *testing.T) {
 	filter := make(Filter)
 	filter[prop1.Name] = val1
 	filter[prop2.Name] = val2
-	features, err := fs.SearchToggleRule(nil, filter)
+	features, err := fs.SearchToggleRule(ToggleRuleSearch{Properties: filter})
 
 	PrintFeatures(*features)
 	require.NotZero(t, len(*features), "Result should contain one toggle rule, %v", *features)
@@ -162,7 +162,7 @@ func TestFeatureStore_SearchToggleRule__no_name(t *testing.T) {
 }
 
 func TestFeatureToggleStoreImpl_GetEnabledToggleRules(t *testing.T) {
-	var fs FeatureToggleStore = NewFeatureToggleStoreImpl()
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
 
 	err := fs.Open()
 	if err != nil {
@@ -176,8 +176,8 @@ func TestFeatureToggleStoreImpl_GetEnabledToggleRules(t *testing.T) {
 	feature := NewFeature(randomSufix("Name-"), true, "f description")
 	featureId, err := fs.CreateFeature(*feature); require.NotNil(t, featureId, "Should get featureId, %v", err)
 
-	prop1 := Property{randomSufix("prop-"), "p description 1"}
-	prop2 := Property{randomSufix("prop-"), "p description 2"}
+	prop1 := *NewProperty(randomSufix("prop-"), "p description 1")
+	prop2 := *NewProperty(randomSufix("prop-"), "p description 2")
 	p, err := fs.CreateProperty(prop1); require.NotNil(t, p, "Should get propertyName, %v", err)
 	p, err = fs.CreateProperty(prop2); require.NotNil(t, p, "Should get propertyName, %v", err)
 
@@ -193,6 +193,92 @@ func TestFeatureToggleStoreImpl_GetEnabledToggleRules(t *testing.T) {
 
 }
 
+func TestFeatureToggleStoreImpl_GetEnabledToggleRules__schedule(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	now := time.Now().Truncate(time.Microsecond)
+	feature := NewFeature(randomSufix("Name-"), true, "f description")
+	feature.Expires = now.Add(3 * time.Hour)
+	featureId, err := fs.CreateFeature(*feature); require.NotNil(t, featureId, "Should get featureId, %v", err)
+	prop1 := *NewProperty(randomSufix("prop-"), "p description 1")
+	p, err := fs.CreateProperty(prop1); require.NotNil(t, p, "Should get propertyName, %v", err)
+
+	upcoming := NewToggleRule(*featureId, true, prop1.Name, "upcoming")
+	upcoming.Starts = now.Add(time.Hour)
+	upcomingId, err := fs.CreateToggleRule(*upcoming); require.NotNil(t, upcomingId, "Should get ruleId, %v", err)
+	expired := NewToggleRule(*featureId, true, prop1.Name, "expired")
+	expired.Expires = now.Add(-time.Hour)
+	expiredId, err := fs.CreateToggleRule(*expired); require.NotNil(t, expiredId, "Should get ruleId, %v", err)
+
+	rules, err := fs.GetEnabledToggleRules()
+	require.NotNil(t, rules, "Should get rules, %v\n", err)
+
+	found := false
+	for _, rule := range *rules {
+		assert.NotEqual(t, *expiredId, rule.Id, "Should not get the expired rule")
+		if rule.Id == *upcomingId {
+			found = true
+			assert.True(t, upcoming.Starts.Equal(rule.Starts), "Should keep the start of the rule")
+			assert.True(t, feature.Expires.Equal(rule.Expires), "Should expire with the feature")
+		}
+	}
+	assert.True(t, found, "Should get the rule that has not started")
+}
+
+func TestFeatureToggleStoreImpl_GetEnabledToggleRules__local_time(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5 * 60 * 60)
+	defer func() { time.Local = local }()
+
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+	err := fs.Open()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	feature := NewFeature(randomSufix("Name-"), true, "f description")
+	featureId, err := fs.CreateFeature(*feature); require.NotNil(t, featureId, "Should get featureId, %v", err)
+	prop1 := *NewProperty(randomSufix("prop-"), "p description 1")
+	p, err := fs.CreateProperty(prop1); require.NotNil(t, p, "Should get propertyName, %v", err)
+
+	rule := NewToggleRule(*featureId, true, prop1.Name, "soon")
+	rule.Expires = time.Now().Add(30 * time.Minute)
+	ruleId, err := fs.CreateToggleRule(*rule); require.NotNil(t, ruleId, "Should get ruleId, %v", err)
+
+	created, err := fs.ReadToggleRule(*ruleId)
+	require.NotNil(t, created, "Should read the rule, %v", err)
+	assert.WithinDuration(t, time.Now(), created.Created, time.Minute, "Should store the created time in UTC")
+	assert.True(t, rule.Expires.Truncate(time.Microsecond).Equal(created.Expires), "Should keep the expire time")
+
+	rules, err := fs.GetEnabledToggleRules()
+	require.NotNil(t, rules, "Should get rules, %v\n", err)
+	found := false
+	for _, enabled := range *rules {
+		found = found || enabled.Id == *ruleId
+	}
+	assert.True(t, found, "Should not expire the rule by the local time zone")
+}
+
+func TestToggleRuleSearchQuery__local_time(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5 * 60 * 60)
+	defer func() { time.Local = local }()
+
+	start := time.Now()
+	_, params := toggleRuleSearchQuery(ToggleRuleSearch{CreatedStart:start})
+	require.Equal(t, 1, len(params))
+	assert.Equal(t, time.UTC, params[0].(time.Time).Location(), "Should compare with the UTC timestamps")
+	assert.True(t, start.Equal(params[0].(time.Time)))
+	assert.Equal(t, time.UTC, nullTime(start).Time.Location())
+}
+
 func randomSufix(text string) string {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 	return fmt.Sprintf("%s%d", text, r.Int())
@@ -203,4 +289,42 @@ func PrintFeatures(toggleRules []ToggleRule) {
 	for _, toggleRule := range toggleRules {
 		fmt.Printf("%v\n", toggleRule)
 	}
-}
\ No newline at end of file
+}
+func TestFeatureToggleStoreImpl_UpdateToggleRule(t *testing.T) {
+	var fs FeatureToggleStore = NewFeatureToggleStoreImpl(DBConfigFromEnv())
+
+	err := fs.Open()
+	if ( err != nil) {
+		panic(fmt.Sprintf("Failed to open database, %v", err))
+	}
+	defer fs.Close()
+
+	feature := NewFeature(randomSufix("Name-"), true, "f description")
+	featureId, err := fs.CreateFeature(*feature); require.NotNil(t, featureId, "Should get featureId, %v", err)
+
+	prop1 := *NewProperty(randomSufix("prop-"), "p description 1")
+	prop2 := *NewProperty(randomSufix("prop-"), "p description 2")
+	p, err := fs.CreateProperty(prop1); require.NotNil(t, p, "Should get propertyName, %v", err)
+	p, err = fs.CreateProperty(prop2); require.NotNil(t, p, "Should get propertyName, %v", err)
+
+	ruleId, err := fs.CreateToggleRule(*NewToggleRule(*featureId, true, prop1.Name, "val1"))
+	require.NotNil(t, ruleId, "Should get ruleId, %v", err)
+
+	update := NewToggleRule("", false, prop1.Name, "val1", prop2.Name, "val2")
+	update.Id = *ruleId
+	update.Priority = 5
+	update.Version = 1
+	toggleRule, err := fs.UpdateToggleRule(*update, []string{"properties", "priority"})
+	require.Nil(t, err, "Should not get an error, %v", err)
+	require.NotNil(t, toggleRule)
+
+	toggleRule, err = fs.ReadToggleRule(*ruleId)
+	require.Nil(t, err, "Should not get an error, %v", err)
+	assert.Equal(t, Properties{prop1.Name: "val1", prop2.Name: "val2"}, toggleRule.Properties, "Should rewrite the rows of the rule")
+	assert.Equal(t, 5, toggleRule.Priority)
+	assert.Equal(t, int64(2), toggleRule.Version, "Should increase the version")
+	assert.True(t, toggleRule.Enabled, "Should not change fields outside the paths")
+
+	_, err = fs.UpdateToggleRule(*update, []string{"priority"})
+	assert.IsType(t, &VersionMismatchError{}, err, "Should refuse a stale version")
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/peterrosell/feature-toggle-service/featuretree"
+)
+
+const (
+	INSERT_FEATURE_VARIANT_SQL = "INSERT INTO feature_variant(featureid, name, position, type, value) values ($1,$2,$3,$4,$5)"
+	READ_FEATURE_VARIANTS_SQL = "SELECT name, type, value FROM feature_variant WHERE featureid = $1 ORDER BY position"
+	DELETE_FEATURE_VARIANTS_SQL = "DELETE FROM feature_variant WHERE featureid = $1"
+	ENABLED_FEATURE_VARIANTS_SQL = "SELECT feature.name, feature.default_variant, v.name, v.type, v.value FROM feature_variant v JOIN feature ON feature.id = v.featureid WHERE feature.enabled = true ORDER BY feature.name, v.position"
+
+	INSERT_TOGGLE_RULE_VARIANT_SQL = "INSERT INTO toggle_rule_variant(ruleid, variant, position, weight) values ($1,$2,$3,$4)"
+	READ_TOGGLE_RULE_VARIANTS_SQL = "SELECT variant, weight FROM toggle_rule_variant WHERE ruleid = $1 ORDER BY position"
+	DELETE_TOGGLE_RULE_VARIANTS_SQL = "DELETE FROM toggle_rule_variant WHERE ruleid = $1"
+	ALL_TOGGLE_RULE_VARIANTS_SQL = "SELECT ruleid, variant, weight FROM toggle_rule_variant ORDER BY ruleid, position"
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func insertFeatureVariants(q queryer, featureId string, variants []featuretree.Variant) error {
+	for i, v := range variants {
+		_, err := q.Exec(INSERT_FEATURE_VARIANT_SQL, featureId, v.Name, i, v.Type, v.Value)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Failed to insert variant '%s', %v", v.Name, err))
+		}
+	}
+	return nil
+}
+
+func readFeatureVariants(q queryer, featureId string) ([]featuretree.Variant, error) {
+	rows, err := q.Query(READ_FEATURE_VARIANTS_SQL, featureId)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to select variants of '%s', %v", featureId, err))
+	}
+	defer rows.Close()
+
+	var variants []featuretree.Variant
+	for rows.Next() {
+		var v featuretree.Variant
+		err := rows.Scan(&v.Name, &v.Type, &v.Value)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Variant: Failed to scan row, %v", err))
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+func insertToggleRuleVariants(q queryer, ruleId string, variants []featuretree.WeightedVariant) error {
+	for i, v := range variants {
+		_, err := q.Exec(INSERT_TOGGLE_RULE_VARIANT_SQL, ruleId, v.Variant, i, v.Weight)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Failed to insert rule variant '%s', %v", v.Variant, err))
+		}
+	}
+	return nil
+}
+
+func readToggleRuleVariants(q queryer, ruleId string) ([]featuretree.WeightedVariant, error) {
+	rows, err := q.Query(READ_TOGGLE_RULE_VARIANTS_SQL, ruleId)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to select variants of rule '%s', %v", ruleId, err))
+	}
+	defer rows.Close()
+
+	var variants []featuretree.WeightedVariant
+	for rows.Next() {
+		var v featuretree.WeightedVariant
+		err := rows.Scan(&v.Variant, &v.Weight)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Rule variant: Failed to scan row, %v", err))
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+// readAllToggleRuleVariants returns the variants of all rules by rule id.
+func readAllToggleRuleVariants(q queryer) (map[string][]featuretree.WeightedVariant, error) {
+	rows, err := q.Query(ALL_TOGGLE_RULE_VARIANTS_SQL)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to select rule variants, %v", err))
+	}
+	defer rows.Close()
+
+	res := make(map[string][]featuretree.WeightedVariant)
+	for rows.Next() {
+		var ruleId string
+		var v featuretree.WeightedVariant
+		err := rows.Scan(&ruleId, &v.Variant, &v.Weight)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Rule variant: Failed to scan row, %v", err))
+		}
+		res[ruleId] = append(res[ruleId], v)
+	}
+	return res, rows.Err()
+}
+
+func (fs *FeatureToggleStoreImpl) GetFeatureVariants() (*[]featuretree.FeatureVariants, error) {
+	rows, err := fs.db.Query(ENABLED_FEATURE_VARIANTS_SQL)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("GetFeatureVariants: Failed to run query, %v", err))
+	}
+	defer rows.Close()
+
+	res := []featuretree.FeatureVariants{}
+	for rows.Next() {
+		var featureName string
+		var defaultVariant string
+		var v featuretree.Variant
+		err := rows.Scan(&featureName, &defaultVariant, &v.Name, &v.Type, &v.Value)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("GetFeatureVariants: Failed to scan row, %v", err))
+		}
+		if len(res) == 0 || res[len(res) - 1].Feature != featureName {
+			res = append(res, featuretree.FeatureVariants{Feature:featureName, Default:defaultVariant})
+		}
+		res[len(res) - 1].Variants = append(res[len(res) - 1].Variants, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("GetFeatureVariants: Failed to read rows, %v", err))
+	}
+	return &res, nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// The paths of an update are the field names of the api messages. An update
+// without paths changes every field that can be changed.
+var (
+	featureUpdatePaths    = []string{"name", "enabled", "description", "variants", "defaultVariant",
+		"starts", "expires"}
+	propertyUpdatePaths   = []string{"description"}
+	toggleRuleUpdatePaths = []string{"featureId", "enabled", "starts", "expires", "properties", "operators",
+		"rolloutPercentage", "rolloutProperty", "deny", "priority", "variants"}
+)
+
+func applyFeatureUpdate(feature *Feature, update Feature, paths []string) error {
+	if len(paths) == 0 {
+		paths = featureUpdatePaths
+	}
+	for _, path := range paths {
+		switch path {
+		case "name":
+			if update.Name == "" {
+				return &InvalidArgumentError{Field:"name", Description:"feature name is missing"}
+			}
+			feature.Name = update.Name
+		case "enabled":
+			feature.Enabled = update.Enabled
+		case "description":
+			feature.Description = update.Description
+		case "variants":
+			feature.Variants = copyVariants(update.Variants)
+		case "defaultVariant":
+			feature.DefaultVariant = update.DefaultVariant
+		case "starts":
+			feature.Starts = update.Starts
+		case "expires":
+			feature.Expires = update.Expires
+		default:
+			return &InvalidArgumentError{Field:"updateMask", Description:fmt.Sprintf("field '%s' of feature can't be updated", path)}
+		}
+	}
+	err := validateSchedule(feature.Starts, feature.Expires)
+	if err != nil {
+		return err
+	}
+	return validateVariants(*feature)
+}
+
+func applyPropertyUpdate(property *Property, update Property, paths []string) error {
+	if len(paths) == 0 {
+		paths = propertyUpdatePaths
+	}
+	for _, path := range paths {
+		switch path {
+		case "description":
+			property.Description = update.Description
+		default:
+			return &InvalidArgumentError{Field:"updateMask", Description:fmt.Sprintf("field '%s' of property can't be updated", path)}
+		}
+	}
+	return nil
+}
+
+// applyToggleRuleUpdate changes the rule, its id and created time are kept.
+// The feature variants the rule chooses are validated by the caller.
+func applyToggleRuleUpdate(toggleRule *ToggleRule, update ToggleRule, paths []string) error {
+	if len(paths) == 0 {
+		paths = toggleRuleUpdatePaths
+	}
+	for _, path := range paths {
+		switch path {
+		case "featureId":
+			toggleRule.FeatureId = update.FeatureId
+		case "enabled":
+			toggleRule.Enabled = update.Enabled
+		case "starts":
+			toggleRule.Starts = update.Starts
+		case "expires":
+			toggleRule.Expires = update.Expires
+		case "properties":
+			toggleRule.Properties = copyProperties(update.Properties)
+		case "operators":
+			toggleRule.Operators = copyOperators(update.Operators)
+		case "rolloutPercentage":
+			toggleRule.RolloutPercentage = update.RolloutPercentage
+		case "rolloutProperty":
+			toggleRule.RolloutProperty = update.RolloutProperty
+		case "deny":
+			toggleRule.Deny = update.Deny
+		case "priority":
+			toggleRule.Priority = update.Priority
+		case "variants":
+			toggleRule.Variants = copyWeightedVariants(update.Variants)
+		default:
+			return &InvalidArgumentError{Field:"updateMask", Description:fmt.Sprintf("field '%s' of toggle rule can't be updated", path)}
+		}
+	}
+	if containsPath(paths, "properties") && !containsPath(paths, "operators") {
+		// The operators of the properties the update removes go with them.
+		operators := make(map[string]string)
+		for property, operator := range toggleRule.Operators {
+			if _, ok := toggleRule.Properties[property]; ok {
+				operators[property] = operator
+			}
+		}
+		toggleRule.Operators = copyOperators(operators)
+	}
+	err := validateProperties(*toggleRule)
+	if err != nil {
+		return err
+	}
+	err = validateSchedule(toggleRule.Starts, toggleRule.Expires)
+	if err != nil {
+		return err
+	}
+	err = validateRollout(*toggleRule)
+	if err != nil {
+		return err
+	}
+	return validateOperators(*toggleRule)
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}